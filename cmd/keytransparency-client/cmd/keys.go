@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -24,10 +25,12 @@ import (
 	"time"
 
 	"github.com/google/keytransparency/core/crypto/keymaster"
+	"github.com/google/keytransparency/core/crypto/signatures/ed25519"
 	"github.com/google/keytransparency/core/crypto/signatures/p256"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 
 	kmpb "github.com/google/keytransparency/core/proto/keymaster"
 )
@@ -35,6 +38,9 @@ import (
 const (
 	keyStoreFile      = ".keystore"
 	keyIDTruncatedLen = 8
+	// passphraseEnv is the environment variable from which the keystore
+	// passphrase is read instead of prompting for it.
+	passphraseEnv = "KT_KEYSTORE_PASSPHRASE"
 )
 
 var (
@@ -44,9 +50,18 @@ var (
 	activate    bool
 	keyType     string
 	generate    bool
+	exportPriv  bool
 )
 
-var store *keymaster.KeyMaster
+var (
+	store *keymaster.KeyMaster
+	// passphrase encrypts the keystore file. It is nil until the
+	// passphrase has been read.
+	passphrase []byte
+	// modified is set by subcommands which change the keystore or its
+	// passphrase. Only modified keystores are saved.
+	modified bool
+)
 
 // keysCmd represents the authorized-keys command.
 var keysCmd = &cobra.Command{
@@ -61,7 +76,18 @@ Verifying always happens using the keys listed in the previous epoch.`,
 		}
 	},
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
-		buf, err := store.Marshal()
+		if !modified {
+			return nil
+		}
+		if passphrase == nil {
+			// New or plaintext keystore.
+			p, err := readNewPassphrase()
+			if err != nil {
+				return err
+			}
+			passphrase = p
+		}
+		buf, err := store.MarshalEncrypted(passphrase)
 		if err != nil {
 			return err
 		}
@@ -112,6 +138,12 @@ var addCmd = &cobra.Command{
 				if err := addPrivateKey(skPEM, description, activate); err == nil {
 					return err
 				}
+			case "ed25519":
+				skPEM, _, err := ed25519.GeneratePEMs()
+				if err != nil {
+					return err
+				}
+				return addPrivateKey(skPEM, description, activate)
 			default:
 				return fmt.Errorf("unrecognized key type %v", keyType)
 			}
@@ -161,6 +193,7 @@ If the list contains a single key, it cannot be removed.
 		if err := store.RemoveSigningKey(keyID); err != nil && err != keymaster.ErrKeyNotExist {
 			return err
 		}
+		modified = true
 		return nil
 	},
 }
@@ -179,7 +212,90 @@ var activateCmd = &cobra.Command{
 			return fmt.Errorf("key ID needs to be provided")
 		}
 		keyID := keyID(args[0])
-		return store.Activate(keyID)
+		if err := store.Activate(keyID); err != nil {
+			return err
+		}
+		modified = true
+		return nil
+	},
+}
+
+// passwdCmd represents the authorized-keys passwd command.
+var passwdCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "Change the passphrase of the keystore",
+	Long: `Change the passphrase the keystore is encrypted with. e.g.:
+
+./keytransparency-client authorized-keys passwd
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := readNewPassphrase()
+		if err != nil {
+			return err
+		}
+		passphrase = p
+		modified = true
+		return nil
+	},
+}
+
+// exportCmd represents the authorized-keys export command.
+var exportCmd = &cobra.Command{
+	Use:   "export [keyid] [--private]",
+	Short: "Export a key in PEM format",
+	Long: `Write the public key, or with --private the private key, of a key in the list of authorized keys to stdout. e.g.:
+
+./keytransparency-client authorized-keys export [keyid] > pubkey.pem
+./keytransparency-client authorized-keys export [keyid] --private > privkey.pem
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("key ID needs to be provided")
+		}
+		keyID := keyID(args[0])
+		var pemKey []byte
+		if exportPriv {
+			signer, err := store.Signer(keyID)
+			if err != nil {
+				return err
+			}
+			if pemKey, err = signer.PrivateKeyPEM(); err != nil {
+				return err
+			}
+		} else {
+			verifier, err := store.Verifier(keyID)
+			if err != nil {
+				return err
+			}
+			if pemKey, err = verifier.PublicKeyPEM(); err != nil {
+				return err
+			}
+		}
+		_, err := os.Stdout.Write(pemKey)
+		return err
+	},
+}
+
+// importCmd represents the authorized-keys import command.
+var importCmd = &cobra.Command{
+	Use:   "import [path] [--activate] --description=[comment]",
+	Short: "Import a PEM formatted key",
+	Long: `Import a public or private key in PEM format into the list of authorized keys. e.g.:
+
+./keytransparency-client authorized-keys import /path/to/PEM/key --description=[comment]
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("path needs to be provided")
+		}
+		pemKey, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		if _, err := keymaster.NewSignerFromPEM(pemKey); err == nil {
+			return addPrivateKey(pemKey, description, activate)
+		}
+		return addPublicKey(pemKey, description)
 	},
 }
 
@@ -238,15 +354,62 @@ func readKeyStoreFile() error {
 		if err != nil {
 			return fmt.Errorf("reading keystore file failed: %v", err)
 		}
-		if err = keymaster.Unmarshal(data, store); err != nil {
-			return fmt.Errorf("keystore.Unmarshak() failed: %v", err)
+		if !keymaster.IsEncrypted(data) {
+			// Plaintext keystores are encrypted when saved.
+			if err = keymaster.Unmarshal(data, store); err != nil {
+				return fmt.Errorf("keystore.Unmarshal() failed: %v", err)
+			}
+			return nil
+		}
+		p, err := readPassphrase("Keystore passphrase: ")
+		if err != nil {
+			return err
+		}
+		if err = keymaster.UnmarshalEncrypted(data, p, store); err != nil {
+			return fmt.Errorf("keystore.UnmarshalEncrypted() failed: %v", err)
 		}
+		passphrase = p
 	} else if !os.IsNotExist(err) {
 		return fmt.Errorf("error checking if keystore file exists: %v", err)
 	}
 	return nil
 }
 
+// readPassphrase reads the keystore passphrase from the environment or
+// prompts for it.
+func readPassphrase(prompt string) ([]byte, error) {
+	if p, ok := os.LookupEnv(passphraseEnv); ok {
+		return []byte(p), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("reading passphrase failed: %v", err)
+	}
+	return p, nil
+}
+
+// readNewPassphrase reads a new keystore passphrase, prompting twice to
+// avoid typos.
+func readNewPassphrase() ([]byte, error) {
+	p, err := readPassphrase("New keystore passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := os.LookupEnv(passphraseEnv); ok {
+		return p, nil
+	}
+	confirm, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p, confirm) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return p, nil
+}
+
 func addPublicKey(pkPEM []byte, description string) error {
 	if activate {
 		return errors.New("--activate requires a private key")
@@ -254,6 +417,7 @@ func addPublicKey(pkPEM []byte, description string) error {
 	if _, err := store.AddVerifyingKey(description, pkPEM); err != nil {
 		return err
 	}
+	modified = true
 	return nil
 }
 
@@ -279,6 +443,7 @@ func addPrivateKey(skPEM []byte, description string, activate bool) error {
 	if _, err := store.AddVerifyingKey(description, pkPEM); err != nil {
 		return err
 	}
+	modified = true
 	return nil
 }
 
@@ -298,11 +463,19 @@ func init() {
 	keysCmd.AddCommand(removeCmd)
 	keysCmd.AddCommand(activateCmd)
 	keysCmd.AddCommand(listCmd)
+	keysCmd.AddCommand(passwdCmd)
+	keysCmd.AddCommand(exportCmd)
+	keysCmd.AddCommand(importCmd)
 
 	addCmd.PersistentFlags().StringVar(&pubKey, "pubkey", "", "Path to a public key file")
 	addCmd.PersistentFlags().StringVar(&privKey, "privkey", "", "Path to a private key file")
 	addCmd.PersistentFlags().StringVar(&description, "description", "", "(Optional) Description of the added authorized key")
 	addCmd.PersistentFlags().BoolVar(&activate, "activate", false, "(Optional) Activate the added signing key")
 	addCmd.PersistentFlags().BoolVar(&generate, "generate", false, "Generate a random public and private key pair")
-	addCmd.PersistentFlags().StringVar(&keyType, "type", "", "The key type to be generated, e.g., ecdsa")
+	addCmd.PersistentFlags().StringVar(&keyType, "type", "", "The key type to be generated, e.g., ecdsa or ed25519")
+
+	exportCmd.PersistentFlags().BoolVar(&exportPriv, "private", false, "Export the private key instead of the public key")
+
+	importCmd.PersistentFlags().StringVar(&description, "description", "", "(Optional) Description of the imported key")
+	importCmd.PersistentFlags().BoolVar(&activate, "activate", false, "(Optional) Activate the imported signing key")
 }
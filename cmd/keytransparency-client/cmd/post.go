@@ -20,6 +20,9 @@ import (
 	"log"
 	"time"
 
+	"github.com/google/keytransparency/cmd/keytransparency-client/grpcc"
+	"github.com/google/keytransparency/core/rejection"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
@@ -86,7 +89,25 @@ User email MUST match the OAuth account used to authorize the update.
 			return fmt.Errorf("updateKeys() failed: %v", err)
 		}
 		// TODO: fill signers and authorizedKeys.
-		if _, err := c.Update(ctx, userID, appID, profileData, signers, authorizedKeys); err != nil {
+		req, err := c.CreateUpdate(ctx, userID, appID, profileData, signers, authorizedKeys)
+		if err != nil {
+			return fmt.Errorf("update failed: %v", err)
+		}
+		// Record the update before sending it, such that watchers
+		// never report it as unexpected.
+		ws, err := watchStore()
+		if err != nil {
+			return err
+		}
+		if err := grpcc.RecordAuthored(ws, req); err != nil {
+			return fmt.Errorf("recording update failed: %v", err)
+		}
+		err = c.Submit(ctx, req)
+		if err != nil {
+			if r, ok := err.(*rejection.Rejection); ok {
+				// The rejection explains why the server refused it.
+				return r
+			}
 			return fmt.Errorf("update failed: %v", err)
 		}
 		fmt.Printf("New key for %v: %x\n", userID, data)
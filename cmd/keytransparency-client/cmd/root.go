@@ -15,12 +15,13 @@
 package cmd
 
 import (
-	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/google/keytransparency/cmd/keytransparency-client/grpcc"
@@ -84,6 +85,10 @@ func init() {
 	RootCmd.PersistentFlags().String("kt-cert", "genfiles/server.crt", "Path to public key for Key Transparency")
 	RootCmd.PersistentFlags().Bool("autoconfig", true, "Fetch config info from the server's /v1/domain/info")
 	RootCmd.PersistentFlags().Bool("insecure", false, "Skip TLS checks")
+	RootCmd.PersistentFlags().String("client-cert", "", "Path to the client certificate presented to Key Transparency for mutual TLS authentication")
+	RootCmd.PersistentFlags().String("client-key", "", "Path to the private key of --client-cert")
+	RootCmd.PersistentFlags().String("trusted-roots", ".trusted_roots", "Directory in which the trusted log root of each Key Transparency server is stored")
+	RootCmd.PersistentFlags().String("watch-state", ".watch_state", "Directory in which the last verified watched entries and the updates authored by this client are stored")
 
 	RootCmd.PersistentFlags().String("vrf", "genfiles/vrf-pubkey.pem", "path to vrf public key")
 
@@ -170,24 +175,24 @@ func getServiceCreds(serviceKeyFile string) (credentials.PerRPCCredentials, erro
 func transportCreds(ktURL string) (credentials.TransportCredentials, error) {
 	ktCert := viper.GetString("kt-cert")
 	insecure := viper.GetBool("insecure")
+	clientCert := viper.GetString("client-cert")
+	clientKey := viper.GetString("client-key")
 
 	host, _, err := net.SplitHostPort(ktURL)
 	if err != nil {
 		return nil, err
 	}
 
-	switch {
-	case insecure: // Impatient insecure.
-		return credentials.NewTLS(&tls.Config{
-			InsecureSkipVerify: true,
-		}), nil
-
-	case ktCert != "": // Custom CA Cert.
-		return credentials.NewClientTLSFromFile(ktCert, host)
-
-	default: // Use the local set of root certs.
-		return credentials.NewClientTLSFromCert(nil, host), nil
+	if insecure { // Impatient insecure.
+		ktCert = ""
+	}
+	// An empty kt-cert uses the local set of root certs.
+	config, err := grpcc.TLSConfig(host, ktCert, clientCert, clientKey)
+	if err != nil {
+		return nil, err
 	}
+	config.InsecureSkipVerify = insecure
+	return credentials.NewTLS(config), nil
 }
 
 // userCreds returns PerRPCCredentials. Only one type of credential
@@ -252,7 +257,37 @@ func GetClient(useClientSecret bool) (*grpcc.Client, error) {
 		return nil, fmt.Errorf("Error reading config: %v", err)
 	}
 
-	return grpcc.NewFromConfig(cc, config)
+	c, err := grpcc.NewFromConfig(cc, config)
+	if err != nil {
+		return nil, err
+	}
+	store, err := trustedRootStore(ktURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetTrustedRootStore(store); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// trustedRootStore returns the store of the trusted log root of ktURL.
+func trustedRootStore(ktURL string) (grpcc.TrustedRootStore, error) {
+	dir := viper.GetString("trusted-roots")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Error creating trusted roots directory: %v", err)
+	}
+	return grpcc.NewFileRootStore(filepath.Join(dir, url.QueryEscape(ktURL))), nil
+}
+
+// watchStore returns the store of watched entries for the Key Transparency
+// server at kt-url.
+func watchStore() (grpcc.WatchStore, error) {
+	dir := viper.GetString("watch-state")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Error creating watch state directory: %v", err)
+	}
+	return grpcc.NewFileWatchStore(filepath.Join(dir, url.QueryEscape(viper.GetString("kt-url")))), nil
 }
 
 // config selects a source for and returns the client configuration.
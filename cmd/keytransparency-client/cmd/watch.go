@@ -0,0 +1,170 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/google/keytransparency/cmd/keytransparency-client/grpcc"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// exitUnexpectedChange is the exit code of watch when --exit-on-change is set
+// and an unexpected change is detected.
+const exitUnexpectedChange = 3
+
+// errUnexpectedChange stops watching with --exit-on-change.
+var errUnexpectedChange = errors.New("unexpected change")
+
+var (
+	watchPeriod time.Duration
+	watchOnce   bool
+	watchExec   string
+	watchNotify bool
+	watchExit   bool
+)
+
+// watchCmd watches entries for changes not authored by this client.
+var watchCmd = &cobra.Command{
+	Use:   "watch [user email] [app]...",
+	Short: "Watch entries for unexpected changes",
+	Long: `Watch verifies every new epoch of the entries of a user and reports
+changes which have not been posted by this client, e.g. a key the user does
+not recognize. eg:
+
+  ./keytransparency-client watch foobar@example.com app1 app2 --exec ./alert.sh
+
+The first run records the current entries. Unexpected changes are printed and
+can run a command, show a desktop notification or end watch with exit code 3.
+The command is run by the shell with KT_USER_ID, KT_APP_ID and KT_EPOCH set.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("user email and at least one app need to be provided")
+		}
+		ids := make([]grpcc.Identity, 0, len(args)-1)
+		for _, appID := range args[1:] {
+			ids = append(ids, grpcc.Identity{UserID: args[0], AppID: appID})
+		}
+		store, err := watchStore()
+		if err != nil {
+			return err
+		}
+		c, err := GetClient(false)
+		if err != nil {
+			return fmt.Errorf("error connecting: %v", err)
+		}
+
+		if watchOnce {
+			ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
+			defer cancel()
+			changes, err := c.Poll(ctx, store, ids)
+			if err != nil {
+				return fmt.Errorf("Poll failed: %v", err)
+			}
+			for _, change := range changes {
+				if err := reportChange(change); err != nil {
+					return exitWatch(err)
+				}
+			}
+			return nil
+		}
+
+		for {
+			err := c.Watch(context.Background(), store, ids, watchPeriod, reportChange)
+			switch grpc.Code(err) {
+			case codes.Unavailable, codes.DeadlineExceeded:
+				// Keep watching through transient failures.
+				fmt.Fprintf(os.Stderr, "Watch failed, retrying in %v: %v\n", watchPeriod, err)
+				time.Sleep(watchPeriod)
+			default:
+				return exitWatch(err)
+			}
+		}
+	},
+}
+
+// exitWatch exits with exitUnexpectedChange if watching stopped due to an
+// unexpected change.
+func exitWatch(err error) error {
+	if err == errUnexpectedChange {
+		os.Exit(exitUnexpectedChange)
+	}
+	return err
+}
+
+// reportChange prints change and raises the configured alerts if change has
+// not been authored by this client.
+func reportChange(change *grpcc.Change) error {
+	if change.Authored {
+		fmt.Printf("Epoch %v: %v/%v changed by this client: %x\n",
+			change.Epoch, change.UserID, change.AppID, change.NewProfile)
+		return nil
+	}
+	msg := fmt.Sprintf("Epoch %v: unexpected change of %v/%v: %x",
+		change.Epoch, change.UserID, change.AppID, change.NewProfile)
+	fmt.Printf("ALERT: %v\n", msg)
+	if watchExec != "" {
+		hook := exec.Command("sh", "-c", watchExec)
+		hook.Env = append(os.Environ(),
+			"KT_USER_ID="+change.UserID,
+			"KT_APP_ID="+change.AppID,
+			fmt.Sprintf("KT_EPOCH=%v", change.Epoch))
+		hook.Stdout = os.Stdout
+		hook.Stderr = os.Stderr
+		if err := hook.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Alert command failed: %v\n", err)
+		}
+	}
+	if watchNotify {
+		if err := notify("Key Transparency alert", msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Desktop notification failed: %v\n", err)
+		}
+	}
+	if watchExit {
+		return errUnexpectedChange
+	}
+	return nil
+}
+
+// notify shows a desktop notification.
+func notify(title, msg string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", msg, title)
+		return exec.Command("osascript", "-e", script).Run()
+	default:
+		return exec.Command("notify-send", title, msg).Run()
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().DurationVar(&watchPeriod, "period", time.Minute, "Time between checks for new epochs")
+	watchCmd.Flags().BoolVar(&watchOnce, "once", false, "Check for new epochs once and exit")
+	watchCmd.Flags().StringVar(&watchExec, "exec", "", "Command run on unexpected changes")
+	watchCmd.Flags().BoolVar(&watchNotify, "notify", false, "Show a desktop notification on unexpected changes")
+	watchCmd.Flags().BoolVar(&watchExit, "exit-on-change", false, "Exit with code 3 on the first unexpected change")
+}
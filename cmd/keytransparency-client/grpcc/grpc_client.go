@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/google/keytransparency/core/client/kt"
@@ -32,6 +33,7 @@ import (
 	"github.com/google/keytransparency/core/crypto/vrf/p256"
 	"github.com/google/keytransparency/core/mutator"
 	"github.com/google/keytransparency/core/mutator/entry"
+	"github.com/google/keytransparency/core/rejection"
 
 	"github.com/google/trillian/client"
 	"github.com/google/trillian/crypto/keys/der"
@@ -60,6 +62,9 @@ var (
 	// ErrIncomplete occurs when the server indicates that requested epochs
 	// are not available.
 	ErrIncomplete = errors.New("incomplete account history")
+	// ErrInconsistentRoot occurs when the server returns a log root which is
+	// not consistent with the trusted log root.
+	ErrInconsistentRoot = errors.New("log root is inconsistent with the trusted log root")
 	// Vlog is the verbose logger. By default it outputs to /dev/null.
 	Vlog = log.New(ioutil.Discard, "", 0)
 )
@@ -83,7 +88,10 @@ type Client struct {
 	mutator    mutator.Mutator
 	RetryCount int
 	RetryDelay time.Duration
-	trusted    trillian.SignedLogRoot
+
+	mu      sync.Mutex // Guards trusted.
+	trusted trillian.SignedLogRoot
+	store   TrustedRootStore
 }
 
 // NewFromConfig creates a new client from a config
@@ -138,18 +146,66 @@ func New(cc *grpc.ClientConn,
 	}
 }
 
-// GetEntry returns an entry if it exists, and nil if it does not.
-func (c *Client) GetEntry(ctx context.Context, userID, appID string, opts ...grpc.CallOption) ([]byte, *trillian.SignedMapRoot, error) {
-	e, err := c.cli.GetEntry(ctx, &tpb.GetEntryRequest{
-		UserId:        userID,
-		AppId:         appID,
-		FirstTreeSize: c.trusted.TreeSize,
-	}, opts...)
+// SetTrustedRootStore loads the trusted log root from store and persists every
+// subsequently trusted log root to store.
+func (c *Client) SetTrustedRootStore(store TrustedRootStore) error {
+	root, err := store.Get()
 	if err != nil {
-		return nil, nil, err
+		return fmt.Errorf("TrustedRootStore.Get(): %v", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trusted = *root
+	c.store = store
+	return nil
+}
+
+// trustedRoot returns a copy of the trusted log root.
+func (c *Client) trustedRoot() trillian.SignedLogRoot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.trusted
+}
+
+// verifyGetEntryResponse verifies in against trusted, the log root whose tree
+// size was sent in the request, and advances the trusted log root to the log
+// root of in.
+func (c *Client) verifyGetEntryResponse(ctx context.Context, userID, appID string, trusted *trillian.SignedLogRoot, in *tpb.GetEntryResponse) error {
+	if err := c.kt.VerifyGetEntryResponse(ctx, userID, appID, trusted, in); err != nil {
+		return err
 	}
+	return c.updateTrusted(in.GetLogRoot())
+}
+
+// updateTrusted advances the trusted log root to root, which has been verified
+// to be consistent with a previously trusted log root, and persists it.
+func (c *Client) updateTrusted(root *trillian.SignedLogRoot) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case root.GetTreeSize() < c.trusted.TreeSize:
+		// A concurrent request already advanced the trusted root.
+		return nil
+	case root.GetTreeSize() == c.trusted.TreeSize && c.trusted.TreeSize > 0:
+		if !bytes.Equal(root.GetRootHash(), c.trusted.RootHash) {
+			return ErrInconsistentRoot
+		}
+		return nil
+	}
+	if c.store != nil {
+		if err := c.store.Set(root); err != nil {
+			return fmt.Errorf("TrustedRootStore.Set(): %v", err)
+		}
+	}
+	c.trusted = *root
+	Vlog.Printf("✓ Trusted log root advanced to tree size %v.", root.GetTreeSize())
+	return nil
+}
 
-	if err := c.kt.VerifyGetEntryResponse(ctx, userID, appID, &c.trusted, e); err != nil {
+// GetEntry returns an entry if it exists, and nil if it does not.
+func (c *Client) GetEntry(ctx context.Context, userID, appID string, opts ...grpc.CallOption) ([]byte, *trillian.SignedMapRoot, error) {
+	e, err := c.getEntry(ctx, userID, appID, opts...)
+	if err != nil {
 		return nil, nil, err
 	}
 
@@ -161,6 +217,24 @@ func (c *Client) GetEntry(ctx context.Context, userID, appID string, opts ...grp
 	return e.GetCommitted().GetData(), e.GetSmr(), nil
 }
 
+// getEntry returns the verified current entry of userID.
+func (c *Client) getEntry(ctx context.Context, userID, appID string, opts ...grpc.CallOption) (*tpb.GetEntryResponse, error) {
+	trusted := c.trustedRoot()
+	e, err := c.cli.GetEntry(ctx, &tpb.GetEntryRequest{
+		UserId:        userID,
+		AppId:         appID,
+		FirstTreeSize: trusted.TreeSize,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.verifyGetEntryResponse(ctx, userID, appID, &trusted, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
 func min(x, y int32) int32 {
 	if x < y {
 		return x
@@ -171,42 +245,57 @@ func min(x, y int32) int32 {
 // ListHistory returns a list of profiles starting and ending at given epochs.
 // It also filters out all identical consecutive profiles.
 func (c *Client) ListHistory(ctx context.Context, userID, appID string, start, end int64, opts ...grpc.CallOption) (map[*trillian.SignedMapRoot][]byte, error) {
-	if start < 0 {
-		return nil, fmt.Errorf("start=%v, want >= 0", start)
-	}
 	var currentProfile []byte
 	profiles := make(map[*trillian.SignedMapRoot][]byte)
+	if err := c.listEntryHistory(ctx, userID, appID, start, end, func(v *tpb.GetEntryResponse) error {
+		// Compress profiles that are equal through time.  All
+		// nil profiles before the first profile are ignored.
+		profile := v.GetCommitted().GetData()
+		if bytes.Equal(currentProfile, profile) {
+			return nil
+		}
+
+		// Append the slice and update currentProfile.
+		profiles[v.GetSmr()] = profile
+		currentProfile = profile
+		return nil
+	}, opts...); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// listEntryHistory verifies the entries of userID in epochs [start, end] and
+// calls fn for each of them in epoch order.
+func (c *Client) listEntryHistory(ctx context.Context, userID, appID string, start, end int64, fn func(*tpb.GetEntryResponse) error, opts ...grpc.CallOption) error {
+	if start < 0 {
+		return fmt.Errorf("start=%v, want >= 0", start)
+	}
 	epochsReceived := int64(0)
 	epochsWant := end - start + 1
 	for epochsReceived < epochsWant {
+		trusted := c.trustedRoot()
 		resp, err := c.cli.ListEntryHistory(ctx, &tpb.ListEntryHistoryRequest{
-			UserId:   userID,
-			AppId:    appID,
-			Start:    start,
-			PageSize: min(int32((end-start)+1), pageSize),
+			UserId:        userID,
+			AppId:         appID,
+			Start:         start,
+			PageSize:      min(int32((end-start)+1), pageSize),
+			FirstTreeSize: trusted.TreeSize,
 		}, opts...)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		epochsReceived += int64(len(resp.GetValues()))
 
 		for i, v := range resp.GetValues() {
 			Vlog.Printf("Processing entry for %v, epoch %v", userID, start+int64(i))
-			err = c.kt.VerifyGetEntryResponse(ctx, userID, appID, &c.trusted, v)
+			err = c.verifyGetEntryResponse(ctx, userID, appID, &trusted, v)
 			if err != nil {
-				return nil, err
+				return err
 			}
-
-			// Compress profiles that are equal through time.  All
-			// nil profiles before the first profile are ignored.
-			profile := v.GetCommitted().GetData()
-			if bytes.Equal(currentProfile, profile) {
-				continue
+			if err := fn(v); err != nil {
+				return err
 			}
-
-			// Append the slice and update currentProfile.
-			profiles[v.GetSmr()] = profile
-			currentProfile = profile
 		}
 		if resp.NextStart == 0 {
 			break // No more data.
@@ -215,32 +304,45 @@ func (c *Client) ListHistory(ctx context.Context, userID, appID string, start, e
 	}
 
 	if epochsReceived < epochsWant {
-		return nil, ErrIncomplete
+		return ErrIncomplete
 	}
-
-	return profiles, nil
+	return nil
 }
 
 // Update creates an UpdateEntryRequest for a user, attempt to submit it multiple
-// times depending on RetryCount.
+// times depending on RetryCount. If the server refuses the update, a
+// *rejection.Rejection describing the reason is returned.
 func (c *Client) Update(ctx context.Context, userID, appID string, profileData []byte,
 	signers []signatures.Signer, authorizedKeys []*tpb.PublicKey,
 	opts ...grpc.CallOption) (*tpb.UpdateEntryRequest, error) {
+	req, err := c.CreateUpdate(ctx, userID, appID, profileData, signers, authorizedKeys, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return req, c.Submit(ctx, req)
+}
+
+// CreateUpdate creates an UpdateEntryRequest for a user without sending it.
+func (c *Client) CreateUpdate(ctx context.Context, userID, appID string, profileData []byte,
+	signers []signatures.Signer, authorizedKeys []*tpb.PublicKey,
+	opts ...grpc.CallOption) (*tpb.UpdateEntryRequest, error) {
+	trusted := c.trustedRoot()
 	getResp, err := c.cli.GetEntry(ctx, &tpb.GetEntryRequest{
 		UserId:        userID,
 		AppId:         appID,
-		FirstTreeSize: c.trusted.TreeSize,
+		FirstTreeSize: trusted.TreeSize,
 	}, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("GetEntry(%v): %v", userID, err)
 	}
 	Vlog.Printf("Got current entry...")
 
-	if err := c.kt.VerifyGetEntryResponse(ctx, userID, appID, &c.trusted, getResp); err != nil {
+	if err := c.verifyGetEntryResponse(ctx, userID, appID, &trusted, getResp); err != nil {
 		return nil, fmt.Errorf("VerifyGetEntryResponse(): %v", err)
 	}
 
-	req, err := kt.CreateUpdateEntryRequest(&c.trusted, getResp, c.vrf, userID, appID, profileData, signers, authorizedKeys)
+	trusted = c.trustedRoot()
+	req, err := kt.CreateUpdateEntryRequest(&trusted, getResp, c.vrf, userID, appID, profileData, signers, authorizedKeys)
 	if err != nil {
 		return nil, fmt.Errorf("CreateUpdateEntryRequest: %v", err)
 	}
@@ -253,26 +355,42 @@ func (c *Client) Update(ctx context.Context, userID, appID string, profileData [
 		return nil, fmt.Errorf("Mutate: %v", err)
 	}
 
-	err = c.Retry(ctx, req)
+	return req, nil
+}
+
+// Submit sends req and attempts to resend it multiple times depending on
+// RetryCount until an inclusion proof is returned. If the server refuses the
+// update, a *rejection.Rejection describing the reason is returned.
+func (c *Client) Submit(ctx context.Context, req *tpb.UpdateEntryRequest) error {
+	err := c.Retry(ctx, req)
 	// Retry submitting until an inclusion proof is returned.
 	for i := 0; err == ErrRetry && i < c.RetryCount; i++ {
 		time.Sleep(c.RetryDelay)
 		err = c.Retry(ctx, req)
 	}
-	return req, err
+	return err
 }
 
-// Retry will take a pre-fabricated request and send it again.
+// Retry will take a pre-fabricated request and send it again. The
+// first_tree_size of req is set to the size of the current trusted log root.
+// If the server refuses the update, a *rejection.Rejection describing the
+// reason is returned.
 func (c *Client) Retry(ctx context.Context, req *tpb.UpdateEntryRequest) error {
+	trusted := c.trustedRoot()
+	req.FirstTreeSize = trusted.TreeSize
 	Vlog.Printf("Sending Update request...")
 	updateResp, err := c.cli.UpdateEntry(ctx, req)
 	if err != nil {
+		// Tell why the server refused the update, if it did.
+		if r, ok := rejection.FromError(err); ok {
+			return r
+		}
 		return err
 	}
 	Vlog.Printf("Got current entry...")
 
 	// Validate response.
-	if err := c.kt.VerifyGetEntryResponse(ctx, req.UserId, req.AppId, &c.trusted, updateResp.GetProof()); err != nil {
+	if err := c.verifyGetEntryResponse(ctx, req.UserId, req.AppId, &trusted, updateResp.GetProof()); err != nil {
 		return fmt.Errorf("VerifyGetEntryResponse(): %v", err)
 	}
 
@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// ErrIncompleteKeyPair occurs when only one of the client certificate and
+// key is given.
+var ErrIncompleteKeyPair = errors.New("client certificate and key must be given together")
+
+// TLSConfig returns the TLS config for connecting to host. The server
+// certificate is verified against the certificates in caFile, or the system
+// roots if caFile is empty. If certFile and keyFile are set, the client
+// certificate in certFile is presented to the server for mutual TLS
+// authentication.
+func TLSConfig(host, caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: host}
+	if caFile != "" {
+		b, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %v", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, ErrIncompleteKeyPair
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
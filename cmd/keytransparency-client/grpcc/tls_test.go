@@ -0,0 +1,104 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate and its key to dir.
+func writeKeyPair(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "alice"},
+		EmailAddresses:        []string{"alice@example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate(): %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey(): %v", err)
+	}
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcc")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeKeyPair(t, dir)
+
+	for _, tc := range []struct {
+		caFile, certFile, keyFile string
+		wantCerts                 int
+		success                   bool
+	}{
+		{"", "", "", 0, true},
+		{certFile, "", "", 0, true},
+		{certFile, certFile, keyFile, 1, true},
+		{"", certFile, "", 0, false},
+		{"", "", keyFile, 0, false},
+		{"", keyFile, certFile, 0, false},
+		{keyFile, "", "", 0, false},
+		{filepath.Join(dir, "missing"), "", "", 0, false},
+	} {
+		config, err := TLSConfig("example.com", tc.caFile, tc.certFile, tc.keyFile)
+		if got := err == nil; got != tc.success {
+			t.Errorf("TLSConfig(%q, %q, %q): %v, want success %v", tc.caFile, tc.certFile, tc.keyFile, err, tc.success)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got, want := len(config.Certificates), tc.wantCerts; got != want {
+			t.Errorf("TLSConfig(%q, %q, %q): %v certificates, want %v", tc.caFile, tc.certFile, tc.keyFile, got, want)
+		}
+		if got, want := config.RootCAs != nil, tc.caFile != ""; got != want {
+			t.Errorf("TLSConfig(%q, %q, %q): RootCAs set %v, want %v", tc.caFile, tc.certFile, tc.keyFile, got, want)
+		}
+	}
+}
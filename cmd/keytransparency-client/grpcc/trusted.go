@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+// TrustedRootStore persists the trusted log root of a client such that
+// consistency with previously seen log roots is also checked across restarts.
+type TrustedRootStore interface {
+	// Get returns the stored log root. If no root has been stored yet, Get
+	// returns an empty root.
+	Get() (*trillian.SignedLogRoot, error)
+	// Set replaces the stored log root with root.
+	Set(root *trillian.SignedLogRoot) error
+}
+
+// FileRootStore stores the trusted log root in a file.
+type FileRootStore struct {
+	path string
+}
+
+// NewFileRootStore returns a TrustedRootStore which stores the trusted log
+// root in the file at path.
+func NewFileRootStore(path string) *FileRootStore {
+	return &FileRootStore{path: path}
+}
+
+// Get reads the log root from the file. A missing file yields an empty root.
+func (f *FileRootStore) Get() (*trillian.SignedLogRoot, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return &trillian.SignedLogRoot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	root := new(trillian.SignedLogRoot)
+	if err := proto.Unmarshal(b, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Set writes root to the file. The root is written to a temporary file first
+// so that a crash never leaves a partially written root behind.
+func (f *FileRootStore) Set(root *trillian.SignedLogRoot) error {
+	b, err := proto.Marshal(root)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
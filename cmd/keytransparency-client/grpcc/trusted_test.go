@@ -0,0 +1,102 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+func TestFileRootStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcc")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileRootStore(filepath.Join(dir, "root"))
+
+	root, err := store.Get()
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if got, want := root.GetTreeSize(), int64(0); got != want {
+		t.Errorf("Get().TreeSize: %v, want %v", got, want)
+	}
+
+	want := &trillian.SignedLogRoot{TreeSize: 3, RootHash: []byte("root")}
+	if err := store.Set(want); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	got, err := store.Get()
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("Get(): %v, want %v", got, want)
+	}
+}
+
+func TestUpdateTrusted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcc")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileRootStore(filepath.Join(dir, "root"))
+	c := &Client{}
+	if err := c.SetTrustedRootStore(store); err != nil {
+		t.Fatalf("SetTrustedRootStore(): %v", err)
+	}
+
+	for _, tc := range []struct {
+		root     *trillian.SignedLogRoot
+		wantErr  error
+		wantSize int64
+	}{
+		{&trillian.SignedLogRoot{TreeSize: 2, RootHash: []byte("a")}, nil, 2},
+		{&trillian.SignedLogRoot{TreeSize: 2, RootHash: []byte("a")}, nil, 2},
+		{&trillian.SignedLogRoot{TreeSize: 2, RootHash: []byte("b")}, ErrInconsistentRoot, 2},
+		{&trillian.SignedLogRoot{TreeSize: 1, RootHash: []byte("c")}, nil, 2},
+		{&trillian.SignedLogRoot{TreeSize: 5, RootHash: []byte("d")}, nil, 5},
+	} {
+		if got, want := c.updateTrusted(tc.root), tc.wantErr; got != want {
+			t.Errorf("updateTrusted(%v): %v, want %v", tc.root, got, want)
+		}
+		stored, err := store.Get()
+		if err != nil {
+			t.Fatalf("Get(): %v", err)
+		}
+		if got, want := stored.GetTreeSize(), tc.wantSize; got != want {
+			t.Errorf("stored TreeSize: %v, want %v", got, want)
+		}
+		if got, want := c.trustedRoot().TreeSize, tc.wantSize; got != want {
+			t.Errorf("trusted TreeSize: %v, want %v", got, want)
+		}
+	}
+
+	// A new client resumes from the stored root.
+	c2 := &Client{}
+	if err := c2.SetTrustedRootStore(store); err != nil {
+		t.Fatalf("SetTrustedRootStore(): %v", err)
+	}
+	if got, want := c2.trustedRoot().TreeSize, int64(5); got != want {
+		t.Errorf("trusted TreeSize after restart: %v, want %v", got, want)
+	}
+}
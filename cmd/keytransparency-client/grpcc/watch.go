@@ -0,0 +1,343 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+// Identity is a watched entry.
+type Identity struct {
+	UserID string
+	AppID  string
+}
+
+// WatchedEntry is the last verified state of a watched entry.
+type WatchedEntry struct {
+	// Epoch is the epoch the entry has last been verified at.
+	Epoch int64 `json:"epoch"`
+	// LeafHash is the SHA256 hash of the map leaf of the entry.
+	LeafHash []byte `json:"leaf_hash"`
+	// Profile is the profile of the entry.
+	Profile []byte `json:"profile"`
+	// Authored are the leaf hashes of updates authored by this client which
+	// have not been observed yet.
+	Authored [][]byte `json:"authored,omitempty"`
+}
+
+// WatchStore persists the state of watched entries.
+type WatchStore interface {
+	// Get returns the state of id, or nil if id has not been watched yet.
+	Get(id Identity) (*WatchedEntry, error)
+	// Update replaces the state of id with the state returned by fn, which
+	// is called with the current state of id, or nil. Updates are atomic,
+	// also with respect to other processes sharing the store. The state is
+	// left unchanged if fn returns nil or an error.
+	Update(id Identity, fn func(e *WatchedEntry) (*WatchedEntry, error)) error
+}
+
+// Change is a change of a watched entry.
+type Change struct {
+	Identity
+	// Epoch is the epoch the change has been observed at.
+	Epoch int64
+	// OldProfile and NewProfile are the profiles before and after the
+	// change.
+	OldProfile, NewProfile []byte
+	// Authored is set if the change has been authored by this client.
+	// Other changes have been made by someone else, possibly without
+	// consent of the user.
+	Authored bool
+}
+
+func leafHash(leaf []byte) []byte {
+	h := sha256.Sum256(leaf)
+	return h[:]
+}
+
+// RecordAuthored records that req has been authored by this client, such that
+// Poll does not report the resulting change as unexpected. It must be called
+// before req is sent.
+func RecordAuthored(store WatchStore, req *tpb.UpdateEntryRequest) error {
+	id := Identity{UserID: req.GetUserId(), AppID: req.GetAppId()}
+	return store.Update(id, func(e *WatchedEntry) (*WatchedEntry, error) {
+		if e == nil {
+			// The entry is not watched yet. Watching it starts at
+			// the current entry, which needs no record.
+			return nil, nil
+		}
+		e.Authored = append(e.Authored, leafHash(req.GetEntryUpdate().GetUpdate().GetKeyValue().GetValue()))
+		return e, nil
+	})
+}
+
+// Poll verifies every epoch of the watched entries since they have last been
+// verified and returns their changes in epoch order. Entries which have not
+// been watched before are verified at the current epoch without reporting a
+// change.
+func (c *Client) Poll(ctx context.Context, store WatchStore, ids []Identity, opts ...grpc.CallOption) ([]*Change, error) {
+	var changes []*Change
+	for _, id := range ids {
+		idChanges, err := c.poll(ctx, store, id, opts...)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, idChanges...)
+	}
+	return changes, nil
+}
+
+// version is the verified entry of a watched identity at an epoch.
+type version struct {
+	epoch    int64
+	leafHash []byte
+	profile  []byte
+}
+
+func (c *Client) poll(ctx context.Context, store WatchStore, id Identity, opts ...grpc.CallOption) ([]*Change, error) {
+	current, err := c.getEntry(ctx, id.UserID, id.AppID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	epoch := current.GetSmr().GetMapRevision()
+	e, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		// Start watching at the current entry.
+		return nil, store.Update(id, func(e *WatchedEntry) (*WatchedEntry, error) {
+			if e != nil {
+				// Another watcher started watching first.
+				return nil, nil
+			}
+			return &WatchedEntry{
+				Epoch:    epoch,
+				LeafHash: leafHash(current.GetLeafProof().GetLeaf().GetLeafValue()),
+				Profile:  current.GetCommitted().GetData(),
+			}, nil
+		})
+	}
+	if epoch <= e.Epoch {
+		return nil, nil
+	}
+
+	// Inspect every epoch such that no intermediate change is missed.
+	var versions []version
+	if err := c.listEntryHistory(ctx, id.UserID, id.AppID, e.Epoch+1, epoch, func(v *tpb.GetEntryResponse) error {
+		versions = append(versions, version{
+			epoch:    v.GetSmr().GetMapRevision(),
+			leafHash: leafHash(v.GetLeafProof().GetLeaf().GetLeafValue()),
+			profile:  v.GetCommitted().GetData(),
+		})
+		return nil
+	}, opts...); err != nil {
+		return nil, err
+	}
+
+	// Updates are recorded as authored before they are sent, so the state
+	// reloaded now knows about every authored update in versions, even if
+	// it has been recorded concurrently by another process.
+	var changes []*Change
+	if err := store.Update(id, func(e *WatchedEntry) (*WatchedEntry, error) {
+		if e == nil {
+			// The entry is no longer watched.
+			return nil, nil
+		}
+		changes = detectChanges(id, e, versions)
+		if e.Epoch < epoch {
+			e.Epoch = epoch
+		}
+		return e, nil
+	}); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// detectChanges advances e through versions and returns the changes of the
+// entry. Versions up to e.Epoch, e.g. processed by another watcher, are
+// skipped.
+func detectChanges(id Identity, e *WatchedEntry, versions []version) []*Change {
+	var changes []*Change
+	for _, v := range versions {
+		if v.epoch <= e.Epoch || bytes.Equal(v.leafHash, e.LeafHash) {
+			continue
+		}
+		change := &Change{
+			Identity:   id,
+			Epoch:      v.epoch,
+			OldProfile: e.Profile,
+			NewProfile: v.profile,
+		}
+		for i, a := range e.Authored {
+			if bytes.Equal(a, v.leafHash) {
+				change.Authored = true
+				e.Authored = append(e.Authored[:i], e.Authored[i+1:]...)
+				break
+			}
+		}
+		changes = append(changes, change)
+		e.LeafHash = v.leafHash
+		e.Profile = v.profile
+	}
+	return changes
+}
+
+// Watch polls the watched entries every period until ctx is done and calls
+// onChange for each change. Watching stops if onChange returns an error.
+func (c *Client) Watch(ctx context.Context, store WatchStore, ids []Identity, period time.Duration, onChange func(*Change) error, opts ...grpc.CallOption) error {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		changes, err := c.Poll(ctx, store, ids, opts...)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if err := onChange(change); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// FileWatchStore stores the state of watched entries in a JSON file. Updates
+// are serialized with a lock on a separate lock file, so several processes,
+// e.g. watch and post, can share the file.
+type FileWatchStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileWatchStore returns a WatchStore which stores the state of watched
+// entries in the file at path.
+func NewFileWatchStore(path string) *FileWatchStore {
+	return &FileWatchStore{path: path}
+}
+
+func key(id Identity) string {
+	return id.AppID + "/" + id.UserID
+}
+
+func (f *FileWatchStore) read() (map[string]*WatchedEntry, error) {
+	entries := make(map[string]*WatchedEntry)
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// lock locks the store against other goroutines and processes. The returned
+// function unlocks it.
+func (f *FileWatchStore) lock() (func(), error) {
+	f.mu.Lock()
+	lf, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	if err := syscall.Flock(int(lf.Fd()), syscall.LOCK_EX); err != nil {
+		lf.Close()
+		f.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		// Closing the file releases the lock.
+		lf.Close()
+		f.mu.Unlock()
+	}, nil
+}
+
+// Get reads the state of id from the file.
+func (f *FileWatchStore) Get(id Identity) (*WatchedEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return entries[key(id)], nil
+}
+
+// Set writes the state of id to the file.
+func (f *FileWatchStore) Set(id Identity, e *WatchedEntry) error {
+	return f.Update(id, func(*WatchedEntry) (*WatchedEntry, error) {
+		return e, nil
+	})
+}
+
+// Update reloads the state of id from the file and writes the state returned
+// by fn while holding the lock. The file is written to a temporary file first
+// so that a crash never leaves a partially written file behind.
+func (f *FileWatchStore) Update(id Identity, fn func(e *WatchedEntry) (*WatchedEntry, error)) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := f.read()
+	if err != nil {
+		return err
+	}
+	e, err := fn(entries[key(id)])
+	if err != nil || e == nil {
+		return err
+	}
+	entries[key(id)] = e
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+func TestFileWatchStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcc")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileWatchStore(filepath.Join(dir, "watch"))
+	alice := Identity{UserID: "alice@example.com", AppID: "pgp"}
+	bob := Identity{UserID: "bob@example.com", AppID: "pgp"}
+
+	if e, err := store.Get(alice); err != nil || e != nil {
+		t.Fatalf("Get(): %v, %v, want nil, nil", e, err)
+	}
+	want := &WatchedEntry{Epoch: 3, LeafHash: leafHash([]byte("leaf")), Profile: []byte("key")}
+	if err := store.Set(alice, want); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if err := store.Set(bob, &WatchedEntry{Epoch: 1}); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	got, err := NewFileWatchStore(filepath.Join(dir, "watch")).Get(alice)
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get(): %+v, want %+v", got, want)
+	}
+}
+
+func TestRecordAuthored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcc")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileWatchStore(filepath.Join(dir, "watch"))
+	id := Identity{UserID: "alice@example.com", AppID: "pgp"}
+	req := &tpb.UpdateEntryRequest{
+		UserId: id.UserID,
+		AppId:  id.AppID,
+		EntryUpdate: &tpb.EntryUpdate{
+			Update: &tpb.SignedKV{KeyValue: &tpb.KeyValue{Value: []byte("new leaf")}},
+		},
+	}
+
+	// Updates of unwatched entries are not recorded.
+	if err := RecordAuthored(store, req); err != nil {
+		t.Fatalf("RecordAuthored(): %v", err)
+	}
+	if e, err := store.Get(id); err != nil || e != nil {
+		t.Fatalf("Get(): %v, %v, want nil, nil", e, err)
+	}
+
+	if err := store.Set(id, &WatchedEntry{Epoch: 1}); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if err := RecordAuthored(store, req); err != nil {
+		t.Fatalf("RecordAuthored(): %v", err)
+	}
+	e, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if got, want := len(e.Authored), 1; got != want {
+		t.Fatalf("len(Authored): %v, want %v", got, want)
+	}
+	if got, want := e.Authored[0], leafHash([]byte("new leaf")); !bytes.Equal(got, want) {
+		t.Errorf("Authored[0]: %x, want %x", got, want)
+	}
+}
+
+func TestFileWatchStoreConcurrentUpdates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcc")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "watch")
+	id := Identity{UserID: "alice@example.com", AppID: "pgp"}
+	if err := NewFileWatchStore(path).Set(id, &WatchedEntry{Epoch: 1}); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+
+	// Every store stands for a separate process sharing the file.
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := &tpb.UpdateEntryRequest{
+				UserId: id.UserID,
+				AppId:  id.AppID,
+				EntryUpdate: &tpb.EntryUpdate{
+					Update: &tpb.SignedKV{KeyValue: &tpb.KeyValue{Value: []byte{byte(i)}}},
+				},
+			}
+			if err := RecordAuthored(NewFileWatchStore(path), req); err != nil {
+				t.Errorf("RecordAuthored(): %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	e, err := NewFileWatchStore(path).Get(id)
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if got, want := len(e.Authored), n; got != want {
+		t.Errorf("len(Authored): %v, want %v", got, want)
+	}
+}
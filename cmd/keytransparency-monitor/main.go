@@ -16,7 +16,9 @@ package main
 
 import (
 	"crypto/tls"
+	"database/sql"
 	"flag"
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
@@ -36,14 +38,21 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	cmon "github.com/google/keytransparency/core/monitor"
-	"github.com/google/keytransparency/impl/monitor/storage/bftkvst"
+	"github.com/google/keytransparency/core/monitor/storage"
 	kpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/keytransparency/core/witness"
 	"github.com/google/keytransparency/impl/monitor/client"
+	"github.com/google/keytransparency/impl/monitor/storage/bftkvst"
+	"github.com/google/keytransparency/impl/monitor/webhook"
 	spb "github.com/google/keytransparency/impl/proto/keytransparency_v1_service"
 	mopb "github.com/google/keytransparency/impl/proto/monitor_v1_service"
 	mupb "github.com/google/keytransparency/impl/proto/mutation_v1_service"
+	"github.com/google/keytransparency/impl/sql/engine"
+	"github.com/google/keytransparency/impl/sql/monitorst"
+	iwitness "github.com/google/keytransparency/impl/witness"
 	_ "github.com/google/trillian/merkle/coniks"    // Register coniks
 	_ "github.com/google/trillian/merkle/objhasher" // Register objhasher
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -53,16 +62,23 @@ var (
 
 	signingKey         = flag.String("sign-key", "genfiles/monitor_sign-key.pem", "Path to private key PEM for SMH signing")
 	signingKeyPassword = flag.String("password", "towel", "Password of the private key PEM file for SMH signing")
-	ktURL              = flag.String("kt-url", "localhost:8080", "URL of key-server.")
+	ktURLs             = flag.String("kt-url", "localhost:8080", "Comma separated list of URLs of the key-servers to monitor.")
 	insecure           = flag.Bool("insecure", false, "Skip TLS checks")
 	ktCert             = flag.String("kt-cert", "genfiles/server.crt", "Path to kt-server's public key")
 
 	pollPeriod = flag.Duration("poll-period", time.Second*5, "Maximum time between polling the key-server. Ideally, this is equal to the min-period of paramerter of the keyserver.")
 
-	bftkvKeyPath       = flag.String("bftkv", "genfiles/u01", "Path to BFTKV keyrings")
+	storageType  = flag.String("storage", "bftkv", "Storage of the monitoring results. Accepted values are bftkv and sql.")
+	bftkvKeyPath = flag.String("bftkv", "genfiles/u01", "Path to BFTKV keyrings")
+	serverDBPath = flag.String("db", "test:zaphod@tcp(localhost:3306)/test", "Database connection string of the sql storage")
 
-	// TODO(ismail): expose prometheus metrics: a variable that tracks valid/invalid MHs
-	// metricsAddr = flag.String("metrics-addr", ":8081", "The ip:port to publish metrics on")
+	witnessURL     = flag.String("witness-url", "", "URL of the BFTKV HTTP front end the sequencer publishes map roots to, e.g. http://localhost:6001. Map roots are not compared if empty.")
+	witnessTimeout = flag.Duration("witness-timeout", 5*time.Second, "Timeout of a single request to the witness")
+	witnessRetries = flag.Int("witness-retries", 3, "Number of times a failed request to the witness is retried")
+	alertURL       = flag.String("alert-url", "", "URL alerts are posted to as JSON, e.g. when a map root differs from the witnessed root. Alerts are only logged if empty.")
+	alertTimeout   = flag.Duration("alert-timeout", 5*time.Second, "Timeout of posting an alert")
+
+	metricsAddr = flag.String("metrics-addr", ":8081", "The ip:port to publish metrics on")
 )
 
 func grpcGatewayMux(addr string) (*runtime.ServeMux, error) {
@@ -109,26 +125,43 @@ func main() {
 		grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptor),
 	)
 
-	// Connect to the kt-server's mutation API:
-	grpcc, err := dial()
-	if err != nil {
-		glog.Fatalf("Error Dialing %v: %v", ktURL, err)
-	}
-	mcc := mupb.NewMutationServiceClient(grpcc)
-
 	// Read signing key:
 	key, err := pem.ReadPrivateKeyFile(*signingKey, *signingKeyPassword)
 	if err != nil {
 		glog.Fatalf("Could not create signer from %v: %v", *signingKey, err)
 	}
+	signer := crypto.NewSHA256Signer(key)
+	var wit witness.Witness
+	if *witnessURL != "" {
+		wit = iwitness.New(*witnessURL, *witnessTimeout, *witnessRetries)
+	}
+	var alerter cmon.Alerter
+	if *alertURL != "" {
+		alerter = webhook.New(*alertURL, *alertTimeout)
+	}
+
+	// The sql storages of all key servers share one database.
+	var db *sql.DB
+	if *storageType == "sql" {
+		db, err = openDB()
+		if err != nil {
+			glog.Exitf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+	}
+
+	// Monitor every key server and keep its results in a separate storage.
 	ctx := context.Background()
-	logTree, mapTree, err := getTrees(ctx, grpcc)
-	if err != nil {
-		glog.Fatalf("Could not read domain info %v:", err)
+	storages := make(map[string]storage.Storage)
+	for _, url := range strings.Split(*ktURLs, ",") {
+		store, err := startMonitoring(ctx, url, db, signer, wit, alerter)
+		if err != nil {
+			glog.Exitf("Failed to monitor %v: %v", url, err)
+		}
+		storages[url] = store
 	}
 
-	store := bftkvst.New(*bftkvKeyPath)
-	srv := monitor.New(store)
+	srv := monitor.New(storages)
 	mopb.RegisterMonitorServiceServer(grpcServer, srv)
 	reflection.Register(grpcServer)
 	grpc_prometheus.Register(grpcServer)
@@ -144,19 +177,56 @@ func main() {
 	mux := http.NewServeMux()
 	mux.Handle("/", gwmux)
 
+	metricMux := http.NewServeMux()
+	metricMux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(*metricsAddr, metricMux); err != nil {
+			glog.Fatalf("ListenAndServe(%v): %v", *metricsAddr, err)
+		}
+	}()
+
+	// Serve HTTP2 server over TLS.
+	glog.Infof("Listening on %v", *addr)
+	if err := http.ListenAndServeTLS(*addr, *certFile, *keyFile,
+		grpcHandlerFunc(grpcServer, mux)); err != nil {
+		glog.Errorf("ListenAndServeTLS: %v", err)
+	}
+}
+
+// startMonitoring connects to the key server at ktURL and starts polling and
+// verifying its mutations. It returns the storage of the monitoring results.
+func startMonitoring(ctx context.Context, ktURL string, db *sql.DB, signer *crypto.Signer,
+	wit witness.Witness, alerter cmon.Alerter) (storage.Storage, error) {
+	// Connect to the kt-server's mutation API:
+	grpcc, err := dial(ktURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error Dialing %v: %v", ktURL, err)
+	}
+	mcc := mupb.NewMutationServiceClient(grpcc)
+	logTree, mapTree, err := getTrees(ctx, grpcc)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read domain info: %v", err)
+	}
+
+	store, err := openStorage(db, ktURL, mapTree.GetTreeId())
+	if err != nil {
+		return nil, err
+	}
+
 	// initialize the mutations API client and feed the responses it got
 	// into the monitor:
-	mon, err := cmon.New(logTree, mapTree, crypto.NewSHA256Signer(key), store)
+	mon, err := cmon.New(mcc, logTree, mapTree, signer, store, wit, alerter)
 	if err != nil {
-		glog.Exitf("Failed to initialize monitor: %v", err)
+		return nil, fmt.Errorf("Failed to initialize monitor: %v", err)
 	}
 	mutCli := client.New(mcc, *pollPeriod)
-	responses, errs := mutCli.StartPolling(1)
+	// Resume after the latest epoch processed before a restart.
+	responses, errs := mutCli.StartPolling(store.LatestEpoch() + 1)
 	go func() {
 		for {
 			select {
 			case mutResp := <-responses:
-				glog.Infof("Received mutations response: %v", mutResp.Epoch)
+				glog.Infof("Received mutations response from %v: %v", ktURL, mutResp.Epoch)
 				if err := mon.Process(mutResp); err != nil {
 					glog.Infof("Error processing mutations response: %v", err)
 				}
@@ -164,30 +234,49 @@ func main() {
 				// this is OK if there were no mutations in  between:
 				// TODO(ismail): handle the case when the known maxDuration has
 				// passed and no epoch was issued?
-				glog.Infof("Could not retrieve mutations API response %v", err)
+				glog.Infof("Could not retrieve mutations API response from %v: %v", ktURL, err)
 			}
 		}
 	}()
+	return store, nil
+}
 
-	// Serve HTTP2 server over TLS.
-	glog.Infof("Listening on %v", *addr)
-	if err := http.ListenAndServeTLS(*addr, *certFile, *keyFile,
-		grpcHandlerFunc(grpcServer, mux)); err != nil {
-		glog.Errorf("ListenAndServeTLS: %v", err)
+// openStorage opens the storage of the results of the key server at ktURL.
+// Results are kept in db if the storage is sql.
+func openStorage(db *sql.DB, ktURL string, mapID int64) (storage.Storage, error) {
+	switch *storageType {
+	case "bftkv":
+		return bftkvst.New(*bftkvKeyPath, ktURL), nil
+	case "sql":
+		return monitorst.New(db, ktURL, mapID)
+	default:
+		return nil, fmt.Errorf("Invalid storage parameter: %v", *storageType)
+	}
+}
+
+// openDB opens the database of the sql storage.
+func openDB() (*sql.DB, error) {
+	db, err := sql.Open(engine.DriverName, *serverDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open(): %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("db.Ping(): %v", err)
 	}
+	return db, nil
 }
 
-func dial() (*grpc.ClientConn, error) {
+func dial(ktURL string) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 
-	transportCreds, err := transportCreds(*ktURL, *ktCert, *insecure)
+	transportCreds, err := transportCreds(ktURL, *ktCert, *insecure)
 	if err != nil {
 		return nil, err
 	}
 	opts = append(opts, grpc.WithTransportCredentials(transportCreds))
 
 	// TODO(ismail): authenticate the monitor to the kt-server:
-	cc, err := grpc.Dial(*ktURL, opts...)
+	cc, err := grpc.Dial(ktURL, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -17,14 +17,25 @@ package main
 import (
 	"database/sql"
 	"flag"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/google/keytransparency/core/election"
+	"github.com/google/keytransparency/core/mutation"
 	"github.com/google/keytransparency/core/mutator/entry"
 	"github.com/google/keytransparency/core/sequencer"
+	"github.com/google/keytransparency/core/witness"
 
 	"github.com/google/keytransparency/impl/sql/engine"
+	"github.com/google/keytransparency/impl/sql/leases"
 	"github.com/google/keytransparency/impl/sql/mutations"
+	"github.com/google/keytransparency/impl/sql/unwitnessed"
 	"github.com/google/keytransparency/impl/transaction"
 
 	"github.com/golang/glog"
@@ -33,19 +44,40 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+
+	spb "github.com/google/keytransparency/impl/proto/sequencer_v1_service"
+	isequencer "github.com/google/keytransparency/impl/sequencer"
+	iwitness "github.com/google/keytransparency/impl/witness"
 )
 
 var (
+	addr             = flag.String("addr", "", "The ip:port to serve the sequencer API on. The API is not served if empty.")
+	keyFile          = flag.String("tls-key", "", "TLS private key file. The API is served without TLS if empty.")
+	certFile         = flag.String("tls-cert", "", "TLS cert file. The API is served without TLS if empty.")
 	metricsAddr      = flag.String("metrics-addr", ":8081", "The ip:port to publish metrics on")
 	serverDBPath     = flag.String("db", "db", "Database connection string")
 	minEpochDuration = flag.Duration("min-period", time.Second*60, "Minimum time between epoch creation (create epochs only if there where mutations). Expected to be smaller than max-period.")
 	maxEpochDuration = flag.Duration("max-period", time.Hour*12, "Maximum time between epoch creation (independent from mutations). This value should about half the time guaranteed by the policy.")
+	maxMutations     = flag.Int("max-mutations", 10000, "Maximum number of mutations in one epoch. Larger backlogs are processed in several consecutive epochs.")
+	followPeriod     = flag.Duration("follow-period", time.Second, "How often the map is polled for epochs created by other replicas, which are then served on the sequencer API")
 
 	// Info to connect to the trillian map and log.
 	mapID  = flag.Int64("map-id", 0, "ID for backend map")
 	mapURL = flag.String("map-url", "", "URL of Trilian Map Server")
 	logID  = flag.Int64("log-id", 0, "Trillian Log ID")
 	logURL = flag.String("log-url", "", "URL of Trillian Log Server for Signed Map Heads")
+
+	// Info to publish map roots to a witness.
+	witnessURL     = flag.String("witness-url", "", "URL of the BFTKV HTTP front end map roots are published to, e.g. http://localhost:6001. Map roots are not published if empty.")
+	witnessTimeout = flag.Duration("witness-timeout", 5*time.Second, "Timeout of a single request to the witness")
+	witnessRetries = flag.Int("witness-retries", 3, "Number of times a failed request to the witness is retried")
+
+	// Leader election among sequencer replicas.
+	leaderElection = flag.String("leader-election", "none", "Elects the replica creating epochs: none (a single replica runs) or sql (leases in the database)")
+	electionID     = flag.String("election-id", "", "Unique ID of this replica in the leader election. Defaults to hostname and process ID")
+	electionTTL    = flag.Duration("election-ttl", 10*time.Second, "Time after which the lease of a failed master expires and another replica takes over")
 )
 
 func openDB() *sql.DB {
@@ -59,12 +91,72 @@ func openDB() *sql.DB {
 	return db
 }
 
+// newElection returns the election of the replica creating epochs, or nil if
+// a single replica runs.
+func newElection(db *sql.DB) *election.Election {
+	switch *leaderElection {
+	case "none":
+		return nil
+	case "sql":
+		store, err := leases.New(db)
+		if err != nil {
+			glog.Exitf("leases.New(): %v", err)
+		}
+		id := *electionID
+		if id == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				glog.Exitf("os.Hostname(): %v", err)
+			}
+			id = fmt.Sprintf("%v-%v", hostname, os.Getpid())
+		}
+		return election.New(store, fmt.Sprintf("sequencer/%v", *mapID), id, *electionTTL)
+	default:
+		glog.Exitf("Unknown leader election %q", *leaderElection)
+		return nil
+	}
+}
+
+// serveAPI serves the sequencer API on addr, using TLS if a certificate and
+// key are provided.
+func serveAPI(srv spb.SequencerServiceServer) {
+	var opts []grpc.ServerOption
+	switch {
+	case *certFile != "" && *keyFile != "":
+		creds, err := credentials.NewServerTLSFromFile(*certFile, *keyFile)
+		if err != nil {
+			glog.Exitf("Failed to load server credentials %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	case *certFile != "" || *keyFile != "":
+		glog.Exitf("tls-cert and tls-key must be provided together")
+	default:
+		glog.Warningf("Serving the sequencer API on %v without TLS", *addr)
+	}
+	grpcServer := grpc.NewServer(opts...)
+	spb.RegisterSequencerServiceServer(grpcServer, srv)
+	reflection.Register(grpcServer)
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		glog.Exitf("net.Listen(%v): %v", *addr, err)
+	}
+	go func() {
+		glog.Infof("Listening on %v", *addr)
+		if err := grpcServer.Serve(lis); err != nil {
+			glog.Errorf("grpcServer.Serve(%v): %v", *addr, err)
+		}
+	}()
+}
+
 func main() {
 	flag.Parse()
 
 	// Flag validation.
 	if *maxEpochDuration < *minEpochDuration {
-		glog.Exitf("maxEpochDuration < minEpochDuration: %v < %v, want maxEpochDuration >= minEpochDuration")
+		glog.Exitf("maxEpochDuration < minEpochDuration: %v < %v, want maxEpochDuration >= minEpochDuration", *maxEpochDuration, *minEpochDuration)
+	}
+	if *maxMutations <= 0 || *maxMutations > math.MaxInt32 {
+		glog.Exitf("max-mutations: %v, want 0 < max-mutations <= %v", *maxMutations, math.MaxInt32)
 	}
 
 	sqldb := openDB()
@@ -100,8 +192,53 @@ func main() {
 		}
 	}()
 
-	signer := sequencer.New(*mapID, tmap, *logID, tlog, mutator, mutations, factory)
-	glog.Infof("Signer starting")
-	signer.StartSigning(context.Background(), *minEpochDuration, *maxEpochDuration)
+	var wit witness.Witness
+	if *witnessURL != "" {
+		wit = iwitness.New(*witnessURL, *witnessTimeout, *witnessRetries)
+	}
+
+	signer := sequencer.New(*mapID, tmap, *logID, tlog, mutator, mutations, factory, wit, int32(*maxMutations))
+	if wit != nil {
+		// Keep the roots which have yet to be published to the witness
+		// in the database, such that the next master publishes them.
+		pending, err := unwitnessed.New(sqldb)
+		if err != nil {
+			glog.Exitf("unwitnessed.New(): %v", err)
+		}
+		signer.SetPendingRoots(pending)
+	}
+
+	// Stop signing on shutdown, such that the master resigns and another
+	// replica takes over immediately.
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		glog.Infof("Received signal %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	// Serve newly created epochs. Every replica follows the map, such
+	// that replicas which are not the master serve the epochs created by
+	// the master.
+	if *addr != "" {
+		msrv := mutation.New(*logID, *mapID, tlog, tmap, mutations, factory)
+		serveAPI(isequencer.New(signer, msrv))
+		go signer.Follow(ctx, *followPeriod)
+	}
+
+	sign := func(ctx context.Context) {
+		glog.Infof("Signer starting")
+		signer.StartSigning(ctx, *minEpochDuration, *maxEpochDuration)
+		glog.Infof("Signer stopped")
+	}
+	if e := newElection(sqldb); e != nil {
+		glog.Infof("Campaigning for mastership")
+		if err := e.Run(ctx, sign); err != nil {
+			glog.Infof("Leader election ended: %v", err)
+		}
+	} else {
+		sign(ctx)
+	}
 	glog.Errorf("Signer exiting")
 }
@@ -15,14 +15,20 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"flag"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/google/keytransparency/core/authentication"
+	cauthz "github.com/google/keytransparency/core/authorization"
+	"github.com/google/keytransparency/core/crypto/keymaster"
 	"github.com/google/keytransparency/core/crypto/vrf"
 	"github.com/google/keytransparency/core/crypto/vrf/p256"
 	"github.com/google/keytransparency/core/keyserver"
@@ -46,11 +52,17 @@ import (
 
 	cmutation "github.com/google/keytransparency/core/mutation"
 	gauth "github.com/google/keytransparency/impl/google/authentication"
+	mtlsauth "github.com/google/keytransparency/impl/mtls/authentication"
+	oidcauth "github.com/google/keytransparency/impl/oidc/authentication"
 	ktpb "github.com/google/keytransparency/impl/proto/keytransparency_v1_service"
 	mpb "github.com/google/keytransparency/impl/proto/mutation_v1_service"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 )
 
+// adminKeysPassphraseEnv is the environment variable containing the passphrase
+// of an encrypted admin key set.
+const adminKeysPassphraseEnv = "KT_ADMIN_KEYS_PASSPHRASE"
+
 var (
 	addr         = flag.String("addr", ":8080", "The ip:port combination to listen on")
 	metricsAddr  = flag.String("metrics-addr", ":8081", "The ip:port to publish metrics on")
@@ -58,7 +70,28 @@ var (
 	vrfPath      = flag.String("vrf", "genfiles/vrf-key.pem", "Path to VRF private key")
 	keyFile      = flag.String("tls-key", "genfiles/server.key", "TLS private key file")
 	certFile     = flag.String("tls-cert", "genfiles/server.crt", "TLS cert file")
-	authType     = flag.String("auth-type", "google", "Sets the type of authentication required from clients to update their entries. Accepted values are google (oauth tokens) and insecure-fake (for testing only).")
+	authType     = flag.String("auth-type", "google", "Sets the type of authentication required from clients to update their entries. Accepted values are google (oauth tokens), oidc (locally validated ID tokens or JWTs), mtls (TLS client certificates) and insecure-fake (for testing only).")
+	oidcIssuer   = flag.String("oidc-issuer", "", "Required issuer of tokens with --auth-type=oidc")
+	oidcAudience = flag.String("oidc-audience", "", "Required audience of tokens with --auth-type=oidc")
+	oidcJWKS     = flag.String("oidc-jwks", "", "Path or URL of the JSON Web Key Set of the issuer with --auth-type=oidc")
+	oidcIdentity = flag.String("oidc-identity-claim", "email", "Claim used as user identity with --auth-type=oidc")
+	oidcClaims   = flag.String("oidc-required-claims", "email_verified=true", "Comma separated claim=value pairs required with --auth-type=oidc")
+	oidcSkew     = flag.Duration("oidc-clock-skew", time.Minute, "Tolerated clock skew when checking token expiry with --auth-type=oidc")
+	mtlsClientCA = flag.String("mtls-client-ca", "", "CA cert file used to verify client certificates with --auth-type=mtls")
+	mtlsRules    = flag.String("mtls-rules", "email:^(.+)$=$1", "Comma separated field:pattern=identity rules mapping client certificates to identities with --auth-type=mtls. Field is one of email, uri, dns or cn. The first matching rule is used.")
+	adminKeys    = flag.String("admin-keys", "", "Path to the serialized key set used by the admin API to update entries. The admin API is disabled if empty.")
+	authzPolicy  = flag.String("authz-policy", "", "Path to the authorization policy as text proto, or JSON if the file ends in .json. The policy is reloaded on SIGHUP and when the file changes. If empty, users may only update their own entries.")
+	authzPeriod  = flag.Duration("authz-policy-period", 10*time.Second, "How often to check the authorization policy file for changes")
+	validators   = flag.String("app-validators", "pgp=pgp", "Comma separated appID=validator pairs. Validators are pgp, ssh (authorized_keys line), x509 (certificate with the user's email), pubkey:<type> (raw ed25519, x25519, signal, p256 or pkix key) and opaque.")
+	rejectApps   = flag.Bool("reject-unknown-apps", false, "Reject profiles of apps not listed in --app-validators")
+	pgpAlgos     = flag.String("pgp-key-algos", "ecdsa,eddsa", "Comma separated primary key algorithms accepted by the pgp validator: rsa, dsa, ecdsa or eddsa (Ed25519)")
+	pgpSubAlgos  = flag.String("pgp-subkey-algos", "ecdh", "Comma separated subkey algorithms accepted by the pgp validator: rsa, elgamal or ecdh (NIST curves and Curve25519)")
+	pgpRSABits   = flag.Int("pgp-min-rsa-bits", 2048, "Minimum size of RSA keys accepted by the pgp validator")
+	pgpExpiry    = flag.Bool("pgp-require-expiry", false, "Require PGP keys to expire")
+	pgpLifetime  = flag.Duration("pgp-max-lifetime", 0, "Maximum lifetime of PGP keys, e.g. 17520h for two years. Zero means unlimited.")
+	pgpUIDMatch  = flag.String("pgp-uid-match", "exact", "How PGP user IDs are matched against the user: exact (a single <user> ID), email (all IDs have the user's email) or domain (other IDs must be in the user's domain)")
+	readAccess   = flag.Bool("enforce-read", false, "Require lookups to be authenticated and authorized with the READ permission")
+	auditLog     = flag.String("audit-log", "", "Path of the file lookups of resources with the LOG permission are audited to. Audit records are written to the server log if empty. Only used with --enforce-read.")
 
 	// Info to connect to sparse merkle tree database.
 	mapID  = flag.Int64("map-id", 0, "ID for backend map")
@@ -92,6 +125,121 @@ func openVRFKey() vrf.PrivateKey {
 	return vrfPriv
 }
 
+func openAdminKeys() *keymaster.KeyMaster {
+	buf, err := ioutil.ReadFile(*adminKeys)
+	if err != nil {
+		glog.Exitf("Failed opening admin key set: %v", err)
+	}
+	keys := keymaster.New()
+	// Encrypted key sets, as written by keytransparency-client, are
+	// decrypted with the passphrase in adminKeysPassphraseEnv.
+	passphrase := []byte(os.Getenv(adminKeysPassphraseEnv))
+	if err := keymaster.UnmarshalEncrypted(buf, passphrase, keys); err != nil {
+		glog.Exitf("Failed parsing admin key set: %v", err)
+	}
+	return keys
+}
+
+// parseClaims parses comma separated claim=value pairs.
+func parseClaims(s string) map[string]string {
+	claims := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if kv == "" {
+			continue
+		}
+		p := strings.SplitN(kv, "=", 2)
+		if len(p) != 2 {
+			glog.Exitf("Invalid required claim %q, want claim=value", kv)
+		}
+		claims[p[0]] = p[1]
+	}
+	return claims
+}
+
+func pgpPolicy() *keyserver.PGPPolicy {
+	policy := keyserver.DefaultPGPPolicy()
+	var err error
+	if policy.KeyAlgos, err = keyserver.ParsePGPAlgos(*pgpAlgos); err != nil {
+		glog.Exitf("Invalid pgp-key-algos: %v", err)
+	}
+	if policy.SubkeyAlgos, err = keyserver.ParsePGPAlgos(*pgpSubAlgos); err != nil {
+		glog.Exitf("Invalid pgp-subkey-algos: %v", err)
+	}
+	if policy.UIDMatch, err = keyserver.ParseUIDMatch(*pgpUIDMatch); err != nil {
+		glog.Exitf("Invalid pgp-uid-match: %v", err)
+	}
+	policy.MinRSABits = *pgpRSABits
+	policy.RequireExpiry = *pgpExpiry
+	policy.MaxLifetime = *pgpLifetime
+	return policy
+}
+
+func parseMTLSRules(s string) []*mtlsauth.Rule {
+	var rules []*mtlsauth.Rule
+	for _, r := range strings.Split(s, ",") {
+		if r == "" {
+			continue
+		}
+		rule, err := mtlsauth.ParseRule(r)
+		if err != nil {
+			glog.Exitf("Invalid mTLS rule: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		glog.Exitf("At least one mTLS rule is required")
+	}
+	return rules
+}
+
+// tlsConfig returns the TLS config of the server. Client certificates are
+// requested and verified against --mtls-client-ca with --auth-type=mtls.
+// Clients without certificate may still connect for unauthenticated calls.
+func tlsConfig() *tls.Config {
+	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		glog.Exitf("Failed to load server credentials %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if *authType != "mtls" {
+		return config
+	}
+	pem, err := ioutil.ReadFile(*mtlsClientCA)
+	if err != nil {
+		glog.Exitf("Failed to read client CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		glog.Exitf("No certificates found in client CA %v", *mtlsClientCA)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config
+}
+
+func openAuthz() cauthz.Authorization {
+	if *authzPolicy == "" {
+		return authorization.New()
+	}
+	authz, err := authorization.NewFromFile(*authzPolicy)
+	if err != nil {
+		glog.Exitf("Failed to load authorization policy: %v", err)
+	}
+	go authz.Watch(context.Background(), *authzPeriod)
+	return authz
+}
+
+func openAuditor() cauthz.Auditor {
+	if *auditLog == "" {
+		return authorization.NewLogAuditor()
+	}
+	f, err := os.OpenFile(*auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		glog.Exitf("Failed to open audit log: %v", err)
+	}
+	return authorization.NewJSONAuditor(f)
+}
+
 func grpcGatewayMux(addr string) (*runtime.ServeMux, error) {
 	ctx := context.Background()
 
@@ -108,6 +256,11 @@ func grpcGatewayMux(addr string) (*runtime.ServeMux, error) {
 	if err := mpb.RegisterMutationServiceHandlerFromEndpoint(ctx, gwmux, addr, dopts); err != nil {
 		return nil, err
 	}
+	if *adminKeys != "" {
+		if err := ktpb.RegisterKeyTransparencyAdminServiceHandlerFromEndpoint(ctx, gwmux, addr, dopts); err != nil {
+			return nil, err
+		}
+	}
 
 	return gwmux, nil
 }
@@ -150,10 +303,25 @@ func main() {
 		if err != nil {
 			glog.Exitf("Failed to create authentication library instance: %v", err)
 		}
+	case "oidc":
+		var err error
+		auth, err = oidcauth.New(oidcauth.Config{
+			Issuer:         *oidcIssuer,
+			Audience:       *oidcAudience,
+			JWKS:           *oidcJWKS,
+			IdentityClaim:  *oidcIdentity,
+			RequiredClaims: parseClaims(*oidcClaims),
+			ClockSkew:      *oidcSkew,
+		})
+		if err != nil {
+			glog.Exitf("Failed to create authentication library instance: %v", err)
+		}
+	case "mtls":
+		auth = mtlsauth.New(parseMTLSRules(*mtlsRules))
 	default:
 		glog.Exitf("Invalid auth-type parameter: %v.", *authType)
 	}
-	authz := authorization.New()
+	authz := openAuthz()
 
 	// Create database and helper objects.
 	commitments, err := commitments.New(sqldb, *mapID)
@@ -185,6 +353,14 @@ func main() {
 	// Create gRPC server.
 	svr := keyserver.New(*logID, tlog, *mapID, tmap, tadmin, commitments,
 		vrfPriv, mutator, auth, authz, factory, mutations)
+	appValidators, err := keyserver.ParseValidators(*validators, *rejectApps, pgpPolicy())
+	if err != nil {
+		glog.Exitf("Invalid app-validators: %v", err)
+	}
+	svr.SetValidators(appValidators)
+	if *readAccess {
+		svr.EnforceReadAccess(openAuditor())
+	}
 	grpcServer := grpc.NewServer(
 		grpc.Creds(creds),
 		grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptor),
@@ -193,6 +369,9 @@ func main() {
 	msrv := mutation.New(cmutation.New(*logID, *mapID, tlog, tmap, mutations, factory))
 	ktpb.RegisterKeyTransparencyServiceServer(grpcServer, svr)
 	mpb.RegisterMutationServiceServer(grpcServer, msrv)
+	if *adminKeys != "" {
+		ktpb.RegisterKeyTransparencyAdminServiceServer(grpcServer, keyserver.NewAdminServer(svr, openAdminKeys()))
+	}
 	reflection.Register(grpcServer)
 	grpc_prometheus.Register(grpcServer)
 	grpc_prometheus.EnableHandlingTimeHistogram()
@@ -217,8 +396,12 @@ func main() {
 	}()
 	// Serve HTTP2 server over TLS.
 	glog.Infof("Listening on %v", *addr)
-	if err := http.ListenAndServeTLS(*addr, *certFile, *keyFile,
-		grpcHandlerFunc(grpcServer, mux)); err != nil {
+	server := &http.Server{
+		Addr:      *addr,
+		Handler:   grpcHandlerFunc(grpcServer, mux),
+		TLSConfig: tlsConfig(),
+	}
+	if err := server.ListenAndServeTLS("", ""); err != nil {
 		glog.Errorf("ListenAndServeTLS: %v", err)
 	}
 }
@@ -0,0 +1,45 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authorization
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// AccessRecord describes an access to a user's profile.
+type AccessRecord struct {
+	// Time is the time of the access.
+	Time time.Time `json:"time"`
+	// Method is the RPC used to access the profile.
+	Method string `json:"method"`
+	// Caller is the authenticated identity of the caller.
+	Caller string `json:"caller"`
+	// MapID, AppID and UserID identify the accessed profile.
+	MapID  int64  `json:"map_id"`
+	AppID  string `json:"app_id"`
+	UserID string `json:"user_id"`
+	// Epoch is the epoch at which the profile was read.
+	Epoch int64 `json:"epoch"`
+}
+
+// Auditor records accesses to resources whose policy carries the LOG
+// permission.
+type Auditor interface {
+	// Audit records an access. Accesses which cannot be recorded are
+	// denied.
+	Audit(ctx context.Context, record *AccessRecord) error
+}
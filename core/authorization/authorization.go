@@ -27,4 +27,7 @@ type Authorization interface {
 	// (from ctx) is authorized to carry the given permission.
 	IsAuthorized(ctx *authentication.SecurityContext, mapID int64,
 		appID, userID string, permission authzpb.Permission) error
+	// IsLogged returns whether accesses to the resource defined by mapID
+	// and appID are to be logged, irrespective of the caller.
+	IsLogged(mapID int64, appID string) bool
 }
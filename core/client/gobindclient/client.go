@@ -32,11 +32,13 @@ import (
 	"github.com/google/keytransparency/core/client/multiWriter"
 
 	"github.com/benlaurie/objecthash/go/objecthash"
+	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
 	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
 	spb "github.com/google/keytransparency/impl/proto/keytransparency_v1_service"
+	"github.com/google/trillian"
 	_ "github.com/google/trillian/merkle/coniks"    // Register coniks
 	_ "github.com/google/trillian/merkle/objhasher" // Used to init the package so that the hasher gets registered
 )
@@ -44,6 +46,8 @@ import (
 var (
 	clients = make(map[string]*grpcc.Client)
 
+	rootStore TrustedRootStore
+
 	timeout = 500 * time.Millisecond
 
 	multiLogWriter = multiWriter.New(os.Stderr)
@@ -67,6 +71,47 @@ type LogWriter interface {
 	Write(p []byte) (n int, err error)
 }
 
+// TrustedRootStore persists the serialized trusted log root of each KtServer
+// across restarts of the application. It can be implemented in Java.
+type TrustedRootStore interface {
+	// Load returns the stored log root of ktURL, or nil if there is none.
+	Load(ktURL string) ([]byte, error)
+	// Store replaces the stored log root of ktURL.
+	Store(ktURL string, root []byte) error
+}
+
+// SetTrustedRootStore sets the store used to persist the trusted log roots of
+// KtServers added after the call.
+func SetTrustedRootStore(store TrustedRootStore) {
+	rootStore = store
+}
+
+// rootStoreAdapter adapts a TrustedRootStore to grpcc.TrustedRootStore.
+type rootStoreAdapter struct {
+	store TrustedRootStore
+	ktURL string
+}
+
+func (a *rootStoreAdapter) Get() (*trillian.SignedLogRoot, error) {
+	b, err := a.store.Load(a.ktURL)
+	if err != nil {
+		return nil, err
+	}
+	root := new(trillian.SignedLogRoot)
+	if err := proto.Unmarshal(b, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (a *rootStoreAdapter) Set(root *trillian.SignedLogRoot) error {
+	b, err := proto.Marshal(root)
+	if err != nil {
+		return err
+	}
+	return a.store.Store(a.ktURL, b)
+}
+
 // SetTimeout sets the timeout (in milliseconds) used for all rpc network requests.
 func SetTimeout(ms int32) {
 	timeout = time.Duration(ms) * time.Millisecond
@@ -106,6 +151,13 @@ func AddKtServer(ktURL string, insecureTLS bool, ktTLSCertPEM []byte, domainInfo
 	if err != nil {
 		return fmt.Errorf("Error adding the KtServer: %v", err)
 	}
+	if rootStore != nil {
+		if err := client.SetTrustedRootStore(&rootStoreAdapter{store: rootStore, ktURL: ktURL}); err != nil {
+			return fmt.Errorf("Error loading the trusted log root: %v", err)
+		}
+	} else {
+		Vlog.Print("Warning: no TrustedRootStore set. The trusted log root will not persist across restarts.")
+	}
 
 	clients[ktURL] = client
 	return nil
@@ -72,6 +72,9 @@ func New(vrf vrf.PublicKey,
 //  - Verify signature.
 //  - Verify consistency proof from log.Root().
 //  - Verify inclusion proof.
+// trusted must be the log root whose tree size was sent as first_tree_size in
+// the request. trusted is not modified; callers advance their trusted root to
+// in.LogRoot once the response has been verified.
 func (v *Verifier) VerifyGetEntryResponse(ctx context.Context, userID, appID string,
 	trusted *trillian.SignedLogRoot, in *tpb.GetEntryResponse) error {
 	// Unpack the merkle tree leaf value.
@@ -132,15 +135,14 @@ func (v *Verifier) VerifyGetEntryResponse(ctx context.Context, userID, appID str
 		return fmt.Errorf("VerifyRoot(%v, %v): %v", in.GetLogRoot(), in.GetLogConsistency(), err)
 	}
 	Vlog.Printf("✓ Log root updated.")
-	trusted = in.GetLogRoot()
 
-	// Verify inclusion proof.
+	// Verify inclusion proof in the new log root.
 	b, err := json.Marshal(in.GetSmr())
 	if err != nil {
 		return fmt.Errorf("json.Marshal(): %v", err)
 	}
 	logLeafIndex := in.GetSmr().GetMapRevision()
-	if err := v.logVerifier.VerifyInclusionAtIndex(trusted, b, logLeafIndex,
+	if err := v.logVerifier.VerifyInclusionAtIndex(in.GetLogRoot(), b, logLeafIndex,
 		in.GetLogInclusion()); err != nil {
 		return fmt.Errorf("VerifyInclusionAtIndex(%s, %v, _): %v",
 			b, in.GetSmr().GetMapRevision(), err)
@@ -14,12 +14,19 @@
 
 package commitments
 
-import "golang.org/x/net/context"
+import (
+	"github.com/google/keytransparency/core/transaction"
+
+	"golang.org/x/net/context"
+)
 
 // Committer saves cryptographic commitments.
 type Committer interface {
 	// Write saves a cryptographic commitment and associated data.
 	Write(ctx context.Context, commitment, data, nonce []byte) error
+	// WriteTxn saves a cryptographic commitment and associated data as part
+	// of txn.
+	WriteTxn(txn transaction.Txn, commitment, data, nonce []byte) error
 	// Read looks up a cryptograpic commitment and returns associated data.
 	Read(ctx context.Context, commitment []byte) (data, nonce []byte, err error)
 }
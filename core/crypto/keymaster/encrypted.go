@@ -0,0 +1,132 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymaster
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	"github.com/google/keytransparency/core/crypto/signatures"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted key set is stored as magic || version || salt || nonce ||
+// ciphertext. The ciphertext is the KeySet sealed with AES-256-GCM, using
+// magic || version as additional data and a key derived from the passphrase
+// and salt with scrypt.
+const (
+	magic       = "KTKS"
+	version     = 1
+	saltSize    = 32
+	keySize     = 32
+	headerSize  = len(magic) + 1
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	minEncrSize = headerSize + saltSize
+)
+
+var (
+	// ErrWrongPassphrase occurs when an encrypted key set cannot be
+	// decrypted with the given passphrase, or has been tampered with.
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key set")
+	// ErrEncrypted occurs when Unmarshal is called on an encrypted key set.
+	ErrEncrypted = errors.New("key set is encrypted")
+	// ErrUnsupportedVersion occurs when the encrypted key set has an
+	// unknown format version.
+	ErrUnsupportedVersion = errors.New("unsupported encrypted key set version")
+)
+
+// IsEncrypted returns whether buf contains an encrypted key set.
+func IsEncrypted(buf []byte) bool {
+	return bytes.HasPrefix(buf, []byte(magic))
+}
+
+// MarshalEncrypted marshals the key store and encrypts it with a key derived
+// from passphrase.
+func (s *KeyMaster) MarshalEncrypted(passphrase []byte) ([]byte, error) {
+	plaintext, err := s.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(magic), version)
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(signatures.Rand, salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(signatures.Rand, nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(header)+len(salt)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, header...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// UnmarshalEncrypted decrypts buf with a key derived from passphrase and
+// unmarshals the key set into store. Plaintext key sets, as written by
+// Marshal, are read as well such that existing key stores can be migrated.
+func UnmarshalEncrypted(buf, passphrase []byte, store *KeyMaster) error {
+	if !IsEncrypted(buf) {
+		return Unmarshal(buf, store)
+	}
+	if len(buf) < minEncrSize {
+		return ErrWrongPassphrase
+	}
+	header := buf[:headerSize]
+	if header[len(magic)] != version {
+		return ErrUnsupportedVersion
+	}
+	salt := buf[headerSize : headerSize+saltSize]
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	rest := buf[headerSize+saltSize:]
+	if len(rest) < aead.NonceSize() {
+		return ErrWrongPassphrase
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+	return Unmarshal(plaintext, store)
+}
+
+// newAEAD derives a key from passphrase and salt and returns AES-256-GCM
+// keyed with it.
+func newAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
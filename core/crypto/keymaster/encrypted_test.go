@@ -0,0 +1,94 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymaster
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalEncrypted(t *testing.T) {
+	store := New()
+	keys, err := generateTestKeys(len(signingStatuses))
+	if err != nil {
+		t.Fatalf("generateTestKeys(%v) failed: %v", len(signingStatuses), err)
+	}
+	if err := addKeys(store, keys); err != nil {
+		t.Fatalf("addKeys() failed: %v", err)
+	}
+	passphrase := []byte("correct horse battery staple")
+	buf, err := store.MarshalEncrypted(passphrase)
+	if err != nil {
+		t.Fatalf("MarshalEncrypted() failed: %v", err)
+	}
+	if !IsEncrypted(buf) {
+		t.Errorf("IsEncrypted(MarshalEncrypted()): false, want true")
+	}
+	for _, k := range keys {
+		if bytes.Contains(buf, k.privKey) {
+			t.Errorf("MarshalEncrypted() contains the plaintext private key")
+		}
+	}
+
+	// Round trip.
+	unmarshalledStore := New()
+	if err := UnmarshalEncrypted(buf, passphrase, unmarshalledStore); err != nil {
+		t.Fatalf("UnmarshalEncrypted() failed: %v", err)
+	}
+	checkAddedSigningKeys(t, unmarshalledStore, keys)
+	checkAddedVerifyingKeys(t, unmarshalledStore, keys)
+
+	// Wrong passphrase.
+	if got, want := UnmarshalEncrypted(buf, []byte("wrong"), New()), ErrWrongPassphrase; got != want {
+		t.Errorf("UnmarshalEncrypted(wrong passphrase): %v, want %v", got, want)
+	}
+
+	// Tampering.
+	tampered := append([]byte(nil), buf...)
+	tampered[len(tampered)-1] ^= 1
+	if got, want := UnmarshalEncrypted(tampered, passphrase, New()), ErrWrongPassphrase; got != want {
+		t.Errorf("UnmarshalEncrypted(tampered): %v, want %v", got, want)
+	}
+	if got, want := UnmarshalEncrypted(buf[:headerSize+1], passphrase, New()), ErrWrongPassphrase; got != want {
+		t.Errorf("UnmarshalEncrypted(truncated): %v, want %v", got, want)
+	}
+
+	// Encrypted key sets cannot be read as plaintext.
+	if got, want := Unmarshal(buf, New()), ErrEncrypted; got != want {
+		t.Errorf("Unmarshal(encrypted): %v, want %v", got, want)
+	}
+}
+
+func TestUnmarshalEncryptedPlaintext(t *testing.T) {
+	store := New()
+	keys, err := generateTestKeys(len(signingStatuses))
+	if err != nil {
+		t.Fatalf("generateTestKeys(%v) failed: %v", len(signingStatuses), err)
+	}
+	if err := addKeys(store, keys); err != nil {
+		t.Fatalf("addKeys() failed: %v", err)
+	}
+	buf, err := store.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	// Plaintext key sets are read regardless of the passphrase.
+	unmarshalledStore := New()
+	if err := UnmarshalEncrypted(buf, []byte("any"), unmarshalledStore); err != nil {
+		t.Fatalf("UnmarshalEncrypted(plaintext) failed: %v", err)
+	}
+	checkAddedSigningKeys(t, unmarshalledStore, keys)
+	checkAddedVerifyingKeys(t, unmarshalledStore, keys)
+}
@@ -51,7 +51,11 @@ func New() *KeyMaster {
 }
 
 // Unmarshal unmarshals the provided protobuf into a key store object.
+// Encrypted key sets must be read with UnmarshalEncrypted.
 func Unmarshal(buf []byte, store *KeyMaster) error {
+	if IsEncrypted(buf) {
+		return ErrEncrypted
+	}
 	set := new(kmpb.KeySet)
 	if err := proto.Unmarshal(buf, set); err != nil {
 		return err
@@ -305,6 +309,15 @@ func (s *KeyMaster) Signer(keyID string) (Signer, error) {
 	return signer, nil
 }
 
+// Verifier returns a verifier object given the corresponding key ID.
+func (s *KeyMaster) Verifier(keyID string) (Verifier, error) {
+	verifier, ok := s.verifiers[keyID]
+	if !ok {
+		return nil, ErrKeyNotExist
+	}
+	return verifier, nil
+}
+
 // Signers returns a list of signers created using all active private keys.
 func (s *KeyMaster) Signers() []signatures.Signer {
 	signers := make([]signatures.Signer, 0, len(s.signers))
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/google/keytransparency/core/crypto/signatures"
+	"github.com/google/keytransparency/core/crypto/signatures/ed25519"
 	kmpb "github.com/google/keytransparency/core/proto/keymaster"
 )
 
@@ -503,6 +504,46 @@ func TestMarshalling(t *testing.T) {
 	checkAddedVerifyingKeys(t, unmarshalledStore, keys)
 }
 
+func TestEd25519Keys(t *testing.T) {
+	store := New()
+	skPEM, pkPEM, err := ed25519.GeneratePEMs()
+	if err != nil {
+		t.Fatalf("ed25519.GeneratePEMs() failed: %v", err)
+	}
+	keyID, err := store.AddSigningKey(kmpb.SigningKey_ACTIVE, "ed25519", skPEM)
+	if err != nil {
+		t.Fatalf("store.AddSigningKey() failed: %v", err)
+	}
+	if _, err := store.AddVerifyingKey("ed25519", pkPEM); err != nil {
+		t.Fatalf("store.AddVerifyingKey() failed: %v", err)
+	}
+	buf, err := store.Marshal()
+	if err != nil {
+		t.Fatalf("store.Marshal() failed: %v", err)
+	}
+	unmarshalledStore := New()
+	if err := Unmarshal(buf, unmarshalledStore); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	signer, err := unmarshalledStore.Signer(keyID)
+	if err != nil {
+		t.Fatalf("Signer(%v) failed: %v", keyID, err)
+	}
+	pks, err := unmarshalledStore.PublicKeys()
+	if err != nil {
+		t.Fatalf("PublicKeys() failed: %v", err)
+	}
+	if got, want := len(pks), 1; got != want {
+		t.Fatalf("len(PublicKeys()): %v, want %v", got, want)
+	}
+	if pks[0].GetEd25519() == nil {
+		t.Errorf("PublicKeys()[0] is not an Ed25519 key: %v", pks[0])
+	}
+	if got, want := signer.KeyID(), keyID; got != want {
+		t.Errorf("signer.KeyID(): %v, want %v", got, want)
+	}
+}
+
 func TestKeyIDs(t *testing.T) {
 	store := New()
 	keys, err := generateTestKeys(len(signingStatuses))
@@ -0,0 +1,365 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ed25519 implements signatures.Signer and signatures.Verifier using
+// Ed25519. Keys are encoded as described in RFC 8410: private keys as PKCS8
+// and public keys as PKIX SubjectPublicKeyInfo.
+package ed25519
+
+import (
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/google/keytransparency/core/crypto/signatures"
+
+	"github.com/benlaurie/objecthash/go/objecthash"
+	"golang.org/x/crypto/ed25519"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// sigAlgorithm identifies Ed25519 signatures. sigpb shares its numbering
+// space with TLS but has no name for ed25519, which is 7 in the TLS
+// SignatureAlgorithm registry (RFC 8422).
+const sigAlgorithm = sigpb.DigitallySigned_SignatureAlgorithm(7)
+
+var (
+	// oidEd25519 is the algorithm identifier of Ed25519 keys (RFC 8410).
+	oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+	// ErrInvalidKey occurs when a key cannot be parsed as an Ed25519 key.
+	ErrInvalidKey = errors.New("not an Ed25519 key")
+)
+
+// pkcs8 is the ASN.1 structure of a PKCS8 encoded private key.
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// publicKeyInfo is the ASN.1 structure of a PKIX encoded public key.
+type publicKeyInfo struct {
+	Algo      pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// signer generates signatures with a single key using Ed25519.
+type signer struct {
+	privKey ed25519.PrivateKey
+	keyID   string
+}
+
+// GeneratePEMs generates a PEM-formatted pair of Ed25519 public and private
+// keys.
+func GeneratePEMs() ([]byte, []byte, error) {
+	skBytes, pkBytes, err := generateByteKeys(signatures.Rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	skPEM := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: skBytes,
+		},
+	)
+	pkPEM := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: pkBytes,
+		},
+	)
+	return skPEM, pkPEM, nil
+}
+
+func generateByteKeys(rand io.Reader) ([]byte, []byte, error) {
+	pk, sk, err := ed25519.GenerateKey(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	skBytes, err := MarshalPrivateKey(sk)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkBytes, err := MarshalPublicKey(pk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return skBytes, pkBytes, nil
+}
+
+// MarshalPrivateKey encodes k in PKCS8 form.
+func MarshalPrivateKey(k ed25519.PrivateKey) ([]byte, error) {
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidKey
+	}
+	// The private key is the 32 bytes seed wrapped in an OCTET STRING.
+	seed, err := asn1.Marshal(k[:32])
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs8{
+		Algo:       pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+		PrivateKey: seed,
+	})
+}
+
+// ParsePrivateKey parses a PKCS8 encoded Ed25519 private key.
+func ParsePrivateKey(b []byte) (ed25519.PrivateKey, error) {
+	var k pkcs8
+	if rest, err := asn1.Unmarshal(b, &k); err != nil || len(rest) != 0 {
+		return nil, ErrInvalidKey
+	}
+	if !k.Algo.Algorithm.Equal(oidEd25519) {
+		return nil, ErrInvalidKey
+	}
+	var seed []byte
+	if rest, err := asn1.Unmarshal(k.PrivateKey, &seed); err != nil || len(rest) != 0 {
+		return nil, ErrInvalidKey
+	}
+	if len(seed) != 32 {
+		return nil, ErrInvalidKey
+	}
+	// The seed determines the key pair.
+	_, sk, err := ed25519.GenerateKey(newSeedReader(seed))
+	if err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+// MarshalPublicKey encodes k in PKIX form.
+func MarshalPublicKey(k ed25519.PublicKey) ([]byte, error) {
+	if len(k) != ed25519.PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+	return asn1.Marshal(publicKeyInfo{
+		Algo:      pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+		PublicKey: asn1.BitString{Bytes: k, BitLength: 8 * len(k)},
+	})
+}
+
+// ParsePublicKey parses a PKIX encoded Ed25519 public key.
+func ParsePublicKey(b []byte) (ed25519.PublicKey, error) {
+	var k publicKeyInfo
+	if rest, err := asn1.Unmarshal(b, &k); err != nil || len(rest) != 0 {
+		return nil, ErrInvalidKey
+	}
+	if !k.Algo.Algorithm.Equal(oidEd25519) {
+		return nil, ErrInvalidKey
+	}
+	if k.PublicKey.BitLength != 8*ed25519.PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+	return ed25519.PublicKey(k.PublicKey.Bytes), nil
+}
+
+// NewSigner creates a signer object from a private key.
+func NewSigner(k ed25519.PrivateKey) (signatures.Signer, error) {
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, signatures.ErrWrongKeyType
+	}
+	id, err := keyID(k.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	return &signer{
+		privKey: k,
+		keyID:   id,
+	}, nil
+}
+
+// Sign generates a digital signature object.
+func (s *signer) Sign(data interface{}) (*sigpb.DigitallySigned, error) {
+	j, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	hash := objecthash.CommonJSONHash(string(j))
+
+	return &sigpb.DigitallySigned{
+		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+		SignatureAlgorithm: sigAlgorithm,
+		Signature:          ed25519.Sign(s.privKey, hash[:]),
+	}, nil
+}
+
+// PublicKey returns the signer public key as tpb.PublicKey proto message.
+func (s *signer) PublicKey() (*tpb.PublicKey, error) {
+	return publicKey(s.privKey.Public().(ed25519.PublicKey)), nil
+}
+
+// KeyID returns the ID of the associated public key.
+func (s *signer) KeyID() string {
+	return s.keyID
+}
+
+// PrivateKeyPEM marshals a signer object into a byte string.
+func (s *signer) PrivateKeyPEM() ([]byte, error) {
+	skBytes, err := MarshalPrivateKey(s.privKey)
+	if err != nil {
+		return nil, err
+	}
+	skPEM := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: skBytes,
+		},
+	)
+	return skPEM, nil
+}
+
+// PublicKeyPEM returns the PEM-formatted public key of this signer.
+func (s *signer) PublicKeyPEM() ([]byte, error) {
+	return publicKeyPEM(s.privKey.Public().(ed25519.PublicKey))
+}
+
+// Clone creates a new instance of the signer object
+func (s *signer) Clone() signatures.Signer {
+	clone := *s
+	return &clone
+}
+
+// verifier verifies signatures using Ed25519.
+type verifier struct {
+	pubKey ed25519.PublicKey
+	keyID  string
+}
+
+// NewVerifier creates a verifier from an Ed25519 public key.
+func NewVerifier(k ed25519.PublicKey) (signatures.Verifier, error) {
+	if len(k) != ed25519.PublicKeySize {
+		return nil, signatures.ErrWrongKeyType
+	}
+	id, err := keyID(k)
+	if err != nil {
+		return nil, err
+	}
+	return &verifier{
+		pubKey: k,
+		keyID:  id,
+	}, nil
+}
+
+// Verify checks the digital signature associated applied to data.
+func (s *verifier) Verify(data interface{}, sig *sigpb.DigitallySigned) error {
+	if sig == nil {
+		return signatures.ErrMissingSig
+	}
+	if sig.HashAlgorithm != sigpb.DigitallySigned_SHA256 {
+		log.Print("not SHA256 hash algorithm")
+		return signatures.ErrVerify
+	}
+	if sig.SignatureAlgorithm != sigAlgorithm {
+		log.Print("not Ed25519 signature algorithm")
+		return signatures.ErrVerify
+	}
+
+	j, err := json.Marshal(data)
+	if err != nil {
+		log.Print("json.Marshal failed")
+		return signatures.ErrVerify
+	}
+	hash := objecthash.CommonJSONHash(string(j))
+
+	if !ed25519.Verify(s.pubKey, hash[:], sig.Signature) {
+		log.Print("failed to verify Ed25519 signature")
+		return signatures.ErrVerify
+	}
+	return nil
+}
+
+// PublicKey returns the verifier public key as tpb.PublicKey proto message.
+func (s *verifier) PublicKey() (*tpb.PublicKey, error) {
+	return publicKey(s.pubKey), nil
+}
+
+// KeyID returns the ID of the associated public key.
+func (s *verifier) KeyID() string {
+	return s.keyID
+}
+
+// PublicKeyPEM marshals a verifier object into a keymaster VerifyingKey message.
+func (s *verifier) PublicKeyPEM() ([]byte, error) {
+	return publicKeyPEM(s.pubKey)
+}
+
+// Clone creates a new instance of the verifier object
+func (s *verifier) Clone() signatures.Verifier {
+	clone := *s
+	return &clone
+}
+
+// keyID is the hex digits of the SHA256 of the PKIX encoded public key, the
+// same as signatures.KeyID for the other key types. x509 cannot marshal
+// Ed25519 keys, hence the key is marshaled here.
+func keyID(k ed25519.PublicKey) (string, error) {
+	pubBytes, err := MarshalPublicKey(k)
+	if err != nil {
+		return "", err
+	}
+	id := sha256.Sum256(pubBytes)
+	return hex.EncodeToString(id[:]), nil
+}
+
+func publicKeyPEM(k ed25519.PublicKey) ([]byte, error) {
+	pkBytes, err := MarshalPublicKey(k)
+	if err != nil {
+		return nil, err
+	}
+	pkPEM := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: pkBytes,
+		},
+	)
+	return pkPEM, nil
+}
+
+// publicKey returns k as tpb.PublicKey. The ed25519 field contains the raw 32
+// bytes key.
+func publicKey(k ed25519.PublicKey) *tpb.PublicKey {
+	return &tpb.PublicKey{
+		KeyType: &tpb.PublicKey_Ed25519{
+			Ed25519: []byte(k),
+		},
+	}
+}
+
+// seedReader returns the seed once, such that ed25519.GenerateKey derives the
+// key pair of the seed.
+type seedReader struct {
+	seed []byte
+}
+
+func newSeedReader(seed []byte) io.Reader {
+	return &seedReader{seed: seed}
+}
+
+func (r *seedReader) Read(p []byte) (int, error) {
+	if len(r.seed) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.seed)
+	r.seed = r.seed[n:]
+	return n, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ed25519
+
+import (
+	"encoding/pem"
+	"reflect"
+	"testing"
+
+	"github.com/google/keytransparency/core/crypto/dev"
+	"github.com/google/keytransparency/core/crypto/signatures"
+)
+
+func newEnv(t *testing.T) (signatures.Signer, signatures.Verifier) {
+	signatures.Rand = dev.Zeros
+	skPEM, pkPEM, err := GeneratePEMs()
+	if err != nil {
+		t.Fatalf("GeneratePEMs() failed: %v", err)
+	}
+	skBlock, _ := pem.Decode(skPEM)
+	pkBlock, _ := pem.Decode(pkPEM)
+	if skBlock == nil || pkBlock == nil {
+		t.Fatalf("no PEM block found")
+	}
+	sk, err := ParsePrivateKey(skBlock.Bytes)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey failed: %v", err)
+	}
+	pk, err := ParsePublicKey(pkBlock.Bytes)
+	if err != nil {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	signer, err := NewSigner(sk)
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	verifier, err := NewVerifier(pk)
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+	return signer, verifier
+}
+
+func TestConsistentKeyIDs(t *testing.T) {
+	signer, verifier := newEnv(t)
+	if got, want := signer.KeyID(), verifier.KeyID(); got != want {
+		t.Errorf("signer.KeyID(): %v, want %v", got, want)
+	}
+}
+
+func TestSignVerifier(t *testing.T) {
+	signer, verifier := newEnv(t)
+	for _, tc := range []struct {
+		data interface{}
+	}{
+		{struct{ Foo string }{"bar"}},
+	} {
+		sig, err := signer.Sign(tc.data)
+		if err != nil {
+			t.Errorf("Sign(%v): %v", tc.data, err)
+		}
+		if err := verifier.Verify(tc.data, sig); err != nil {
+			t.Errorf("Verify(%v, %v): %v", tc.data, sig, err)
+		}
+		if err := verifier.Verify(struct{ Foo string }{"baz"}, sig); err == nil {
+			t.Errorf("Verify() of different data unexpectedly succeeded")
+		}
+		sig.Signature[0] ^= 1
+		if err := verifier.Verify(tc.data, sig); err == nil {
+			t.Errorf("Verify() of modified signature unexpectedly succeeded")
+		}
+	}
+}
+
+func TestPublicKey(t *testing.T) {
+	signer, verifier := newEnv(t)
+	sPK, err := signer.PublicKey()
+	if err != nil {
+		t.Fatalf("signer.PublicKey() failed: %v", err)
+	}
+	vPK, err := verifier.PublicKey()
+	if err != nil {
+		t.Fatalf("verifier.PublicKey() failed: %v", err)
+	}
+	if !reflect.DeepEqual(sPK, vPK) {
+		t.Error("signer.PublicKey() and verifier.PublicKey() should be equal")
+	}
+	if got, want := len(sPK.GetEd25519()), 32; got != want {
+		t.Errorf("len(GetEd25519()): %v, want %v", got, want)
+	}
+}
+
+func TestPEMRoundTrip(t *testing.T) {
+	signer, verifier := newEnv(t)
+	skPEM, err := signer.PrivateKeyPEM()
+	if err != nil {
+		t.Fatalf("PrivateKeyPEM() failed: %v", err)
+	}
+	p, _ := pem.Decode(skPEM)
+	sk, err := ParsePrivateKey(p.Bytes)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey failed: %v", err)
+	}
+	signer2, err := NewSigner(sk)
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	if got, want := signer2.KeyID(), verifier.KeyID(); got != want {
+		t.Errorf("KeyID(): %v, want %v", got, want)
+	}
+}
+
+func TestParseInvalidKeys(t *testing.T) {
+	for _, b := range [][]byte{nil, []byte("foo")} {
+		if _, err := ParsePrivateKey(b); err != ErrInvalidKey {
+			t.Errorf("ParsePrivateKey(%v): %v, want %v", b, err, ErrInvalidKey)
+		}
+		if _, err := ParsePublicKey(b); err != ErrInvalidKey {
+			t.Errorf("ParsePublicKey(%v): %v, want %v", b, err, ErrInvalidKey)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 
 	"github.com/google/keytransparency/core/crypto/signatures"
+	"github.com/google/keytransparency/core/crypto/signatures/ed25519"
 	"github.com/google/keytransparency/core/crypto/signatures/p256"
 	ktrsa "github.com/google/keytransparency/core/crypto/signatures/rsa"
 
@@ -34,6 +35,8 @@ func NewSignerFromBytes(b []byte) (signatures.Signer, error) {
 		return ktrsa.NewSigner(k)
 	} else if k, err := x509.ParseECPrivateKey(b); err == nil {
 		return p256.NewSigner(k)
+	} else if k, err := ed25519.ParsePrivateKey(b); err == nil {
+		return ed25519.NewSigner(k)
 	}
 	return nil, signatures.ErrUnimplemented
 }
@@ -50,6 +53,10 @@ func NewSignerFromPEM(pemKey []byte) (signatures.Signer, error) {
 
 // NewVerifierFromBytes creates a verification object from the raw key bytes.
 func NewVerifierFromBytes(b []byte) (signatures.Verifier, error) {
+	// x509 does not support Ed25519 keys.
+	if k, err := ed25519.ParsePublicKey(b); err == nil {
+		return ed25519.NewVerifier(k)
+	}
 	k, err := x509.ParsePKIXPublicKey(b)
 	if err != nil {
 		return nil, err
@@ -78,7 +85,7 @@ func NewVerifierFromPEM(pemKey []byte) (signatures.Verifier, error) {
 func NewVerifierFromKey(key *tpb.PublicKey) (signatures.Verifier, error) {
 	switch {
 	case key.GetEd25519() != nil:
-		return nil, signatures.ErrUnimplemented
+		return ed25519.NewVerifier(key.GetEd25519())
 	case key.GetRsaVerifyingSha256_3072() != nil:
 		return NewVerifierFromBytes(key.GetRsaVerifyingSha256_3072())
 	case key.GetEcdsaVerifyingP256() != nil:
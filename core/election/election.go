@@ -0,0 +1,189 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package election elects a master among the replicas of a server, such that
+// work which must not run concurrently, e.g. creating epochs, is only done by
+// one replica at a time. Mastership is based on expiring leases, similar to
+// etcd leases: the master renews its lease while it is healthy, and another
+// replica takes over once the lease has been released or has expired.
+package election
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+var (
+	isMasterGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kt_election_is_master",
+		Help: "Set to 1 if this replica is the master of the resource, 0 otherwise.",
+	}, []string{"resource"})
+	termsCtr = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kt_election_terms",
+		Help: "Number of times this replica has been elected master of the resource.",
+	}, []string{"resource"})
+)
+
+func init() {
+	prometheus.MustRegister(isMasterGauge)
+	prometheus.MustRegister(termsCtr)
+}
+
+// LeaseStore grants expiring leases on named resources. A lease is held by at
+// most one holder at a time.
+type LeaseStore interface {
+	// Acquire acquires the lease on resource for holder, or renews it if
+	// holder already holds it, such that it expires after ttl. It returns
+	// false if another holder holds an unexpired lease.
+	Acquire(ctx context.Context, resource, holder string, ttl time.Duration) (bool, error)
+	// Release releases the lease on resource if it is held by holder.
+	Release(ctx context.Context, resource, holder string) error
+	// Holder returns the holder of the unexpired lease on resource, or ""
+	// if the resource is not leased.
+	Holder(ctx context.Context, resource string) (string, error)
+}
+
+// Election campaigns for mastership of a resource on behalf of a replica.
+type Election struct {
+	store    LeaseStore
+	resource string
+	id       string
+	ttl      time.Duration
+
+	mu sync.Mutex
+	// stop ends the current term, and done is closed once it has ended.
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New returns an election for mastership of resource. id identifies this
+// replica and must be unique among all replicas. Leases expire after ttl and
+// are renewed every ttl/3.
+func New(store LeaseStore, resource, id string, ttl time.Duration) *Election {
+	return &Election{
+		store:    store,
+		resource: resource,
+		id:       id,
+		ttl:      ttl,
+	}
+}
+
+// Master returns the ID of the current master, or "" if there is none.
+func (e *Election) Master(ctx context.Context) (string, error) {
+	return e.store.Holder(ctx, e.resource)
+}
+
+// Campaign blocks until this replica is master or ctx is done. The returned
+// context is canceled as soon as mastership is lost, i.e. when the lease
+// could not be renewed in time, or when ctx is done.
+func (e *Election) Campaign(ctx context.Context) (context.Context, error) {
+	period := e.ttl / 3
+	for {
+		acquired, err := e.acquire(ctx, period)
+		if err != nil {
+			glog.Warningf("election: Acquire(%v, %v): %v", e.resource, e.id, err)
+		}
+		if acquired {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(period):
+		}
+	}
+
+	glog.Infof("election: %v is master of %v", e.id, e.resource)
+	termsCtr.WithLabelValues(e.resource).Inc()
+	isMasterGauge.WithLabelValues(e.resource).Set(1)
+	mctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	e.mu.Lock()
+	e.stop, e.done = cancel, done
+	e.mu.Unlock()
+	go func() {
+		defer close(done)
+		defer isMasterGauge.WithLabelValues(e.resource).Set(0)
+		defer cancel()
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// Stop acting as master before the lease expires if it
+			// cannot be renewed, as another replica may take over
+			// then.
+			renewed, err := e.acquire(mctx, period)
+			if err != nil || !renewed {
+				glog.Errorf("election: %v lost mastership of %v: renewed: %v, err: %v",
+					e.id, e.resource, renewed, err)
+				return
+			}
+		}
+	}()
+	return mctx, nil
+}
+
+// acquire tries to acquire or renew the lease within timeout.
+func (e *Election) acquire(ctx context.Context, timeout time.Duration) (bool, error) {
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return e.store.Acquire(cctx, e.resource, e.id, e.ttl)
+}
+
+// Resign ends the current term, if any, and releases the lease of this
+// replica, such that another replica can take over without waiting for the
+// lease to expire.
+func (e *Election) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop, e.done = nil, nil
+	e.mu.Unlock()
+	if stop != nil {
+		stop()
+		<-done
+	}
+	return e.store.Release(ctx, e.resource, e.id)
+}
+
+// Run campaigns for mastership until ctx is done and calls run every time
+// this replica has been elected. The context passed to run is canceled when
+// mastership is lost, and run must return promptly then. Mastership is
+// resigned when run returns.
+func (e *Election) Run(ctx context.Context, run func(ctx context.Context)) error {
+	for {
+		mctx, err := e.Campaign(ctx)
+		if err != nil {
+			return err
+		}
+		run(mctx)
+		// Resign even if ctx is done, e.g. on shutdown.
+		rctx, cancel := context.WithTimeout(context.Background(), e.ttl)
+		if err := e.Resign(rctx); err != nil {
+			glog.Warningf("election: Resign(%v, %v): %v", e.resource, e.id, err)
+		}
+		cancel()
+		glog.Infof("election: %v resigned as master of %v", e.id, e.resource)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
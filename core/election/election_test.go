@@ -0,0 +1,124 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/fake"
+
+	"golang.org/x/net/context"
+)
+
+const ttl = 30 * time.Millisecond
+
+// failingStore fails to renew leases once broken is closed.
+type failingStore struct {
+	LeaseStore
+	broken chan struct{}
+}
+
+func (f *failingStore) Acquire(ctx context.Context, resource, holder string, ttl time.Duration) (bool, error) {
+	select {
+	case <-f.broken:
+		return false, errors.New("unavailable")
+	default:
+		return f.LeaseStore.Acquire(ctx, resource, holder, ttl)
+	}
+}
+
+func TestHandover(t *testing.T) {
+	ctx := context.Background()
+	store := fake.NewFakeLeaseStore()
+	a := New(store, "seq", "a", ttl)
+	b := New(store, "seq", "b", ttl)
+
+	actx, err := a.Campaign(ctx)
+	if err != nil {
+		t.Fatalf("a.Campaign(): %v", err)
+	}
+	// b is not elected while a renews its lease.
+	cctx, cancel := context.WithTimeout(ctx, 3*ttl)
+	if _, err := b.Campaign(cctx); err != context.DeadlineExceeded {
+		t.Errorf("b.Campaign(): %v, want %v", err, context.DeadlineExceeded)
+	}
+	cancel()
+	if got, err := b.Master(ctx); err != nil || got != "a" {
+		t.Errorf("Master(): %v, %v, want a", got, err)
+	}
+
+	// Resigning hands mastership over without waiting for the lease to
+	// expire.
+	if err := a.Resign(ctx); err != nil {
+		t.Fatalf("a.Resign(): %v", err)
+	}
+	if actx.Err() == nil {
+		t.Errorf("a is still master after resigning")
+	}
+	bctx, err := b.Campaign(ctx)
+	if err != nil {
+		t.Fatalf("b.Campaign(): %v", err)
+	}
+	if got, err := a.Master(ctx); err != nil || got != "b" {
+		t.Errorf("Master(): %v, %v, want b", got, err)
+	}
+	if err := b.Resign(ctx); err != nil {
+		t.Fatalf("b.Resign(): %v", err)
+	}
+	if bctx.Err() == nil {
+		t.Errorf("b is still master after resigning")
+	}
+}
+
+func TestLostLease(t *testing.T) {
+	ctx := context.Background()
+	store := &failingStore{LeaseStore: fake.NewFakeLeaseStore(), broken: make(chan struct{})}
+	e := New(store, "seq", "a", ttl)
+	mctx, err := e.Campaign(ctx)
+	if err != nil {
+		t.Fatalf("Campaign(): %v", err)
+	}
+	close(store.broken)
+	select {
+	case <-mctx.Done():
+	case <-time.After(ttl):
+		t.Errorf("master did not step down before its lease expired")
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := fake.NewFakeLeaseStore()
+	e := New(store, "seq", "a", ttl)
+	terms := 0
+	err := e.Run(ctx, func(mctx context.Context) {
+		terms++
+		if terms == 2 {
+			cancel()
+		}
+		// Give up mastership without losing the lease.
+	})
+	if err != context.Canceled {
+		t.Errorf("Run(): %v, want %v", err, context.Canceled)
+	}
+	if terms != 2 {
+		t.Errorf("Run(): %v terms, want 2", terms)
+	}
+	if got, err := store.Holder(context.Background(), "seq"); err != nil || got != "" {
+		t.Errorf("Holder(): %q, %v, want no holder after Run", got, err)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type lease struct {
+	holder string
+	expiry time.Time
+}
+
+// LeaseStore is an in-memory election.LeaseStore.
+type LeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]lease
+}
+
+// NewFakeLeaseStore returns a lease store without leases.
+func NewFakeLeaseStore() *LeaseStore {
+	return &LeaseStore{
+		leases: make(map[string]lease),
+	}
+}
+
+// Acquire acquires or renews the lease on resource for holder unless another
+// holder holds an unexpired lease.
+func (s *LeaseStore) Acquire(ctx context.Context, resource, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if l, ok := s.leases[resource]; ok && l.holder != holder && now.Before(l.expiry) {
+		return false, nil
+	}
+	s.leases[resource] = lease{holder: holder, expiry: now.Add(ttl)}
+	return true, nil
+}
+
+// Release releases the lease on resource if it is held by holder.
+func (s *LeaseStore) Release(ctx context.Context, resource, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[resource]; ok && l.holder == holder {
+		delete(s.leases, resource)
+	}
+	return nil
+}
+
+// Holder returns the holder of the unexpired lease on resource.
+func (s *LeaseStore) Holder(ctx context.Context, resource string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[resource]; ok && time.Now().Before(l.expiry) {
+		return l.holder, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TrillianAdmin is an in-memory trillian.TrillianAdminClient serving a fixed
+// set of trees, e.g. the trees of a TrillianLog and a TrillianMap. Trees
+// cannot be created, updated or deleted; those RPCs panic.
+type TrillianAdmin struct {
+	trillian.TrillianAdminClient
+	trees map[int64]*trillian.Tree
+}
+
+// NewFakeTrillianAdminClient returns an admin client serving trees.
+func NewFakeTrillianAdminClient(trees ...*trillian.Tree) *TrillianAdmin {
+	a := &TrillianAdmin{trees: make(map[int64]*trillian.Tree)}
+	for _, t := range trees {
+		a.trees[t.GetTreeId()] = t
+	}
+	return a
+}
+
+// ListTrees returns all trees ordered by ID.
+func (a *TrillianAdmin) ListTrees(ctx context.Context, in *trillian.ListTreesRequest, opts ...grpc.CallOption) (*trillian.ListTreesResponse, error) {
+	resp := &trillian.ListTreesResponse{}
+	for _, t := range a.trees {
+		resp.Tree = append(resp.Tree, proto.Clone(t).(*trillian.Tree))
+	}
+	sort.Slice(resp.Tree, func(i, j int) bool {
+		return resp.Tree[i].GetTreeId() < resp.Tree[j].GetTreeId()
+	})
+	return resp, nil
+}
+
+// GetTree returns the tree with ID in.TreeId.
+func (a *TrillianAdmin) GetTree(ctx context.Context, in *trillian.GetTreeRequest, opts ...grpc.CallOption) (*trillian.Tree, error) {
+	t, ok := a.trees[in.GetTreeId()]
+	if !ok {
+		return nil, grpc.Errorf(codes.NotFound, "tree %v not found", in.GetTreeId())
+	}
+	return proto.Clone(t).(*trillian.Tree), nil
+}
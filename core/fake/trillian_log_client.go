@@ -15,61 +15,357 @@
 package fake
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/merkle/rfc6962"
 	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
-type logServer struct {
-	treeSize int64
+// TrillianLog is an in-memory trillian.TrillianLogClient. Leaves are
+// sequenced as soon as they are queued into an RFC 6962 Merkle tree, and log
+// roots are signed with a key generated for each log. Inclusion and
+// consistency proofs are computed from the tree, so they can be checked with
+// the Verifier of the log.
+type TrillianLog struct {
+	mu       sync.Mutex
+	hasher   hashers.LogHasher
+	key      crypto.Signer
+	pubKey   *keyspb.PublicKey
+	signer   *tcrypto.Signer
+	leaves   []*trillian.LogLeaf
+	ids      map[string]int64
+	root     *trillian.SignedLogRoot
+	revision int64
+}
+
+// NewFakeTrillianLogClient returns an empty in-memory log.
+func NewFakeTrillianLogClient() *TrillianLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	pubKey, err := der.ToPublicProto(key.Public())
+	if err != nil {
+		panic(err)
+	}
+	return &TrillianLog{
+		hasher: rfc6962.DefaultHasher,
+		key:    key,
+		pubKey: pubKey,
+		signer: tcrypto.NewSHA256Signer(key),
+		ids:    make(map[string]int64),
+	}
+}
+
+// Tree returns the tree of the log with ID logID, as served by the
+// TrillianAdminClient.
+func (l *TrillianLog) Tree(logID int64) *trillian.Tree {
+	return &trillian.Tree{
+		TreeId:             logID,
+		TreeState:          trillian.TreeState_ACTIVE,
+		TreeType:           trillian.TreeType_LOG,
+		HashStrategy:       trillian.HashStrategy_RFC6962_SHA256,
+		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+		SignatureAlgorithm: sigpb.DigitallySigned_ECDSA,
+		PublicKey:          l.pubKey,
+	}
+}
+
+// PublicKey returns the key verifying the signatures of the log roots.
+func (l *TrillianLog) PublicKey() crypto.PublicKey {
+	return l.key.Public()
 }
 
-// NewFakeTrillianLogClient returns a fake trillian log client.
-func NewFakeTrillianLogClient() trillian.TrillianLogClient {
-	return &logServer{}
+// Verifier returns a verifier for the log roots and proofs of the log.
+func (l *TrillianLog) Verifier() client.LogVerifier {
+	return client.NewLogVerifier(l.hasher, l.key.Public())
 }
 
-func (l *logServer) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
-	l.treeSize++
-	return nil, nil
+// add sequences leaf. Leaves are deduplicated by their LeafIdentityHash,
+// which defaults to their MerkleLeafHash. add reports whether leaf is new.
+func (l *TrillianLog) add(leaf *trillian.LogLeaf) (*trillian.LogLeaf, bool, error) {
+	leaf = proto.Clone(leaf).(*trillian.LogLeaf)
+	hash := l.hasher.HashLeaf(leaf.LeafValue)
+	leaf.MerkleLeafHash = hash
+	if len(leaf.LeafIdentityHash) == 0 {
+		leaf.LeafIdentityHash = hash
+	}
+	if i, ok := l.ids[string(leaf.LeafIdentityHash)]; ok {
+		return l.leaves[i], false, nil
+	}
+	leaf.LeafIndex = int64(len(l.leaves))
+	l.ids[string(leaf.LeafIdentityHash)] = leaf.LeafIndex
+	l.leaves = append(l.leaves, leaf)
+	l.root = nil
+	return leaf, true, nil
 }
 
-func (l *logServer) QueueLeaves(ctx context.Context, in *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
-	panic("not implemented")
+// queued returns the QueuedLogLeaf of leaf. Duplicates have status
+// AlreadyExists, like in Trillian.
+func queued(leaf *trillian.LogLeaf, added bool) *trillian.QueuedLogLeaf {
+	q := &trillian.QueuedLogLeaf{Leaf: leaf}
+	if !added {
+		q.Status = &status.Status{
+			Code:    int32(codes.AlreadyExists),
+			Message: "leaf already exists",
+		}
+	}
+	return q
 }
 
-func (l *logServer) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
-	return &trillian.GetInclusionProofResponse{}, nil
+// QueueLeaf sequences in.Leaf.
+func (l *TrillianLog) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	leaf, added, err := l.add(in.GetLeaf())
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.QueueLeafResponse{QueuedLeaf: queued(leaf, added)}, nil
 }
 
-func (l *logServer) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
-	panic("not implemented")
+// QueueLeaves sequences in.Leaves in order.
+func (l *TrillianLog) QueueLeaves(ctx context.Context, in *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	resp := &trillian.QueueLeavesResponse{}
+	for _, leaf := range in.GetLeaves() {
+		leaf, added, err := l.add(leaf)
+		if err != nil {
+			return nil, err
+		}
+		resp.QueuedLeaves = append(resp.QueuedLeaves, queued(leaf, added))
+	}
+	return resp, nil
+}
+
+// leafHashes returns the leaf hashes of the tree of size treeSize.
+func (l *TrillianLog) leafHashes(treeSize int64) [][]byte {
+	hashes := make([][]byte, 0, treeSize)
+	for _, leaf := range l.leaves[:treeSize] {
+		hashes = append(hashes, leaf.MerkleLeafHash)
+	}
+	return hashes
+}
+
+// checkTreeSize verifies that the log has been of size treeSize.
+func (l *TrillianLog) checkTreeSize(treeSize int64) error {
+	if treeSize < 1 || treeSize > int64(len(l.leaves)) {
+		return grpc.Errorf(codes.InvalidArgument, "tree size %v out of range [1, %v]", treeSize, len(l.leaves))
+	}
+	return nil
+}
+
+// GetInclusionProof returns the audit path of in.LeafIndex in the tree of
+// size in.TreeSize.
+func (l *TrillianLog) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.checkTreeSize(in.GetTreeSize()); err != nil {
+		return nil, err
+	}
+	if in.GetLeafIndex() < 0 || in.GetLeafIndex() >= in.GetTreeSize() {
+		return nil, grpc.Errorf(codes.InvalidArgument, "leaf index %v out of range [0, %v)", in.GetLeafIndex(), in.GetTreeSize())
+	}
+	return &trillian.GetInclusionProofResponse{
+		Proof: &trillian.Proof{
+			LeafIndex: in.GetLeafIndex(),
+			Hashes:    inclusionProof(l.hasher, in.GetLeafIndex(), l.leafHashes(in.GetTreeSize())),
+		},
+	}, nil
 }
 
-func (l *logServer) GetConsistencyProof(ctx context.Context, in *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
-	return &trillian.GetConsistencyProofResponse{}, nil
+// GetInclusionProofByHash returns the audit paths of the leaves with the
+// Merkle leaf hash in.LeafHash in the tree of size in.TreeSize.
+func (l *TrillianLog) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.checkTreeSize(in.GetTreeSize()); err != nil {
+		return nil, err
+	}
+	hashes := l.leafHashes(in.GetTreeSize())
+	resp := &trillian.GetInclusionProofByHashResponse{}
+	for i, h := range hashes {
+		if !bytes.Equal(h, in.GetLeafHash()) {
+			continue
+		}
+		resp.Proof = append(resp.Proof, &trillian.Proof{
+			LeafIndex: int64(i),
+			Hashes:    inclusionProof(l.hasher, int64(i), hashes),
+		})
+	}
+	if len(resp.Proof) == 0 {
+		return nil, grpc.Errorf(codes.NotFound, "leaf hash %x not found", in.GetLeafHash())
+	}
+	return resp, nil
 }
 
-func (l *logServer) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
-	return &trillian.GetLatestSignedLogRootResponse{
-		SignedLogRoot: &trillian.SignedLogRoot{
-			TreeSize: l.treeSize,
+// GetConsistencyProof returns the proof that the tree of size
+// in.SecondTreeSize is an extension of the tree of size in.FirstTreeSize.
+func (l *TrillianLog) GetConsistencyProof(ctx context.Context, in *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.checkTreeSize(in.GetSecondTreeSize()); err != nil {
+		return nil, err
+	}
+	if in.GetFirstTreeSize() < 1 || in.GetFirstTreeSize() > in.GetSecondTreeSize() {
+		return nil, grpc.Errorf(codes.InvalidArgument, "first tree size %v out of range [1, %v]", in.GetFirstTreeSize(), in.GetSecondTreeSize())
+	}
+	return &trillian.GetConsistencyProofResponse{
+		Proof: &trillian.Proof{
+			Hashes: consistencyProof(l.hasher, in.GetFirstTreeSize(), l.leafHashes(in.GetSecondTreeSize())),
 		},
 	}, nil
 }
 
-func (l *logServer) GetSequencedLeafCount(ctx context.Context, in *trillian.GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*trillian.GetSequencedLeafCountResponse, error) {
-	panic("not implemented")
+// GetLatestSignedLogRoot returns the signed root of all sequenced leaves.
+func (l *TrillianLog) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.root == nil {
+		root := trillian.SignedLogRoot{
+			TimestampNanos: time.Now().UnixNano(),
+			RootHash:       rootHash(l.hasher, l.leafHashes(int64(len(l.leaves)))),
+			TreeSize:       int64(len(l.leaves)),
+			TreeRevision:   l.revision,
+		}
+		sig, err := l.signer.Sign(tcrypto.HashLogRoot(root))
+		if err != nil {
+			return nil, grpc.Errorf(codes.Internal, "Sign(): %v", err)
+		}
+		root.Signature = sig
+		l.root = &root
+		l.revision++
+	}
+	root := *l.root
+	root.LogId = in.GetLogId()
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &root}, nil
+}
+
+// GetSequencedLeafCount returns the number of sequenced leaves.
+func (l *TrillianLog) GetSequencedLeafCount(ctx context.Context, in *trillian.GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*trillian.GetSequencedLeafCountResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &trillian.GetSequencedLeafCountResponse{LeafCount: int64(len(l.leaves))}, nil
+}
+
+// GetLeavesByIndex returns the leaves at in.LeafIndex.
+func (l *TrillianLog) GetLeavesByIndex(ctx context.Context, in *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	resp := &trillian.GetLeavesByIndexResponse{}
+	for _, i := range in.GetLeafIndex() {
+		if i < 0 || i >= int64(len(l.leaves)) {
+			return nil, grpc.Errorf(codes.OutOfRange, "leaf index %v out of range [0, %v)", i, len(l.leaves))
+		}
+		resp.Leaves = append(resp.Leaves, l.leaves[i])
+	}
+	return resp, nil
+}
+
+// GetLeavesByHash returns the leaves with the Merkle leaf hashes in.LeafHash.
+func (l *TrillianLog) GetLeavesByHash(ctx context.Context, in *trillian.GetLeavesByHashRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByHashResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	resp := &trillian.GetLeavesByHashResponse{}
+	for _, leaf := range l.leaves {
+		for _, h := range in.GetLeafHash() {
+			if bytes.Equal(leaf.MerkleLeafHash, h) {
+				resp.Leaves = append(resp.Leaves, leaf)
+				break
+			}
+		}
+	}
+	return resp, nil
+}
+
+// GetEntryAndProof returns the leaf at in.LeafIndex and its audit path in the
+// tree of size in.TreeSize.
+func (l *TrillianLog) GetEntryAndProof(ctx context.Context, in *trillian.GetEntryAndProofRequest, opts ...grpc.CallOption) (*trillian.GetEntryAndProofResponse, error) {
+	proof, err := l.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+		LogId:     in.GetLogId(),
+		LeafIndex: in.GetLeafIndex(),
+		TreeSize:  in.GetTreeSize(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &trillian.GetEntryAndProofResponse{
+		Proof: proof.GetProof(),
+		Leaf:  l.leaves[in.GetLeafIndex()],
+	}, nil
+}
+
+// split returns the largest power of two smaller than n, n > 1.
+func split(n int64) int64 {
+	k := int64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// rootHash returns MTH(D[n]) of RFC 6962 section 2.1 for the leaf hashes D.
+func rootHash(h hashers.LogHasher, leaves [][]byte) []byte {
+	n := int64(len(leaves))
+	switch n {
+	case 0:
+		return h.EmptyRoot()
+	case 1:
+		return leaves[0]
+	}
+	k := split(n)
+	return h.HashChildren(rootHash(h, leaves[:k]), rootHash(h, leaves[k:]))
 }
 
-func (l *logServer) GetLeavesByIndex(ctx context.Context, in *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
-	panic("not implemented")
+// inclusionProof returns PATH(m, D[n]) of RFC 6962 section 2.1.1.
+func inclusionProof(h hashers.LogHasher, m int64, leaves [][]byte) [][]byte {
+	n := int64(len(leaves))
+	if n <= 1 {
+		return [][]byte{}
+	}
+	k := split(n)
+	if m < k {
+		return append(inclusionProof(h, m, leaves[:k]), rootHash(h, leaves[k:]))
+	}
+	return append(inclusionProof(h, m-k, leaves[k:]), rootHash(h, leaves[:k]))
 }
 
-func (l *logServer) GetLeavesByHash(ctx context.Context, in *trillian.GetLeavesByHashRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByHashResponse, error) {
-	panic("not implemented")
+// consistencyProof returns PROOF(m, D[n]) of RFC 6962 section 2.1.2.
+func consistencyProof(h hashers.LogHasher, m int64, leaves [][]byte) [][]byte {
+	return subproof(h, m, leaves, true)
 }
 
-func (l *logServer) GetEntryAndProof(ctx context.Context, in *trillian.GetEntryAndProofRequest, opts ...grpc.CallOption) (*trillian.GetEntryAndProofResponse, error) {
-	panic("not implemented")
+func subproof(h hashers.LogHasher, m int64, leaves [][]byte, complete bool) [][]byte {
+	n := int64(len(leaves))
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+		return [][]byte{rootHash(h, leaves)}
+	}
+	k := split(n)
+	if m <= k {
+		return append(subproof(h, m, leaves[:k], complete), rootHash(h, leaves[k:]))
+	}
+	return append(subproof(h, m-k, leaves[k:], false), rootHash(h, leaves[:k]))
 }
@@ -0,0 +1,147 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+func TestTrillianLogProofs(t *testing.T) {
+	ctx := context.Background()
+	l := NewFakeTrillianLogClient()
+	v := l.Verifier()
+	trusted := &trillian.SignedLogRoot{}
+	for size := int64(1); size <= 17; size++ {
+		data := []byte(fmt.Sprintf("leaf %v", size-1))
+		resp, err := l.QueueLeaf(ctx, &trillian.QueueLeafRequest{
+			Leaf: &trillian.LogLeaf{LeafValue: data},
+		})
+		if err != nil {
+			t.Fatalf("QueueLeaf(): %v", err)
+		}
+		if got, want := resp.GetQueuedLeaf().GetLeaf().GetLeafIndex(), size-1; got != want {
+			t.Errorf("QueueLeaf(): LeafIndex %v, want %v", got, want)
+		}
+
+		root, err := l.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{})
+		if err != nil {
+			t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+		}
+		newRoot := root.GetSignedLogRoot()
+		if got, want := newRoot.GetTreeSize(), size; got != want {
+			t.Errorf("TreeSize: %v, want %v", got, want)
+		}
+		var consistency [][]byte
+		if trusted.GetTreeSize() > 0 {
+			c, err := l.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{
+				FirstTreeSize:  trusted.GetTreeSize(),
+				SecondTreeSize: size,
+			})
+			if err != nil {
+				t.Fatalf("GetConsistencyProof(%v, %v): %v", trusted.GetTreeSize(), size, err)
+			}
+			consistency = c.GetProof().GetHashes()
+		}
+		if err := v.VerifyRoot(trusted, newRoot, consistency); err != nil {
+			t.Errorf("VerifyRoot(%v, %v): %v", trusted.GetTreeSize(), size, err)
+		}
+		trusted = newRoot
+
+		for i := int64(0); i < size; i++ {
+			p, err := l.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+				LeafIndex: i,
+				TreeSize:  size,
+			})
+			if err != nil {
+				t.Fatalf("GetInclusionProof(%v, %v): %v", i, size, err)
+			}
+			leaf := []byte(fmt.Sprintf("leaf %v", i))
+			if err := v.VerifyInclusionAtIndex(newRoot, leaf, i, p.GetProof().GetHashes()); err != nil {
+				t.Errorf("VerifyInclusionAtIndex(%v, %v): %v", i, size, err)
+			}
+			if err := v.VerifyInclusionAtIndex(newRoot, []byte("other"), i, p.GetProof().GetHashes()); err == nil {
+				t.Errorf("VerifyInclusionAtIndex(%v, %v) of another leaf succeeded", i, size)
+			}
+		}
+	}
+
+	// A root which has not been signed by the log is rejected.
+	forged := *trusted
+	forged.RootHash = []byte("forged")
+	if err := v.VerifyRoot(&trillian.SignedLogRoot{}, &forged, nil); err == nil {
+		t.Errorf("VerifyRoot(forged root) succeeded")
+	}
+}
+
+func TestTrillianLogQueueLeaf(t *testing.T) {
+	ctx := context.Background()
+	l := NewFakeTrillianLogClient()
+	leaves := []*trillian.LogLeaf{
+		{LeafValue: []byte("a")},
+		{LeafValue: []byte("b")},
+		{LeafValue: []byte("a")},
+		{LeafValue: []byte("a"), LeafIdentityHash: []byte("other id")},
+	}
+	resp, err := l.QueueLeaves(ctx, &trillian.QueueLeavesRequest{Leaves: leaves})
+	if err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+	for i, tc := range []struct {
+		index int64
+		code  codes.Code
+	}{
+		{0, codes.OK},
+		{1, codes.OK},
+		{0, codes.AlreadyExists},
+		{2, codes.OK},
+	} {
+		q := resp.GetQueuedLeaves()[i]
+		if got, want := q.GetLeaf().GetLeafIndex(), tc.index; got != want {
+			t.Errorf("QueuedLeaves[%v]: LeafIndex %v, want %v", i, got, want)
+		}
+		if got, want := codes.Code(q.GetStatus().GetCode()), tc.code; got != want {
+			t.Errorf("QueuedLeaves[%v]: status %v, want %v", i, got, want)
+		}
+	}
+
+	count, err := l.GetSequencedLeafCount(ctx, &trillian.GetSequencedLeafCountRequest{})
+	if err != nil {
+		t.Fatalf("GetSequencedLeafCount(): %v", err)
+	}
+	if got, want := count.GetLeafCount(), int64(3); got != want {
+		t.Errorf("GetSequencedLeafCount(): %v, want %v", got, want)
+	}
+	byIndex, err := l.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1}})
+	if err != nil {
+		t.Fatalf("GetLeavesByIndex(): %v", err)
+	}
+	if got, want := string(byIndex.GetLeaves()[0].GetLeafValue()), "b"; got != want {
+		t.Errorf("GetLeavesByIndex(1): %v, want %v", got, want)
+	}
+	if _, err := l.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{3}}); err == nil {
+		t.Errorf("GetLeavesByIndex(3) succeeded on a log of size 3")
+	}
+	if _, err := l.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{LeafIndex: 0, TreeSize: 4}); err == nil {
+		t.Errorf("GetInclusionProof() succeeded for a tree larger than the log")
+	}
+	if _, err := l.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{FirstTreeSize: 3, SecondTreeSize: 2}); err == nil {
+		t.Errorf("GetConsistencyProof(3, 2) succeeded")
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/coniks"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// mapRevision is the state of the map at a revision.
+type mapRevision struct {
+	root   *trillian.SignedMapRoot
+	leaves map[string]*trillian.MapLeaf
+	// nodes contains the hashes of the non-empty nodes of the tree,
+	// indexed by node ID.
+	nodes map[string][]byte
+}
+
+// TrillianMap is an in-memory trillian.TrillianMapClient serving a single
+// CONIKS sparse Merkle tree. Revision 0 is the empty map, and every SetLeaves
+// call creates a new revision. Map roots are signed with a key generated for
+// each map, and leaves are served with inclusion proofs, so both can be
+// checked like the roots and proofs of a Trillian map.
+type TrillianMap struct {
+	mu        sync.Mutex
+	mapID     int64
+	hasher    hashers.MapHasher
+	key       crypto.Signer
+	pubKey    *keyspb.PublicKey
+	signer    *tcrypto.Signer
+	revisions []*mapRevision
+}
+
+// NewFakeTrillianMapClient returns an empty in-memory map with ID mapID.
+func NewFakeTrillianMapClient(mapID int64) *TrillianMap {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	pubKey, err := der.ToPublicProto(key.Public())
+	if err != nil {
+		panic(err)
+	}
+	m := &TrillianMap{
+		mapID:  mapID,
+		hasher: coniks.Default,
+		key:    key,
+		pubKey: pubKey,
+		signer: tcrypto.NewSHA256Signer(key),
+	}
+	if err := m.commit(make(map[string]*trillian.MapLeaf), nil); err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Tree returns the tree of the map, as served by the TrillianAdminClient.
+func (m *TrillianMap) Tree() *trillian.Tree {
+	return &trillian.Tree{
+		TreeId:             m.mapID,
+		TreeState:          trillian.TreeState_ACTIVE,
+		TreeType:           trillian.TreeType_MAP,
+		HashStrategy:       trillian.HashStrategy_CONIKS_SHA512_256,
+		HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+		SignatureAlgorithm: sigpb.DigitallySigned_ECDSA,
+		PublicKey:          m.pubKey,
+	}
+}
+
+// PublicKey returns the key verifying the signatures of the map roots.
+func (m *TrillianMap) PublicKey() crypto.PublicKey {
+	return m.key.Public()
+}
+
+// commit computes the tree of leaves and appends it as a new revision.
+func (m *TrillianMap) commit(leaves map[string]*trillian.MapLeaf, metadata *trillian.MapperMetadata) error {
+	bitLen := m.hasher.BitLen()
+	nodes := make(map[string][]byte)
+	hashes := make([]merkle.HStar2LeafHash, 0, len(leaves))
+	for _, l := range leaves {
+		nID := storage.NewNodeIDFromPrefixSuffix(l.Index, storage.Suffix{}, bitLen)
+		nodes[nID.String()] = l.LeafHash
+		hashes = append(hashes, merkle.HStar2LeafHash{
+			Index:    nID.BigInt(),
+			LeafHash: l.LeafHash,
+		})
+	}
+	hs2 := merkle.NewHStar2(m.mapID, m.hasher)
+	rootHash, err := hs2.HStar2Nodes([]byte{}, bitLen, hashes,
+		func(depth int, index *big.Int) ([]byte, error) {
+			return nil, nil
+		},
+		func(depth int, index *big.Int, hash []byte) error {
+			id := storage.NewNodeIDFromBigInt(depth, index, bitLen)
+			nodes[id.String()] = hash
+			return nil
+		})
+	if err != nil {
+		return grpc.Errorf(codes.Internal, "HStar2Nodes(): %v", err)
+	}
+
+	smr := trillian.SignedMapRoot{
+		TimestampNanos: time.Now().UnixNano(),
+		RootHash:       rootHash,
+		Metadata:       metadata,
+		MapId:          m.mapID,
+		MapRevision:    int64(len(m.revisions)),
+	}
+	sig, err := m.signer.SignObject(smr)
+	if err != nil {
+		return grpc.Errorf(codes.Internal, "SignObject(): %v", err)
+	}
+	smr.Signature = sig
+	m.revisions = append(m.revisions, &mapRevision{
+		root:   &smr,
+		leaves: leaves,
+		nodes:  nodes,
+	})
+	return nil
+}
+
+// revision returns the state of the map at revision, or the latest state if
+// revision is negative.
+func (m *TrillianMap) revision(mapID, revision int64) (*mapRevision, error) {
+	if mapID != m.mapID {
+		return nil, grpc.Errorf(codes.NotFound, "map %v not found", mapID)
+	}
+	if revision < 0 {
+		return m.revisions[len(m.revisions)-1], nil
+	}
+	if revision >= int64(len(m.revisions)) {
+		return nil, grpc.Errorf(codes.NotFound, "map revision %v not found", revision)
+	}
+	return m.revisions[revision], nil
+}
+
+// checkIndex verifies that index addresses a leaf of the tree.
+func (m *TrillianMap) checkIndex(index []byte) error {
+	if got, want := len(index)*8, m.hasher.BitLen(); got != want {
+		return grpc.Errorf(codes.InvalidArgument, "index length %v bits, want %v", got, want)
+	}
+	return nil
+}
+
+// GetLeaves returns the leaves at in.Index and their inclusion proofs at
+// in.Revision. A negative revision selects the latest revision. Absent leaves
+// are returned without a value.
+func (m *TrillianMap) GetLeaves(ctx context.Context, in *trillian.GetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.GetMapLeavesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rev, err := m.revision(in.GetMapId(), in.GetRevision())
+	if err != nil {
+		return nil, err
+	}
+	bitLen := m.hasher.BitLen()
+	resp := &trillian.GetMapLeavesResponse{
+		MapRoot: proto.Clone(rev.root).(*trillian.SignedMapRoot),
+	}
+	for _, index := range in.GetIndex() {
+		if err := m.checkIndex(index); err != nil {
+			return nil, err
+		}
+		leaf, ok := rev.leaves[string(index)]
+		if ok {
+			leaf = proto.Clone(leaf).(*trillian.MapLeaf)
+		} else {
+			leaf = &trillian.MapLeaf{Index: index}
+		}
+		// Proof hashes of empty subtrees are omitted.
+		id := storage.NewNodeIDFromPrefixSuffix(index, storage.Suffix{}, bitLen)
+		siblings := id.Siblings()
+		inclusion := make([][]byte, len(siblings))
+		for i := range siblings {
+			inclusion[i] = rev.nodes[siblings[i].String()]
+		}
+		resp.MapLeafInclusion = append(resp.MapLeafInclusion, &trillian.MapLeafInclusion{
+			Leaf:      leaf,
+			Inclusion: inclusion,
+		})
+	}
+	return resp, nil
+}
+
+// SetLeaves creates a new revision with in.Leaves set to their new values and
+// in.MapperData as metadata of the map root.
+func (m *TrillianMap) SetLeaves(ctx context.Context, in *trillian.SetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.SetMapLeavesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest, err := m.revision(in.GetMapId(), -1)
+	if err != nil {
+		return nil, err
+	}
+	leaves := make(map[string]*trillian.MapLeaf, len(latest.leaves)+len(in.GetLeaves()))
+	for k, v := range latest.leaves {
+		leaves[k] = v
+	}
+	for _, l := range in.GetLeaves() {
+		if err := m.checkIndex(l.GetIndex()); err != nil {
+			return nil, err
+		}
+		leaf := proto.Clone(l).(*trillian.MapLeaf)
+		leaf.LeafHash = m.hasher.HashLeaf(m.mapID, leaf.Index, leaf.LeafValue)
+		leaves[string(leaf.Index)] = leaf
+	}
+	var metadata *trillian.MapperMetadata
+	if in.GetMapperData() != nil {
+		metadata = proto.Clone(in.GetMapperData()).(*trillian.MapperMetadata)
+	}
+	if err := m.commit(leaves, metadata); err != nil {
+		return nil, err
+	}
+	return &trillian.SetMapLeavesResponse{
+		MapRoot: proto.Clone(m.revisions[len(m.revisions)-1].root).(*trillian.SignedMapRoot),
+	}, nil
+}
+
+// GetSignedMapRoot returns the map root of the latest revision.
+func (m *TrillianMap) GetSignedMapRoot(ctx context.Context, in *trillian.GetSignedMapRootRequest, opts ...grpc.CallOption) (*trillian.GetSignedMapRootResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rev, err := m.revision(in.GetMapId(), -1)
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetSignedMapRootResponse{
+		MapRoot: proto.Clone(rev.root).(*trillian.SignedMapRoot),
+	}, nil
+}
+
+// GetSignedMapRootByRevision returns the map root of in.Revision.
+func (m *TrillianMap) GetSignedMapRootByRevision(ctx context.Context, in *trillian.GetSignedMapRootByRevisionRequest, opts ...grpc.CallOption) (*trillian.GetSignedMapRootResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if in.GetRevision() < 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "revision %v < 0", in.GetRevision())
+	}
+	rev, err := m.revision(in.GetMapId(), in.GetRevision())
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetSignedMapRootResponse{
+		MapRoot: proto.Clone(rev.root).(*trillian.SignedMapRoot),
+	}, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/coniks"
+	"golang.org/x/net/context"
+)
+
+const mapID = 7
+
+func index(i int) []byte {
+	h := sha256.Sum256([]byte(fmt.Sprintf("index %v", i)))
+	return h[:]
+}
+
+// verifyLeaves fetches the leaves at revision and verifies their values, their
+// inclusion proofs and the signature of the map root.
+func verifyLeaves(t *testing.T, m *TrillianMap, revision int64, want map[int]string) {
+	ctx := context.Background()
+	indexes := make([][]byte, 0, 4)
+	for i := 0; i < 4; i++ {
+		indexes = append(indexes, index(i))
+	}
+	resp, err := m.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
+		MapId:    mapID,
+		Index:    indexes,
+		Revision: revision,
+	})
+	if err != nil {
+		t.Fatalf("GetLeaves(%v): %v", revision, err)
+	}
+	smr := *resp.GetMapRoot()
+	smr.Signature = nil
+	if err := tcrypto.VerifyObject(m.PublicKey(), smr, resp.GetMapRoot().GetSignature()); err != nil {
+		t.Errorf("VerifyObject(SMR %v): %v", revision, err)
+	}
+	for i, inc := range resp.GetMapLeafInclusion() {
+		value := inc.GetLeaf().GetLeafValue()
+		if got, want := string(value), want[i]; got != want {
+			t.Errorf("GetLeaves(%v): leaf %v: %q, want %q", revision, i, got, want)
+		}
+		if err := merkle.VerifyMapInclusionProof(mapID, index(i), value,
+			smr.GetRootHash(), inc.GetInclusion(), coniks.Default); err != nil {
+			t.Errorf("VerifyMapInclusionProof(%v, leaf %v): %v", revision, i, err)
+		}
+	}
+}
+
+func TestTrillianMap(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeTrillianMapClient(mapID)
+	verifyLeaves(t, m, 0, map[int]string{})
+
+	for _, tc := range []struct {
+		leaves map[int]string
+		seq    int64
+	}{
+		{map[int]string{0: "a", 1: "b"}, 2},
+		{map[int]string{1: "c", 3: "d"}, 5},
+	} {
+		leaves := make([]*trillian.MapLeaf, 0, len(tc.leaves))
+		for i, v := range tc.leaves {
+			leaves = append(leaves, &trillian.MapLeaf{Index: index(i), LeafValue: []byte(v)})
+		}
+		if _, err := m.SetLeaves(ctx, &trillian.SetMapLeavesRequest{
+			MapId:      mapID,
+			Leaves:     leaves,
+			MapperData: &trillian.MapperMetadata{HighestFullyCompletedSeq: tc.seq},
+		}); err != nil {
+			t.Fatalf("SetLeaves(): %v", err)
+		}
+	}
+
+	// Previous revisions remain available.
+	verifyLeaves(t, m, 1, map[int]string{0: "a", 1: "b"})
+	verifyLeaves(t, m, 2, map[int]string{0: "a", 1: "c", 3: "d"})
+	verifyLeaves(t, m, -1, map[int]string{0: "a", 1: "c", 3: "d"})
+
+	latest, err := m.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	if got, want := latest.GetMapRoot().GetMapRevision(), int64(2); got != want {
+		t.Errorf("GetSignedMapRoot(): revision %v, want %v", got, want)
+	}
+	if got, want := latest.GetMapRoot().GetMetadata().GetHighestFullyCompletedSeq(), int64(5); got != want {
+		t.Errorf("GetSignedMapRoot(): HighestFullyCompletedSeq %v, want %v", got, want)
+	}
+	first, err := m.GetSignedMapRootByRevision(ctx, &trillian.GetSignedMapRootByRevisionRequest{MapId: mapID, Revision: 1})
+	if err != nil {
+		t.Fatalf("GetSignedMapRootByRevision(1): %v", err)
+	}
+	if got, want := first.GetMapRoot().GetMetadata().GetHighestFullyCompletedSeq(), int64(2); got != want {
+		t.Errorf("GetSignedMapRootByRevision(1): HighestFullyCompletedSeq %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		req  *trillian.GetMapLeavesRequest
+	}{
+		{"unknown map", &trillian.GetMapLeavesRequest{MapId: mapID + 1, Index: [][]byte{index(0)}}},
+		{"unknown revision", &trillian.GetMapLeavesRequest{MapId: mapID, Index: [][]byte{index(0)}, Revision: 3}},
+		{"short index", &trillian.GetMapLeavesRequest{MapId: mapID, Index: [][]byte{[]byte("short")}}},
+	} {
+		if _, err := m.GetLeaves(ctx, tc.req); err == nil {
+			t.Errorf("GetLeaves(%v) succeeded", tc.desc)
+		}
+	}
+}
+
+func TestTrillianAdmin(t *testing.T) {
+	ctx := context.Background()
+	l := NewFakeTrillianLogClient()
+	m := NewFakeTrillianMapClient(mapID)
+	a := NewFakeTrillianAdminClient(l.Tree(1), m.Tree())
+
+	tree, err := a.GetTree(ctx, &trillian.GetTreeRequest{TreeId: mapID})
+	if err != nil {
+		t.Fatalf("GetTree(%v): %v", mapID, err)
+	}
+	if got, want := tree.GetTreeType(), trillian.TreeType_MAP; got != want {
+		t.Errorf("GetTree(%v): type %v, want %v", mapID, got, want)
+	}
+	if _, err := a.GetTree(ctx, &trillian.GetTreeRequest{TreeId: 2}); err == nil {
+		t.Errorf("GetTree(2) succeeded")
+	}
+	list, err := a.ListTrees(ctx, &trillian.ListTreesRequest{})
+	if err != nil {
+		t.Fatalf("ListTrees(): %v", err)
+	}
+	if got, want := len(list.GetTree()), 2; got != want {
+		t.Errorf("ListTrees(): %v trees, want %v", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/google/keytransparency/core/witness"
+
+	"golang.org/x/net/context"
+)
+
+// Witness is an in-memory witness.Witness. Roots can only be published once
+// per revision, like with a write-once BFTKV.
+type Witness struct {
+	mu    sync.Mutex
+	roots map[string][]byte
+}
+
+// NewFakeWitness returns a fake witness.
+func NewFakeWitness() *Witness {
+	return &Witness{
+		roots: make(map[string][]byte),
+	}
+}
+
+// Publish stores root for revision.
+func (w *Witness) Publish(ctx context.Context, mapID, revision int64, root []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := witness.Key(mapID, revision)
+	if r, ok := w.roots[key]; ok {
+		if !bytes.Equal(r, root) {
+			return witness.ErrAlreadyPublished
+		}
+		return nil
+	}
+	w.roots[key] = append([]byte(nil), root...)
+	return nil
+}
+
+// Lookup returns the root stored for revision.
+func (w *Witness) Lookup(ctx context.Context, mapID, revision int64) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	r, ok := w.roots[witness.Key(mapID, revision)]
+	if !ok {
+		return nil, witness.ErrNotFound
+	}
+	return r, nil
+}
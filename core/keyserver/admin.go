@@ -0,0 +1,260 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"sort"
+
+	"github.com/google/keytransparency/core/authentication"
+	"github.com/google/keytransparency/core/crypto/keymaster"
+	"github.com/google/keytransparency/core/crypto/signatures"
+	"github.com/google/keytransparency/core/crypto/vrf"
+	"github.com/google/keytransparency/core/mutator"
+	"github.com/google/keytransparency/core/mutator/entry"
+	"github.com/google/keytransparency/core/transaction"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authzpb "github.com/google/keytransparency/core/proto/authorization"
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/trillian"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// AdminServer implements the KeyTransparencyAdminService. It updates entries
+// on behalf of users with signing keys held by the server.
+type AdminServer struct {
+	*Server
+	keys *keymaster.KeyMaster
+}
+
+// NewAdminServer creates a new instance of the admin server. keys contains
+// the signing keys the server may use to update entries.
+func NewAdminServer(s *Server, keys *keymaster.KeyMaster) *AdminServer {
+	return &AdminServer{
+		Server: s,
+		keys:   keys,
+	}
+}
+
+// BatchUpdateEntries sets the profiles of multiple users at once. Mutations
+// are signed with the server held key in.KeyId and written in a single
+// transaction. Users whose update failed are reported in the errors and
+// statuses maps of the response.
+func (s *AdminServer) BatchUpdateEntries(ctx context.Context, in *tpb.BatchUpdateEntriesRequest) (*tpb.BatchUpdateEntriesResponse, error) {
+	// Validate proper authentication.
+	sctx, err := s.auth.ValidateCreds(ctx)
+	switch err {
+	case nil:
+		break // Authentication succeeded.
+	case authentication.ErrMissingAuth:
+		return nil, grpc.Errorf(codes.Unauthenticated, "Missing authentication header")
+	default:
+		glog.Warningf("Auth failed: %v", err)
+		return nil, grpc.Errorf(codes.Unauthenticated, "Unauthenticated")
+	}
+	if in.AppId == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Missing app_id")
+	}
+	signer, err := s.keys.Signer(in.KeyId)
+	if err != nil {
+		glog.Warningf("keys.Signer(%v): %v", in.KeyId, err)
+		return nil, grpc.Errorf(codes.InvalidArgument, "Unknown key_id %v", in.KeyId)
+	}
+	pubKey, err := signer.PublicKey()
+	if err != nil {
+		glog.Errorf("signer.PublicKey(): %v", err)
+		return nil, grpc.Errorf(codes.Internal, "Cannot read public key of key_id %v", in.KeyId)
+	}
+
+	// Process users in a deterministic order.
+	userIDs := make([]string, 0, len(in.Users))
+	for userID := range in.Users {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	// Record the errors of failed users together with their status, which
+	// carries the reason of rejected updates.
+	errs := make(map[string]string)
+	statuses := make(map[string]*spb.Status)
+	fail := func(userID string, err error) {
+		errs[userID] = grpc.ErrorDesc(err)
+		if st, ok := status.FromError(err); ok {
+			statuses[userID] = st.Proto()
+		}
+	}
+
+	// Validate proper authorization for each user.
+	authorized := make([]string, 0, len(userIDs))
+	indexes := make([][]byte, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if err := s.authz.IsAuthorized(sctx, s.mapID, in.AppId, userID, authzpb.Permission_WRITE); err != nil {
+			glog.Warningf("Authz failed for %v: %v", userID, err)
+			fail(userID, grpc.Errorf(codes.PermissionDenied, "Unauthorized"))
+			continue
+		}
+		index, _ := s.vrf.Evaluate(vrf.UniqueID(userID, in.AppId))
+		authorized = append(authorized, userID)
+		indexes = append(indexes, index[:])
+	}
+	if len(authorized) == 0 {
+		return &tpb.BatchUpdateEntriesResponse{Errors: errs, Statuses: statuses}, nil
+	}
+
+	leaves, err := s.latestLeaves(ctx, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and sign the mutations.
+	updates := make([]*tpb.UpdateEntryRequest, 0, len(authorized))
+	for i, userID := range authorized {
+		update, err := s.createUpdate(userID, in.AppId, indexes[i],
+			leaves[i].GetLeaf().GetLeafValue(), in.Users[userID].GetData(),
+			signer, pubKey)
+		if err != nil {
+			glog.Warningf("createUpdate(%v): %v", userID, err)
+			fail(userID, err)
+			continue
+		}
+		if update != nil {
+			updates = append(updates, update)
+		}
+	}
+
+	// Save all commitments and mutations to the database in one
+	// transaction.
+	txn, err := s.factory.NewTxn(ctx)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, "Cannot create transaction")
+	}
+	for _, update := range updates {
+		if err := s.writeUpdate(txn, update); err != nil {
+			if err := txn.Rollback(); err != nil {
+				glog.Errorf("Cannot rollback the transaction: %v", err)
+			}
+			return nil, err
+		}
+	}
+	if err := txn.Commit(); err != nil {
+		glog.Errorf("Cannot commit transaction: %v", err)
+		return nil, grpc.Errorf(codes.Internal, "Cannot commit transaction")
+	}
+	return &tpb.BatchUpdateEntriesResponse{Errors: errs, Statuses: statuses}, nil
+}
+
+// latestLeaves returns the leaves of indexes in the latest map revision. The
+// returned leaves are in the same order as indexes.
+func (s *AdminServer) latestLeaves(ctx context.Context, indexes [][]byte) ([]*trillian.MapLeafInclusion, error) {
+	logRoot, err := s.tlog.GetLatestSignedLogRoot(ctx,
+		&trillian.GetLatestSignedLogRootRequest{
+			LogId: s.logID,
+		})
+	if err != nil {
+		glog.Errorf("tlog.GetLatestSignedLogRoot(%v): %v", s.logID, err)
+		return nil, grpc.Errorf(codes.Internal, "Cannot fetch SignedLogRoot")
+	}
+	// Use the log as the athoritative source of the latest revision.
+	revision := logRoot.GetSignedLogRoot().GetTreeSize() - 1
+
+	getResp, err := s.tmap.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
+		MapId:    s.mapID,
+		Index:    indexes,
+		Revision: revision,
+	})
+	if err != nil {
+		glog.Errorf("GetLeaves(): %v", err)
+		return nil, grpc.Errorf(codes.Internal, "Failed fetching map leaves")
+	}
+	if got, want := len(getResp.GetMapLeafInclusion()), len(indexes); got != want {
+		glog.Errorf("GetLeaves() len: %v, want %v", got, want)
+		return nil, grpc.Errorf(codes.Internal, "Failed fetching map leaves")
+	}
+	return getResp.GetMapLeafInclusion(), nil
+}
+
+// createUpdate creates a request setting the profile of userID to data and
+// signs its mutation with signer. New users get pubKey as their only
+// authorized key. If the mutation is a replay of the current value,
+// createUpdate returns a nil request.
+func (s *AdminServer) createUpdate(userID, appID string,
+	index, oldLeaf, data []byte, signer signatures.Signer,
+	pubKey *tpb.PublicKey) (*tpb.UpdateEntryRequest, error) {
+	mutation, err := entry.NewMutation(oldLeaf, index, userID, appID)
+	if err != nil {
+		glog.Errorf("entry.NewMutation(): %v", err)
+		return nil, grpc.Errorf(codes.Internal, "Invalid previous leaf value")
+	}
+	if err := mutation.SetCommitment(data); err != nil {
+		glog.Errorf("SetCommitment(): %v", err)
+		return nil, grpc.Errorf(codes.Internal, "Cannot create commitment")
+	}
+	oldEntry, err := entry.FromLeafValue(oldLeaf)
+	if err != nil {
+		glog.Errorf("entry.FromLeafValue(): %v", err)
+		return nil, grpc.Errorf(codes.Internal, "Invalid previous leaf value")
+	}
+	if len(oldEntry.GetAuthorizedKeys()) == 0 {
+		if err := mutation.ReplaceAuthorizedKeys([]*tpb.PublicKey{pubKey}); err != nil {
+			return nil, grpc.Errorf(codes.Internal, "Cannot set authorized keys")
+		}
+	}
+	req, err := mutation.SerializeAndSign([]signatures.Signer{signer})
+	if err != nil {
+		if err == mutator.ErrUnauthorized {
+			glog.Warningf("Invalid mutation: %v", err)
+			return nil, rejectUpdate(err)
+		}
+		glog.Errorf("SerializeAndSign(): %v", err)
+		return nil, grpc.Errorf(codes.Internal, "Cannot sign mutation")
+	}
+	if err := validateUpdateEntryRequest(req, s.vrf, s.validators); err != nil {
+		glog.Warningf("Invalid UpdateEntryRequest: %v", err)
+		return nil, rejectUpdate(err)
+	}
+	if _, err := s.mutator.Mutate(oldEntry, req.GetEntryUpdate().GetUpdate()); err == mutator.ErrReplay {
+		return nil, nil
+	} else if err != nil {
+		glog.Warningf("Invalid mutation: %v", err)
+		return nil, rejectUpdate(err)
+	}
+	return req, nil
+}
+
+// writeUpdate saves the commitment and the mutation of req as part of txn.
+func (s *AdminServer) writeUpdate(txn transaction.Txn, req *tpb.UpdateEntryRequest) error {
+	update := req.GetEntryUpdate().GetUpdate()
+	e := new(tpb.Entry)
+	if err := proto.Unmarshal(update.GetKeyValue().GetValue(), e); err != nil {
+		glog.Errorf("Error unmarshaling entry: %v", err)
+		return grpc.Errorf(codes.Internal, "Invalid mutation")
+	}
+	committed := req.GetEntryUpdate().GetCommitted()
+	if err := s.committer.WriteTxn(txn, e.GetCommitment(), committed.GetData(), committed.GetKey()); err != nil {
+		glog.Errorf("committer.WriteTxn failed: %v", err)
+		return grpc.Errorf(codes.Internal, "Commitment write error")
+	}
+	if _, err := s.mutations.Write(txn, update); err != nil {
+		glog.Errorf("mutations.Write failed: %v", err)
+		return grpc.Errorf(codes.Internal, "Mutation write error")
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/keytransparency/core/authentication"
+	"github.com/google/keytransparency/core/crypto/keymaster"
+	"github.com/google/keytransparency/core/crypto/signatures"
+	"github.com/google/keytransparency/core/crypto/signatures/factory"
+	"github.com/google/keytransparency/core/crypto/vrf"
+	"github.com/google/keytransparency/core/crypto/vrf/p256"
+	"github.com/google/keytransparency/core/fake"
+	"github.com/google/keytransparency/core/mutator/entry"
+	"github.com/google/keytransparency/core/rejection"
+	"github.com/google/keytransparency/core/transaction"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authzpb "github.com/google/keytransparency/core/proto/authorization"
+	kmpb "github.com/google/keytransparency/core/proto/keymaster"
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+const (
+	adminMapID = 1
+	adminLogID = 2
+	adminAppID = "app"
+)
+
+var errWrite = errors.New("write failed")
+
+// userAuthz grants every permission for all users but the denied ones.
+type userAuthz struct {
+	denied map[string]bool
+}
+
+func (a *userAuthz) IsAuthorized(sctx *authentication.SecurityContext, mapID int64,
+	appID, userID string, permission authzpb.Permission) error {
+	if a.denied[userID] {
+		return errors.New("unauthorized")
+	}
+	return nil
+}
+
+func (*userAuthz) IsLogged(mapID int64, appID string) bool { return false }
+
+// fakeTxn applies writes when it is committed.
+type fakeTxn struct {
+	writes []func()
+}
+
+func (*fakeTxn) Prepare(query string) (*sql.Stmt, error) { return nil, nil }
+
+func (t *fakeTxn) Commit() error {
+	for _, w := range t.writes {
+		w()
+	}
+	t.writes = nil
+	return nil
+}
+
+func (t *fakeTxn) Rollback() error {
+	t.writes = nil
+	return nil
+}
+
+type fakeFactory struct{}
+
+func (fakeFactory) NewTxn(ctx context.Context) (transaction.Txn, error) {
+	return &fakeTxn{}, nil
+}
+
+// fakeMutations fails the failAt-th write, counting from 1.
+type fakeMutations struct {
+	mutations []*tpb.SignedKV
+	writes    int
+	failAt    int
+}
+
+func (m *fakeMutations) ReadRange(txn transaction.Txn, startSequence, endSequence uint64, count int32) (uint64, []*tpb.SignedKV, error) {
+	return 0, nil, nil
+}
+
+func (m *fakeMutations) ReadAll(txn transaction.Txn, startSequence uint64) (uint64, []*tpb.SignedKV, error) {
+	return 0, nil, nil
+}
+
+func (m *fakeMutations) Write(txn transaction.Txn, mutation *tpb.SignedKV) (uint64, error) {
+	m.writes++
+	if m.writes == m.failAt {
+		return 0, errWrite
+	}
+	t := txn.(*fakeTxn)
+	t.writes = append(t.writes, func() { m.mutations = append(m.mutations, mutation) })
+	return uint64(m.writes), nil
+}
+
+type fakeCommitter struct {
+	committed map[string]*tpb.Committed
+	err       error
+}
+
+func (c *fakeCommitter) Write(ctx context.Context, commitment, data, nonce []byte) error {
+	c.committed[string(commitment)] = &tpb.Committed{Key: nonce, Data: data}
+	return c.err
+}
+
+func (c *fakeCommitter) WriteTxn(txn transaction.Txn, commitment, data, nonce []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	t := txn.(*fakeTxn)
+	t.writes = append(t.writes, func() {
+		c.committed[string(commitment)] = &tpb.Committed{Key: nonce, Data: data}
+	})
+	return nil
+}
+
+func (c *fakeCommitter) Read(ctx context.Context, commitment []byte) ([]byte, []byte, error) {
+	committed, ok := c.committed[string(commitment)]
+	if !ok {
+		return nil, nil, nil
+	}
+	return committed.Data, committed.Key, nil
+}
+
+func newSignerPEM(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey(): %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func newSigner(t *testing.T) signatures.Signer {
+	signer, err := factory.NewSignerFromPEM(newSignerPEM(t))
+	if err != nil {
+		t.Fatalf("factory.NewSignerFromPEM(): %v", err)
+	}
+	return signer
+}
+
+// setEntry sets the profile of userID to data, authorized by signer, in a new
+// map revision. It returns the update, whose commitment is not saved.
+func setEntry(t *testing.T, tmap trillian.TrillianMapClient, tlog trillian.TrillianLogClient,
+	vrfKey vrf.PrivateKey, userID string, data []byte, signer signatures.Signer) *tpb.UpdateEntryRequest {
+	ctx := context.Background()
+	index, _ := vrfKey.Evaluate(vrf.UniqueID(userID, adminAppID))
+	mutation, err := entry.NewMutation(nil, index[:], userID, adminAppID)
+	if err != nil {
+		t.Fatalf("NewMutation(): %v", err)
+	}
+	if err := mutation.SetCommitment(data); err != nil {
+		t.Fatalf("SetCommitment(): %v", err)
+	}
+	pubKey, err := signer.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey(): %v", err)
+	}
+	if err := mutation.ReplaceAuthorizedKeys([]*tpb.PublicKey{pubKey}); err != nil {
+		t.Fatalf("ReplaceAuthorizedKeys(): %v", err)
+	}
+	req, err := mutation.SerializeAndSign([]signatures.Signer{signer})
+	if err != nil {
+		t.Fatalf("SerializeAndSign(): %v", err)
+	}
+	oldEntry, _ := entry.FromLeafValue(nil)
+	leaf, err := entry.New().Mutate(oldEntry, req.GetEntryUpdate().GetUpdate())
+	if err != nil {
+		t.Fatalf("Mutate(): %v", err)
+	}
+	resp, err := tmap.SetLeaves(ctx, &trillian.SetMapLeavesRequest{
+		MapId:  adminMapID,
+		Leaves: []*trillian.MapLeaf{{Index: index[:], LeafValue: leaf}},
+	})
+	if err != nil {
+		t.Fatalf("SetLeaves(): %v", err)
+	}
+	queueRoot(t, tlog, resp.GetMapRoot())
+	return req
+}
+
+// queueRoot adds smr to the log, which makes it the latest map revision.
+func queueRoot(t *testing.T, tlog trillian.TrillianLogClient, smr *trillian.SignedMapRoot) {
+	smrJSON, err := json.Marshal(smr)
+	if err != nil {
+		t.Fatalf("json.Marshal(): %v", err)
+	}
+	if _, err := tlog.QueueLeaf(context.Background(), &trillian.QueueLeafRequest{
+		LogId: adminLogID,
+		Leaf:  &trillian.LogLeaf{LeafValue: smrJSON},
+	}); err != nil {
+		t.Fatalf("QueueLeaf(): %v", err)
+	}
+}
+
+func TestBatchUpdateEntries(t *testing.T) {
+	profile := []byte("profile")
+	for _, tc := range []struct {
+		desc        string
+		caller      string
+		appID       string
+		keyID       string // Defaults to the key of the server.
+		users       []string
+		denied      map[string]bool
+		failAt      int   // Fails the failAt-th mutation write.
+		commitErr   error // Fails commitment writes.
+		wantCode    codes.Code
+		wantErrs    []string // Users whose update failed.
+		wantReasons map[string]rejection.Reason
+		wantWritten []string // Users whose update has been saved.
+	}{
+		{desc: "unauthenticated", appID: adminAppID, users: []string{"alice"},
+			wantCode: codes.Unauthenticated},
+		{desc: "missing app_id", caller: "admin", users: []string{"alice"},
+			wantCode: codes.InvalidArgument},
+		{desc: "unknown key_id", caller: "admin", appID: adminAppID, keyID: "unknown", users: []string{"alice"},
+			wantCode: codes.InvalidArgument},
+		{desc: "new users", caller: "admin", appID: adminAppID, users: []string{"alice", "bob"},
+			wantWritten: []string{"alice", "bob"}},
+		{desc: "unauthorized user", caller: "admin", appID: adminAppID, users: []string{"alice", "bob"},
+			denied:   map[string]bool{"bob": true},
+			wantErrs: []string{"bob"}, wantWritten: []string{"alice"}},
+		{desc: "all unauthorized", caller: "admin", appID: adminAppID, users: []string{"alice", "bob"},
+			denied:   map[string]bool{"alice": true, "bob": true},
+			wantErrs: []string{"alice", "bob"}},
+		{desc: "key not authorized by entry", caller: "admin", appID: adminAppID, users: []string{"alice", "carol"},
+			wantErrs: []string{"carol"}, wantWritten: []string{"alice"},
+			wantReasons: map[string]rejection.Reason{"carol": rejection.Unauthorized}},
+		{desc: "resubmitted profile", caller: "admin", appID: adminAppID, users: []string{"dave"},
+			wantWritten: []string{"dave"}},
+		{desc: "mutation write failure", caller: "admin", appID: adminAppID, users: []string{"alice", "bob"},
+			failAt: 2, wantCode: codes.Internal},
+		{desc: "commitment write failure", caller: "admin", appID: adminAppID, users: []string{"alice", "bob"},
+			commitErr: errWrite, wantCode: codes.Internal},
+	} {
+		ctx := callerCtx(tc.caller)
+		tmap := fake.NewFakeTrillianMapClient(adminMapID)
+		tlog := fake.NewFakeTrillianLogClient()
+		vrfKey, _ := p256.GenerateKey()
+		keys := keymaster.New()
+		keyID, err := keys.AddSigningKey(kmpb.SigningKey_ACTIVE, "admin", newSignerPEM(t))
+		if err != nil {
+			t.Fatalf("AddSigningKey(): %v", err)
+		}
+		adminSigner, err := keys.Signer(keyID)
+		if err != nil {
+			t.Fatalf("Signer(): %v", err)
+		}
+		if tc.keyID != "" {
+			keyID = tc.keyID
+		}
+		committer := &fakeCommitter{committed: make(map[string]*tpb.Committed), err: tc.commitErr}
+		mutations := &fakeMutations{failAt: tc.failAt}
+		s := NewAdminServer(New(adminLogID, tlog, adminMapID, tmap, nil, committer, vrfKey,
+			entry.New(), authentication.NewFake(), &userAuthz{denied: tc.denied},
+			fakeFactory{}, mutations), keys)
+
+		// Revision 0 is empty. Carol's entry is only authorized for
+		// another key, dave's entry is set by the server with profile.
+		root, err := tmap.GetSignedMapRoot(context.Background(), &trillian.GetSignedMapRootRequest{MapId: adminMapID})
+		if err != nil {
+			t.Fatalf("GetSignedMapRoot(): %v", err)
+		}
+		queueRoot(t, tlog, root.GetMapRoot())
+		setEntry(t, tmap, tlog, vrfKey, "carol", profile, newSigner(t))
+		setEntry(t, tmap, tlog, vrfKey, "dave", profile, adminSigner)
+
+		in := &tpb.BatchUpdateEntriesRequest{
+			Users: make(map[string]*tpb.UserProfile),
+			AppId: tc.appID,
+			KeyId: keyID,
+		}
+		for _, userID := range tc.users {
+			in.Users[userID] = &tpb.UserProfile{Data: profile}
+		}
+		resp, err := s.BatchUpdateEntries(ctx, in)
+		if got, want := grpc.Code(err), tc.wantCode; got != want {
+			t.Errorf("%v: BatchUpdateEntries(): %v, want %v", tc.desc, err, want)
+		}
+		errUsers := make([]string, 0, len(resp.GetErrors()))
+		for userID := range resp.GetErrors() {
+			errUsers = append(errUsers, userID)
+		}
+		sort.Strings(errUsers)
+		if got, want := errUsers, tc.wantErrs; len(got)+len(want) > 0 && !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: BatchUpdateEntries().Errors: %v, want %v", tc.desc, resp.GetErrors(), want)
+		}
+		for _, userID := range errUsers {
+			st, ok := resp.GetStatuses()[userID]
+			if !ok {
+				t.Errorf("%v: BatchUpdateEntries().Statuses[%v] missing", tc.desc, userID)
+				continue
+			}
+			want, rejected := tc.wantReasons[userID]
+			r, ok := rejection.FromError(status.ErrorProto(st))
+			if ok != rejected || ok && r.Reason != want {
+				t.Errorf("%v: rejection of %v: %v, want %v", tc.desc, userID, r, want)
+			}
+		}
+
+		// Every saved mutation has its commitment saved, and nothing
+		// is saved for a failed batch.
+		if got, want := len(mutations.mutations), len(tc.wantWritten); got != want {
+			t.Errorf("%v: %v mutations written, want %v", tc.desc, got, want)
+		}
+		if got, want := len(committer.committed), len(tc.wantWritten); got != want {
+			t.Errorf("%v: %v commitments written, want %v", tc.desc, got, want)
+		}
+		for i, m := range mutations.mutations {
+			e := new(tpb.Entry)
+			if err := proto.Unmarshal(m.GetKeyValue().GetValue(), e); err != nil {
+				t.Fatalf("proto.Unmarshal(): %v", err)
+			}
+			committed, ok := committer.committed[string(e.GetCommitment())]
+			if !ok {
+				t.Errorf("%v: commitment of mutation %v not written", tc.desc, i)
+				continue
+			}
+			if got, want := committed.GetData(), profile; !bytes.Equal(got, want) {
+				t.Errorf("%v: committed data: %s, want %s", tc.desc, got, want)
+			}
+			index, _ := vrfKey.Evaluate(vrf.UniqueID(tc.wantWritten[i], adminAppID))
+			if got, want := m.GetKeyValue().GetKey(), index[:]; !bytes.Equal(got, want) {
+				t.Errorf("%v: mutation %v has index %x, want index of %v", tc.desc, i, got, tc.wantWritten[i])
+			}
+		}
+	}
+}
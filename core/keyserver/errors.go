@@ -0,0 +1,55 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"github.com/google/keytransparency/core/crypto/commitments"
+	"github.com/google/keytransparency/core/mutator"
+	"github.com/google/keytransparency/core/rejection"
+
+	"google.golang.org/grpc/codes"
+)
+
+// updateReasons maps the errors of rejected updates to their stable reasons.
+var updateReasons = map[error]rejection.Reason{
+	ErrNoAppID:                       rejection.MissingAppID,
+	ErrWrongIndex:                    rejection.WrongIndex,
+	ErrNoCommitted:                   rejection.MissingCommitment,
+	ErrCommittedKeyLen:               rejection.CommitmentKeyTooShort,
+	commitments.ErrInvalidCommitment: rejection.InvalidCommitment,
+	mutator.ErrSize:                  rejection.TooLarge,
+	mutator.ErrPreviousHash:          rejection.PreviousHash,
+	mutator.ErrMissingKey:            rejection.MissingKey,
+	mutator.ErrInvalidSig:            rejection.InvalidSignature,
+	mutator.ErrUnauthorized:          rejection.Unauthorized,
+}
+
+// rejectUpdate returns the InvalidArgument error for an update which failed
+// validation or mutation. The reason of the rejection is carried as status
+// detail. Unexpected errors are reported as malformed requests without
+// details.
+func rejectUpdate(err error) error {
+	if verr, ok := err.(*ValidationError); ok {
+		reason := rejection.InvalidProfile
+		if verr.Err == ErrUnknownApp {
+			reason = rejection.UnknownApp
+		}
+		return rejection.Error(codes.InvalidArgument, reason, verr.AppID, verr.Err.Error())
+	}
+	if reason, ok := updateReasons[err]; ok {
+		return rejection.Error(codes.InvalidArgument, reason, "", err.Error())
+	}
+	return rejection.Error(codes.InvalidArgument, rejection.Malformed, "", "Invalid request")
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/keytransparency/core/mutator"
+	"github.com/google/keytransparency/core/rejection"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRejectUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		err         error
+		wantReason  rejection.Reason
+		wantSubject string
+	}{
+		{ErrWrongIndex, rejection.WrongIndex, ""},
+		{ErrCommittedKeyLen, rejection.CommitmentKeyTooShort, ""},
+		{mutator.ErrPreviousHash, rejection.PreviousHash, ""},
+		{mutator.ErrMissingKey, rejection.MissingKey, ""},
+		{mutator.ErrUnauthorized, rejection.Unauthorized, ""},
+		{&ValidationError{AppID: "pgp", Err: ErrAlgo}, rejection.InvalidProfile, "pgp"},
+		{&ValidationError{AppID: "foo", Err: ErrUnknownApp}, rejection.UnknownApp, "foo"},
+		{errors.New("proto: bad wiretype"), rejection.Malformed, ""},
+	} {
+		err := rejectUpdate(tc.err)
+		if got, want := grpc.Code(err), codes.InvalidArgument; got != want {
+			t.Errorf("rejectUpdate(%v): code %v, want %v", tc.err, got, want)
+		}
+		r, ok := rejection.FromError(err)
+		if !ok {
+			t.Errorf("rejectUpdate(%v): no rejection details", tc.err)
+			continue
+		}
+		if got, want := r.Reason, tc.wantReason; got != want {
+			t.Errorf("rejectUpdate(%v): reason %v, want %v", tc.err, got, want)
+		}
+		if got, want := r.Subject, tc.wantSubject; got != want {
+			t.Errorf("rejectUpdate(%v): subject %v, want %v", tc.err, got, want)
+		}
+	}
+}
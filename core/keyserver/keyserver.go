@@ -16,6 +16,8 @@
 package keyserver
 
 import (
+	"time"
+
 	"github.com/google/keytransparency/core/authentication"
 	"github.com/google/keytransparency/core/authorization"
 	"github.com/google/keytransparency/core/crypto/commitments"
@@ -59,6 +61,12 @@ type Server struct {
 	mutator   mutator.Mutator
 	factory   transaction.Factory
 	mutations mutator.Mutation
+	// readAccess enables authentication and READ authorization of
+	// lookups. Lookups of resources whose policy carries the LOG
+	// permission are recorded by auditor.
+	readAccess bool
+	auditor    authorization.Auditor
+	validators *Validators
 }
 
 // New creates a new instance of the key server.
@@ -75,26 +83,101 @@ func New(logID int64,
 	factory transaction.Factory,
 	mutations mutator.Mutation) *Server {
 	return &Server{
-		logID:     logID,
-		tlog:      tlog,
-		mapID:     mapID,
-		tmap:      tmap,
-		tadmin:    tadmin,
-		committer: committer,
-		vrf:       vrf,
-		mutator:   mutator,
-		auth:      auth,
-		authz:     authz,
-		factory:   factory,
-		mutations: mutations,
+		logID:      logID,
+		tlog:       tlog,
+		mapID:      mapID,
+		tmap:       tmap,
+		tadmin:     tadmin,
+		committer:  committer,
+		vrf:        vrf,
+		mutator:    mutator,
+		auth:       auth,
+		authz:      authz,
+		factory:    factory,
+		mutations:  mutations,
+		validators: DefaultValidators(),
+	}
+}
+
+// EnforceReadAccess requires lookup callers to be authenticated and to hold
+// the READ permission for the looked up profile. Lookups of resources whose
+// policy carries the LOG permission are recorded by auditor.
+func (s *Server) EnforceReadAccess(auditor authorization.Auditor) {
+	s.readAccess = true
+	s.auditor = auditor
+}
+
+// SetValidators sets the registry used to validate the profile data of each
+// app. By default only PGP keys are validated.
+func (s *Server) SetValidators(validators *Validators) {
+	s.validators = validators
+}
+
+// authorizeRead authenticates the caller of a lookup and verifies that the
+// caller may read the profile of userID in appID. It returns the security
+// context of the caller, or nil if read access is not enforced.
+func (s *Server) authorizeRead(ctx context.Context, userID, appID string) (*authentication.SecurityContext, error) {
+	if !s.readAccess {
+		return nil, nil
+	}
+	sctx, err := s.auth.ValidateCreds(ctx)
+	switch err {
+	case nil:
+		break // Authentication succeeded.
+	case authentication.ErrMissingAuth:
+		return nil, grpc.Errorf(codes.Unauthenticated, "Missing authentication header")
+	default:
+		glog.Warningf("Auth failed: %v", err)
+		return nil, grpc.Errorf(codes.Unauthenticated, "Unauthenticated")
+	}
+	if err := s.authz.IsAuthorized(sctx, s.mapID, appID, userID, authzpb.Permission_READ); err != nil {
+		glog.Warningf("Authz failed: %v", err)
+		return nil, grpc.Errorf(codes.PermissionDenied, "Unauthorized")
 	}
+	return sctx, nil
+}
+
+// audit records the lookup of userID's profile at epoch if the policy of the
+// looked up resource carries the LOG permission. Lookups which cannot be
+// recorded fail.
+func (s *Server) audit(ctx context.Context, sctx *authentication.SecurityContext, method, userID, appID string, epoch int64) error {
+	if sctx == nil || s.auditor == nil {
+		return nil
+	}
+	if !s.authz.IsLogged(s.mapID, appID) {
+		return nil
+	}
+	if err := s.auditor.Audit(ctx, &authorization.AccessRecord{
+		Time:   time.Now(),
+		Method: method,
+		Caller: sctx.Identity(),
+		MapID:  s.mapID,
+		AppID:  appID,
+		UserID: userID,
+		Epoch:  epoch,
+	}); err != nil {
+		glog.Errorf("auditor.Audit(): %v", err)
+		return grpc.Errorf(codes.Internal, "Cannot record access")
+	}
+	return nil
 }
 
 // GetEntry returns a user's profile and proof that there is only one object for
 // this user and that it is the same one being provided to everyone else.
 // GetEntry also supports querying past values by setting the epoch field.
 func (s *Server) GetEntry(ctx context.Context, in *tpb.GetEntryRequest) (*tpb.GetEntryResponse, error) {
-	return s.getEntry(ctx, in.UserId, in.AppId, in.FirstTreeSize, -1)
+	sctx, err := s.authorizeRead(ctx, in.UserId, in.AppId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.getEntry(ctx, in.UserId, in.AppId, in.FirstTreeSize, -1)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit(ctx, sctx, "GetEntry", in.UserId, in.AppId, resp.GetSmr().GetMapRevision()); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
 func (s *Server) getEntry(ctx context.Context, userID, appID string, firstTreeSize, revision int64) (*tpb.GetEntryResponse, error) {
@@ -211,6 +294,11 @@ func (s *Server) getEntry(ctx context.Context, userID, appID string, firstTreeSi
 
 // ListEntryHistory returns a list of EntryProofs covering a period of time.
 func (s *Server) ListEntryHistory(ctx context.Context, in *tpb.ListEntryHistoryRequest) (*tpb.ListEntryHistoryResponse, error) {
+	sctx, err := s.authorizeRead(ctx, in.UserId, in.AppId)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get current epoch.
 	resp, err := s.tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{
 		MapId: s.mapID,
@@ -235,6 +323,9 @@ func (s *Server) ListEntryHistory(ctx context.Context, in *tpb.ListEntryHistoryR
 			glog.Errorf("getEntry failed for epoch %v: %v", in.Start+int64(i), err)
 			return nil, grpc.Errorf(codes.Internal, "GetEntry failed")
 		}
+		if err := s.audit(ctx, sctx, "ListEntryHistory", in.UserId, in.AppId, in.Start+int64(i)); err != nil {
+			return nil, err
+		}
 		responses[i] = resp
 	}
 
@@ -272,22 +363,18 @@ func (s *Server) UpdateEntry(ctx context.Context, in *tpb.UpdateEntryRequest) (*
 	// - Index to Key equality in SignedKV.
 	// - Correct profile commitment.
 	// - Correct key formats.
-	if err := validateUpdateEntryRequest(in, s.vrf); err != nil {
+	if err := validateUpdateEntryRequest(in, s.vrf, s.validators); err != nil {
 		glog.Warningf("Invalid UpdateEntryRequest: %v", err)
-		return nil, grpc.Errorf(codes.InvalidArgument, "Invalid request")
+		return nil, rejectUpdate(err)
 	}
 
 	if err := s.saveCommitment(ctx, in.GetEntryUpdate().GetUpdate().GetKeyValue(), in.GetEntryUpdate().Committed); err != nil {
 		return nil, err
 	}
 
-	// Query for the current epoch.
-	req := &tpb.GetEntryRequest{
-		UserId: in.UserId,
-		AppId:  in.AppId,
-		//EpochStart: in.GetEntryUpdate().EpochStart,
-	}
-	resp, err := s.GetEntry(ctx, req)
+	// Query for the current epoch. Writers need not hold the READ
+	// permission, hence getEntry is called directly.
+	resp, err := s.getEntry(ctx, in.UserId, in.AppId, in.FirstTreeSize, -1)
 	if err != nil {
 		glog.Errorf("GetEntry failed: %v", err)
 		return nil, grpc.Errorf(codes.Internal, "Read failed")
@@ -314,7 +401,7 @@ func (s *Server) UpdateEntry(ctx context.Context, in *tpb.UpdateEntryRequest) (*
 		return &tpb.UpdateEntryResponse{Proof: resp}, nil
 	} else if err != nil {
 		glog.Warningf("Invalid mutation: %v", err)
-		return nil, grpc.Errorf(codes.InvalidArgument, "Invalid mutation")
+		return nil, rejectUpdate(err)
 	}
 
 	// Save mutation to the database.
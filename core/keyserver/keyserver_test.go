@@ -0,0 +1,216 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/keytransparency/core/authentication"
+	"github.com/google/keytransparency/core/authorization"
+	"github.com/google/keytransparency/core/crypto/vrf"
+	"github.com/google/keytransparency/core/crypto/vrf/p256"
+	"github.com/google/keytransparency/core/fake"
+	"github.com/google/keytransparency/core/mutator/entry"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/coniks"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	authzpb "github.com/google/keytransparency/core/proto/authorization"
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+// fakeAuthz grants the permissions in perms to every caller and users access
+// to their own profiles. Resources are logged if perms contains LOG.
+type fakeAuthz struct {
+	perms map[authzpb.Permission]bool
+}
+
+func (a *fakeAuthz) IsAuthorized(sctx *authentication.SecurityContext, mapID int64,
+	appID, userID string, permission authzpb.Permission) error {
+	if sctx.Identity() != userID && !a.perms[permission] {
+		return errors.New("unauthorized")
+	}
+	return nil
+}
+
+func (a *fakeAuthz) IsLogged(mapID int64, appID string) bool {
+	return a.perms[authzpb.Permission_LOG]
+}
+
+type fakeAuditor struct {
+	records []*authorization.AccessRecord
+	err     error
+}
+
+func (a *fakeAuditor) Audit(ctx context.Context, record *authorization.AccessRecord) error {
+	a.records = append(a.records, record)
+	return a.err
+}
+
+func callerCtx(caller string) context.Context {
+	if caller == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "FakeCredential "+caller))
+}
+
+func TestAuthorizeRead(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		enforce  bool
+		caller   string
+		perms    map[authzpb.Permission]bool
+		wantCode codes.Code
+	}{
+		{"not enforced", false, "", nil, codes.OK},
+		{"unauthenticated", true, "", nil, codes.Unauthenticated},
+		{"no READ", true, "alice", map[authzpb.Permission]bool{authzpb.Permission_WRITE: true}, codes.PermissionDenied},
+		{"READ", true, "alice", map[authzpb.Permission]bool{authzpb.Permission_READ: true}, codes.OK},
+	} {
+		s := &Server{
+			auth:  authentication.NewFake(),
+			authz: &fakeAuthz{perms: tc.perms},
+		}
+		if tc.enforce {
+			s.EnforceReadAccess(&fakeAuditor{})
+		}
+		_, err := s.authorizeRead(callerCtx(tc.caller), "bob", "app")
+		if got, want := grpc.Code(err), tc.wantCode; got != want {
+			t.Errorf("%v: authorizeRead(): %v, want %v", tc.desc, err, want)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc        string
+		caller      string
+		perms       map[authzpb.Permission]bool
+		auditErr    error
+		wantRecords int
+		wantCode    codes.Code
+	}{
+		{"no LOG", "alice", map[authzpb.Permission]bool{authzpb.Permission_READ: true}, nil, 0, codes.OK},
+		{"self, no LOG", "bob", nil, nil, 0, codes.OK},
+		{"LOG", "alice", map[authzpb.Permission]bool{authzpb.Permission_LOG: true}, nil, 1, codes.OK},
+		{"self, LOG", "bob", map[authzpb.Permission]bool{authzpb.Permission_LOG: true}, nil, 1, codes.OK},
+		{"audit fails", "alice", map[authzpb.Permission]bool{authzpb.Permission_LOG: true}, errors.New("full"), 1, codes.Internal},
+	} {
+		auditor := &fakeAuditor{err: tc.auditErr}
+		s := &Server{
+			mapID: 1,
+			auth:  authentication.NewFake(),
+			authz: &fakeAuthz{perms: tc.perms},
+		}
+		s.EnforceReadAccess(auditor)
+		sctx := authentication.NewSecurityContext(tc.caller)
+		err := s.audit(ctx, sctx, "GetEntry", "bob", "app", 5)
+		if got, want := grpc.Code(err), tc.wantCode; got != want {
+			t.Errorf("%v: audit(): %v, want %v", tc.desc, err, want)
+		}
+		if got, want := len(auditor.records), tc.wantRecords; got != want {
+			t.Fatalf("%v: %v records, want %v", tc.desc, got, want)
+		}
+		if tc.wantRecords == 0 {
+			continue
+		}
+		r := auditor.records[0]
+		if r.Caller != tc.caller || r.UserID != "bob" || r.AppID != "app" || r.Epoch != 5 || r.MapID != 1 {
+			t.Errorf("%v: record %+v does not match the lookup", tc.desc, r)
+		}
+	}
+}
+
+func TestGetEntry(t *testing.T) {
+	ctx := context.Background()
+	profile := []byte("profile")
+	tmap := fake.NewFakeTrillianMapClient(adminMapID)
+	tlog := fake.NewFakeTrillianLogClient()
+	vrfKey, vrfPub := p256.GenerateKey()
+	committer := &fakeCommitter{committed: make(map[string]*tpb.Committed)}
+	s := New(adminLogID, tlog, adminMapID, tmap, nil, committer, vrfKey,
+		entry.New(), authentication.NewFake(), &fakeAuthz{}, fakeFactory{}, &fakeMutations{})
+
+	// Revision 0 is empty. Alice's profile is set in revision 1, carol's
+	// in revision 2 without saving its commitment.
+	root, err := tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: adminMapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	queueRoot(t, tlog, root.GetMapRoot())
+	req := setEntry(t, tmap, tlog, vrfKey, "alice", profile, newSigner(t))
+	e := new(tpb.Entry)
+	if err := proto.Unmarshal(req.GetEntryUpdate().GetUpdate().GetKeyValue().GetValue(), e); err != nil {
+		t.Fatalf("proto.Unmarshal(): %v", err)
+	}
+	committer.committed[string(e.GetCommitment())] = req.GetEntryUpdate().GetCommitted()
+	setEntry(t, tmap, tlog, vrfKey, "carol", profile, newSigner(t))
+
+	for _, tc := range []struct {
+		desc          string
+		userID        string
+		firstTreeSize int64
+		wantCode      codes.Code
+		wantProfile   []byte
+	}{
+		{desc: "existing profile", userID: "alice", wantProfile: profile},
+		{desc: "absent profile", userID: "bob"},
+		{desc: "consistency proof", userID: "alice", firstTreeSize: 1, wantProfile: profile},
+		{desc: "missing commitment", userID: "carol", wantCode: codes.NotFound},
+	} {
+		resp, err := s.GetEntry(ctx, &tpb.GetEntryRequest{
+			UserId:        tc.userID,
+			AppId:         adminAppID,
+			FirstTreeSize: tc.firstTreeSize,
+		})
+		if got, want := grpc.Code(err), tc.wantCode; got != want {
+			t.Errorf("%v: GetEntry(): %v, want %v", tc.desc, err, want)
+		}
+		if err != nil {
+			continue
+		}
+		if got, want := resp.GetCommitted().GetData(), tc.wantProfile; !bytes.Equal(got, want) {
+			t.Errorf("%v: GetEntry().Committed.Data: %s, want %s", tc.desc, got, want)
+		}
+		index, err := vrfPub.ProofToHash(vrf.UniqueID(tc.userID, adminAppID), resp.GetVrfProof())
+		if err != nil {
+			t.Errorf("%v: ProofToHash(): %v", tc.desc, err)
+		}
+		smr := resp.GetSmr()
+		if got, want := smr.GetMapRevision(), int64(2); got != want {
+			t.Errorf("%v: GetEntry().Smr.MapRevision: %v, want %v", tc.desc, got, want)
+		}
+		if err := merkle.VerifyMapInclusionProof(adminMapID, index[:], resp.GetLeafProof().GetLeaf().GetLeafValue(),
+			smr.GetRootHash(), resp.GetLeafProof().GetInclusion(), coniks.Default); err != nil {
+			t.Errorf("%v: VerifyMapInclusionProof(): %v", tc.desc, err)
+		}
+		if got, want := resp.GetLogRoot().GetTreeSize(), int64(3); got != want {
+			t.Errorf("%v: GetEntry().LogRoot.TreeSize: %v, want %v", tc.desc, got, want)
+		}
+		if got, want := len(resp.GetLogConsistency()) > 0, tc.firstTreeSize != 0; got != want {
+			t.Errorf("%v: GetEntry().LogConsistency: %x, want a proof: %v", tc.desc, resp.GetLogConsistency(), want)
+		}
+	}
+}
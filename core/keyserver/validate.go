@@ -52,26 +52,10 @@ var (
 	ErrInvalidStart = errors.New("invalid start epoch")
 )
 
-// validateKey verifies:
-// - appID is present.
-// - Key is valid for its format.
-func validateKey(userID, appID string, key []byte) error {
-	if appID == "" {
-		return ErrNoAppID
-	}
-	if appID == PGPAppID {
-		pgpUserID := fmt.Sprintf("<%v>", userID)
-		if _, err := validatePGP(pgpUserID, bytes.NewBuffer(key)); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // validateUpdateEntryRequest verifies
 // - Commitment in SignedEntryUpdate matches the serialized profile.
-// - Profile is a valid.
-func validateUpdateEntryRequest(in *tpb.UpdateEntryRequest, vrfPriv vrf.PrivateKey) error {
+// - Profile is a valid according to the validator of the app.
+func validateUpdateEntryRequest(in *tpb.UpdateEntryRequest, vrfPriv vrf.PrivateKey, validators *Validators) error {
 	kv := in.GetEntryUpdate().GetUpdate().GetKeyValue()
 	entry := new(tpb.Entry)
 	if err := proto.Unmarshal(kv.Value, entry); err != nil {
@@ -96,7 +80,7 @@ func validateUpdateEntryRequest(in *tpb.UpdateEntryRequest, vrfPriv vrf.PrivateK
 		return err
 	}
 
-	if err := validateKey(in.GetUserId(), in.GetAppId(), committed.GetData()); err != nil {
+	if err := validators.Validate(in.GetUserId(), in.GetAppId(), committed.GetData()); err != nil {
 		return err
 	}
 	return nil
@@ -15,14 +15,17 @@
 package keyserver
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"reflect"
+	"strings"
 	"time"
 
-	"golang.org/x/crypto/openpgp"
-	"golang.org/x/crypto/openpgp/packet"
-	"golang.org/x/crypto/openpgp/s2k"
+	"github.com/keybase/go-crypto/openpgp"
+	"github.com/keybase/go-crypto/openpgp/packet"
+	"github.com/keybase/go-crypto/openpgp/s2k"
 )
 
 var (
@@ -44,6 +47,13 @@ var (
 	ErrExpiredSig = errors.New("pgp: expired signature")
 	// ErrAlgo occurs when unsupported algorithms are used in a signature packet.
 	ErrAlgo = errors.New("pgp: unsupported algorithm")
+	// ErrKeySize occurs when an RSA key is smaller than allowed.
+	ErrKeySize = errors.New("pgp: key too small")
+	// ErrNoExpiry occurs when a key does not expire although the policy
+	// requires an expiration.
+	ErrNoExpiry = errors.New("pgp: key expiration required")
+	// ErrLifetime occurs when a key expires later than allowed.
+	ErrLifetime = errors.New("pgp: key lifetime too long")
 	// requiredSymmetric represents the required symmetric algorithm. 9 is
 	// AES with 256-bit key.
 	// More details here: https://tools.ietf.org/html/rfc4880#section-9.2.
@@ -52,20 +62,126 @@ var (
 	// is SHA384, 10 is SHA512, and 11 is SHA224.
 	// More details here: https://tools.ietf.org/html/rfc4880#section-9.4.
 	requiredHash = map[uint8]bool{8: true, 9: true, 10: true, 11: true}
+	// pgpAlgos maps the names of public key algorithms to their IDs. ECDH
+	// covers both NIST curves and Curve25519, EdDSA is used for Ed25519
+	// keys.
+	pgpAlgos = map[string]packet.PublicKeyAlgorithm{
+		"rsa":     packet.PubKeyAlgoRSA,
+		"dsa":     packet.PubKeyAlgoDSA,
+		"elgamal": packet.PubKeyAlgoElGamal,
+		"ecdh":    packet.PubKeyAlgoECDH,
+		"ecdsa":   packet.PubKeyAlgoECDSA,
+		"eddsa":   packet.PubKeyAlgoEdDSA,
+	}
 )
 
+// UIDMatch selects how the user IDs of a key are matched against the user ID
+// of the profile.
+type UIDMatch int
+
+const (
+	// UIDExact requires exactly one user ID, which equals "<userID>".
+	UIDExact UIDMatch = iota
+	// UIDEmail requires every user ID to have the email address userID,
+	// e.g. "Alice <alice@example.com>".
+	UIDEmail
+	// UIDDomain requires one user ID with the email address userID. Other
+	// user IDs must have email addresses in the domain of userID.
+	UIDDomain
+)
+
+// ParseUIDMatch parses the name of a UIDMatch: exact, email or domain.
+func ParseUIDMatch(s string) (UIDMatch, error) {
+	switch s {
+	case "exact":
+		return UIDExact, nil
+	case "email":
+		return UIDEmail, nil
+	case "domain":
+		return UIDDomain, nil
+	default:
+		return 0, fmt.Errorf("unknown user ID match mode %q", s)
+	}
+}
+
+// ParsePGPAlgos parses a comma separated list of public key algorithm names:
+// rsa, dsa, elgamal, ecdh, ecdsa and eddsa.
+func ParsePGPAlgos(s string) ([]packet.PublicKeyAlgorithm, error) {
+	var algos []packet.PublicKeyAlgorithm
+	for _, name := range strings.Split(s, ",") {
+		if name == "" {
+			continue
+		}
+		algo, ok := pgpAlgos[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown public key algorithm %q", name)
+		}
+		algos = append(algos, algo)
+	}
+	return algos, nil
+}
+
+// PGPPolicy configures which OpenPGP keys are accepted. Keys using algorithms
+// the OpenPGP library cannot parse are rejected regardless of the policy.
+type PGPPolicy struct {
+	// KeyAlgos are the accepted algorithms of the primary key.
+	KeyAlgos []packet.PublicKeyAlgorithm
+	// SubkeyAlgos are the accepted algorithms of the encryption subkey.
+	SubkeyAlgos []packet.PublicKeyAlgorithm
+	// MinRSABits is the minimum size of RSA keys.
+	MinRSABits int
+	// PreferredSymmetric is the exact set of preferred symmetric algorithms
+	// self signatures must declare.
+	PreferredSymmetric map[uint8]bool
+	// PreferredHash is the exact set of preferred hash functions self
+	// signatures must declare. Signatures must use one of them.
+	PreferredHash map[uint8]bool
+	// RequireExpiry requires the primary key and subkey to expire.
+	RequireExpiry bool
+	// MaxLifetime is the maximum time between the creation and expiration
+	// of the primary key and subkey. Zero means unlimited.
+	MaxLifetime time.Duration
+	// UIDMatch selects how user IDs are matched against the user.
+	UIDMatch UIDMatch
+}
+
+// DefaultPGPPolicy returns the policy accepting ECDSA and EdDSA keys with an
+// ECDH subkey and a single user ID "<userID>".
+func DefaultPGPPolicy() *PGPPolicy {
+	return &PGPPolicy{
+		KeyAlgos:           []packet.PublicKeyAlgorithm{packet.PubKeyAlgoECDSA, packet.PubKeyAlgoEdDSA},
+		SubkeyAlgos:        []packet.PublicKeyAlgorithm{packet.PubKeyAlgoECDH},
+		MinRSABits:         2048,
+		PreferredSymmetric: requiredSymmetric,
+		PreferredHash:      requiredHash,
+		UIDMatch:           UIDExact,
+	}
+}
+
+// Validate verifies that data is an OpenPGP key ring for userID which is
+// accepted by the policy.
+func (p *PGPPolicy) Validate(userID string, data []byte) error {
+	_, err := p.validate(fmt.Sprintf("<%v>", userID), userID, bytes.NewReader(data))
+	return err
+}
+
 // Fingerprint is the type used to identify keys.
 type Fingerprint [20]byte
 
-// validatePGP verifies that there is
+// validatePGP validates key with the default policy. userID is the expected
+// OpenPGP user ID.
+func validatePGP(userID string, key io.Reader) (*Fingerprint, error) {
+	return DefaultPGPPolicy().validate(userID, "", key)
+}
+
+// validate verifies that there is
 // - One entity in the key ring.
-// - One userID packet that matches the userID arg exactly.
-// - One signature with the expected algorithm choices.
+// - User ID packets that match pgpUserID or email, depending on p.UIDMatch.
+// - One signature per user ID with the expected algorithm choices.
 // - Signatures are within their valididty periods.
-// - TODO: parameter checks for public key.
+// - Keys use accepted algorithms and sizes and expire as required.
 // returns fingerprint, error
-//
-func validatePGP(userID string, key io.Reader) (*Fingerprint, error) {
+func (p *PGPPolicy) validate(pgpUserID, email string, key io.Reader) (*Fingerprint, error) {
 	// Verify signatures, check revocation.
 	entityList, err := openpgp.ReadKeyRing(key)
 	if err != nil {
@@ -76,44 +192,81 @@ func validatePGP(userID string, key io.Reader) (*Fingerprint, error) {
 		return nil, ErrEntityCount
 	}
 	entity := entityList[0]
+	// The OpenPGP library skips subkeys with invalid binding signatures.
+	// Reject them like other invalid signatures.
+	if len(entity.BadSubkeys) > 0 {
+		return nil, entity.BadSubkeys[0].Err
+	}
 
-	// Only allow one identity / username.
-	if got, want := len(entity.Identities), 1; got != want {
+	// Only allow one identity / username, unless user IDs are matched by
+	// email address.
+	if len(entity.Identities) == 0 ||
+		(p.UIDMatch == UIDExact && len(entity.Identities) != 1) {
 		return nil, ErrEntityCount
 	}
 	// No revocations allowed.
-	if got, want := len(entity.Revocations), 0; want != got {
+	if got, want := len(entity.Revocations)+len(entity.UnverifiedRevocations), 0; want != got {
 		return nil, ErrRevocationCount
 	}
 	// Verify the UserId.
-	if err := verifyIdentities(entity.Identities, userID); err != nil {
+	if err := p.verifyIdentities(entity, pgpUserID, email); err != nil {
 		return nil, err
 	}
 
 	// Verify subkeys.
-	if err := verifySubkeys(entity.Subkeys); err != nil {
+	if err := p.verifySubkeys(entity.Subkeys); err != nil {
 		return nil, err
 	}
-	// Only accept ECC keys.
-	if got, want := entity.PrimaryKey.PubKeyAlgo, packet.PubKeyAlgoECDSA; got != want {
-		return nil, ErrAlgo
+	// Verify the primary key.
+	if err := p.verifyKey(entity.PrimaryKey, p.KeyAlgos); err != nil {
+		return nil, err
 	}
 	fingerprint := Fingerprint(entity.PrimaryKey.Fingerprint)
 	return &fingerprint, nil
 }
 
-func verifyIdentities(identities map[string]*openpgp.Identity, userID string) error {
+// verifyUserIDs verifies that the user IDs match the user.
+func (p *PGPPolicy) verifyUserIDs(identities map[string]*openpgp.Identity, pgpUserID, email string) error {
+	if p.UIDMatch == UIDExact {
+		for _, id := range identities {
+			if got, want := id.UserId.Id, pgpUserID; got != want {
+				return ErrUserID
+			}
+		}
+		return nil
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	found := false
 	for _, id := range identities {
-		if got, want := id.UserId.Id, userID; got != want {
+		switch {
+		case strings.EqualFold(id.UserId.Email, email):
+			found = true
+		case p.UIDMatch == UIDDomain && strings.HasSuffix(strings.ToLower(id.UserId.Email), "@"+strings.ToLower(domain)):
+		default:
 			return ErrUserID
 		}
+	}
+	if !found {
+		return ErrUserID
+	}
+	return nil
+}
+
+func (p *PGPPolicy) verifyIdentities(entity *openpgp.Entity, pgpUserID, email string) error {
+	if err := p.verifyUserIDs(entity.Identities, pgpUserID, email); err != nil {
+		return err
+	}
+	for _, id := range entity.Identities {
 		if id.SelfSignature == nil {
 			return ErrMissingSelfSig
 		}
-		if err := verifyIdentityMetadata(id); err != nil {
+		if id.Revocation != nil {
+			return ErrRevocationCount
+		}
+		if err := p.verifyIdentityMetadata(id); err != nil {
 			return err
 		}
-		if err := verifyIdentityAlgo(id); err != nil {
+		if err := p.verifyIdentityAlgo(id); err != nil {
 			return err
 		}
 		// No extra signatures allowed.
@@ -124,11 +277,14 @@ func verifyIdentities(identities map[string]*openpgp.Identity, userID string) er
 	return nil
 }
 
-func verifyIdentityMetadata(id *openpgp.Identity) error {
+func (p *PGPPolicy) verifyIdentityMetadata(id *openpgp.Identity) error {
 	// Verify timestamps.
 	if id.SelfSignature.KeyExpired(time.Now()) {
 		return ErrExpiredSig
 	}
+	if err := p.verifyLifetime(id.SelfSignature); err != nil {
+		return err
+	}
 	// Verify flags.
 	if !id.SelfSignature.FlagCertify || !id.SelfSignature.FlagSign {
 		return ErrMissingSelfSig
@@ -139,47 +295,46 @@ func verifyIdentityMetadata(id *openpgp.Identity) error {
 	return nil
 }
 
-func verifyIdentityAlgo(id *openpgp.Identity) error {
+func (p *PGPPolicy) verifyIdentityAlgo(id *openpgp.Identity) error {
 	// Verify encryption types. AES-256
-	if got, want := id.SelfSignature.PreferredSymmetric, requiredSymmetric; !setEquals(want, got) {
+	if got, want := id.SelfSignature.PreferredSymmetric, p.PreferredSymmetric; !setEquals(want, got) {
 		return ErrAlgo
 	}
 	// Verify preferred hash types.
-	if got, want := id.SelfSignature.PreferredHash, requiredHash; !setEquals(want, got) {
+	if got, want := id.SelfSignature.PreferredHash, p.PreferredHash; !setEquals(want, got) {
 		return ErrAlgo
 	}
 	// Verify that hash is one of the preferred hash types.
-	hashID, ok := s2k.HashToHashId(id.SelfSignature.Hash)
-	if !ok {
-		return ErrAlgo
-	}
-	if _, ok := requiredHash[hashID]; !ok {
-		return ErrAlgo
-	}
-	return nil
+	return p.verifyHash(id.SelfSignature)
 }
 
-func verifySubkeys(subkeys []openpgp.Subkey) error {
+func (p *PGPPolicy) verifySubkeys(subkeys []openpgp.Subkey) error {
 	// Only allow one subkey.
 	if got, want := len(subkeys), 1; want != got {
 		return ErrSubkeyCount
 	}
 	for _, subkey := range subkeys {
-		if err := verifySubkeyMetadata(subkey); err != nil {
+		if subkey.Revocation != nil {
+			return ErrRevocationCount
+		}
+		if err := p.verifySubkeyMetadata(subkey); err != nil {
 			return err
 		}
-		if err := verifySubkeyAlgo(subkey); err != nil {
+		if err := p.verifySubkeyAlgo(subkey); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func verifySubkeyMetadata(subkey openpgp.Subkey) error {
+func (p *PGPPolicy) verifySubkeyMetadata(subkey openpgp.Subkey) error {
 	// Verify expiration.
 	if subkey.Sig.KeyExpired(time.Now()) {
 		return ErrExpiredSig
 	}
+	if err := p.verifyLifetime(subkey.Sig); err != nil {
+		return err
+	}
 	// Verify flags.
 	if subkey.Sig.FlagCertify || subkey.Sig.FlagSign {
 		return ErrMissingSubkey
@@ -190,22 +345,70 @@ func verifySubkeyMetadata(subkey openpgp.Subkey) error {
 	return nil
 }
 
-func verifySubkeyAlgo(subkey openpgp.Subkey) error {
-	// Only accept ECC keys.
-	if got, want := subkey.PublicKey.PubKeyAlgo, packet.PubKeyAlgoECDH; got != want {
-		return ErrAlgo
+func (p *PGPPolicy) verifySubkeyAlgo(subkey openpgp.Subkey) error {
+	if err := p.verifyKey(subkey.PublicKey, p.SubkeyAlgos); err != nil {
+		return err
 	}
 	// Verify that hash is one of the preferred hash types.
-	hashID, ok := s2k.HashToHashId(subkey.Sig.Hash)
+	return p.verifyHash(subkey.Sig)
+}
+
+// verifyKey verifies that key uses one of algos and is large enough.
+func (p *PGPPolicy) verifyKey(key *packet.PublicKey, algos []packet.PublicKeyAlgorithm) error {
+	if !containsAlgo(algos, key.PubKeyAlgo) {
+		return ErrAlgo
+	}
+	switch key.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSAEncryptOnly, packet.PubKeyAlgoRSASignOnly:
+		bits, err := key.BitLength()
+		if err != nil {
+			return err
+		}
+		if int(bits) < p.MinRSABits {
+			return ErrKeySize
+		}
+	}
+	return nil
+}
+
+// verifyHash verifies that sig uses one of the preferred hash types.
+func (p *PGPPolicy) verifyHash(sig *packet.Signature) error {
+	hashID, ok := s2k.HashToHashId(sig.Hash)
 	if !ok {
 		return ErrAlgo
 	}
-	if _, ok := requiredHash[hashID]; !ok {
+	if _, ok := p.PreferredHash[hashID]; !ok {
 		return ErrAlgo
 	}
 	return nil
 }
 
+// verifyLifetime verifies the key expiration set by sig.
+func (p *PGPPolicy) verifyLifetime(sig *packet.Signature) error {
+	if sig.KeyLifetimeSecs == nil || *sig.KeyLifetimeSecs == 0 {
+		if p.RequireExpiry {
+			return ErrNoExpiry
+		}
+		if p.MaxLifetime > 0 {
+			return ErrLifetime
+		}
+		return nil
+	}
+	if lifetime := time.Duration(*sig.KeyLifetimeSecs) * time.Second; p.MaxLifetime > 0 && lifetime > p.MaxLifetime {
+		return ErrLifetime
+	}
+	return nil
+}
+
+func containsAlgo(algos []packet.PublicKeyAlgorithm, algo packet.PublicKeyAlgorithm) bool {
+	for _, a := range algos {
+		if a == algo {
+			return true
+		}
+	}
+	return false
+}
+
 // setEquals performs a set equality test between a and b.
 func setEquals(aset map[uint8]bool, b []uint8) bool {
 	// Catch duplicates in b
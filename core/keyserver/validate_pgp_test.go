@@ -15,11 +15,15 @@
 package keyserver
 
 import (
+	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
-	"golang.org/x/crypto/openpgp/armor"
-	"golang.org/x/crypto/openpgp/errors"
+	"github.com/keybase/go-crypto/openpgp/armor"
+	"github.com/keybase/go-crypto/openpgp/errors"
+	"github.com/keybase/go-crypto/openpgp/packet"
 )
 
 const (
@@ -258,6 +262,40 @@ ESFbpA5UETgojzviA6Q/jz6c0N1cHTEQD2MnTsk8xRSnRsYG6AL96f4i6cwpdV2I
 CW4s/mR2ZILuKKsS6WxY8Q8AVeKMW4cXWJZO/cMwok9Gk8oZORdWr8AkVxOLfvf/
 aCOr+QE=
 =d3vQ
+-----END PGP PUBLIC KEY BLOCK-----`
+	// domainGood has the user IDs "Alice <alice@good.com>" and
+	// "Bob <bob@good.com>".
+	domainGood = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mFIEatLO9xMIKoZIzj0DAQcCAwQsWGODYUE4cWGvGvRvAwXZqX6kotimAb19Zb+t
+n4Y+VQBq8kdTG1NhM7MdC6RtUCaEM6Qx9mHBLdxmrU2KkjMotBZBbGljZSA8YWxp
+Y2VAZ29vZC5jb20+iIoEExMIADIWIQTunLayjGPVsnwV4e47Jf2u/pSqGgUCatLO
+9wIbAwILCQUVCAkKCwIWAAIeAQIXgAAKCRA7Jf2u/pSqGn0YAPwMiF4fDvOJm0tQ
+1/7Op69kNId2jIJPdvrXpfPfxPxB9AD/SMpFuX2k79180EppSJHWF7Z49+oQn2YT
+9h5z6O+YzCa0EkJvYiA8Ym9iQGdvb2QuY29tPoiKBBMTCAAyFiEE7py2soxj1bJ8
+FeHuOyX9rv6UqhoFAmrSzvcCGwMCCwkFFQgJCgsCFgACHgECF4AACgkQOyX9rv6U
+qhrkiQD9GIGXPlhcggyfw2Y39oGvVC1C4X58aYwCipGqMxIwFK0BAPyvAvrB2XhA
+AEFWJEZYFRgZ2fPxqIzWzZBDdbAA6rysuFYEatLO9xIIKoZIzj0DAQcCAwS36Yyc
+l4mFUUym/50WBsTCNdX0W3LI59yxRBO/gTBfMDy4TwYP4SlEekZfmB2guVRzg+DP
+dU1c7Ud+Wol9WFf8AwEIB4h4BBgTCAAgFiEE7py2soxj1bJ8FeHuOyX9rv6UqhoF
+AmrSzvcCGwwACgkQOyX9rv6UqhpF+QD7BSkDk7BDqjpw7fAJYrw4xXGT1+QKWElE
+Mhvt3Yb0N4wBAKD+ezFbJo/tX6QhErKuy4hJekqcAeAGYBJaFcLCJkeS
+=+Psy
+-----END PGP PUBLIC KEY BLOCK-----`
+	// edGood is an Ed25519 key with a Curve25519 encryption subkey and the
+	// user ID "<ed@good.com>".
+	edGood = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatLZRBYJKwYBBAHaRw8BAQdAL+1+Wo6jNdfmZiSeBJD90n7yBAFPUTBlMo0u
+3T7nPEC0DTxlZEBnb29kLmNvbT6IhwQTFggALxYhBJNBbxh7+4oTsMP0g7lA0kVY
+6Z8zBQJq0tlEAhsDAgsJBRUICQoLAh4BAheAAAoJELlA0kVY6Z8zxmABAN4hkebC
++lB2M1Ydpo7a0ZaMLY7hY4Ex2GGgSvaW/lRUAQCmxFFujzQbjkN9IhVfhyJrUwlb
+cVsxk/EeCdpUfSlMDLg4BGrS2UQSCisGAQQBl1UBBQEBB0DMJD3+eH5jgCOlkoL4
+1IV37D+5SxuvSKJIBxdHb3CUbgMBCAeIeAQYFggAIBYhBJNBbxh7+4oTsMP0g7lA
+0kVY6Z8zBQJq0tlEAhsMAAoJELlA0kVY6Z8znVkA/idJkaXQmpLuKrIGYE6+8eyy
+TvBFmvVFq9MEmH4CakyHAQDgkazdSHFAg0POT4DYhBpjCSLpqzQCEMWw1JE8Jlew
+BQ==
+=Zvqq
 -----END PGP PUBLIC KEY BLOCK-----`
 )
 
@@ -269,6 +307,7 @@ func TestGoodKey(t *testing.T) {
 		want   error
 	}{
 		{"eccGood", eccGood, "<ecc@good.com>", nil},
+		{"edGood", edGood, "<ed@good.com>", nil},
 	} {
 		block, err := armor.Decode(strings.NewReader(test.key))
 		if err != nil {
@@ -297,7 +336,7 @@ func TestInvalidKeys(t *testing.T) {
 		{"missingCrossSignature", missingCrossSignature, "invalid-signing-subkeys",
 			errors.StructuralError("subkey signature invalid: openpgp: invalid data: signing subkey is missing cross-signature")},
 		{"invalidCrossSignature", invalidCrossSignature, "invalid-signing-subkeys",
-			errors.StructuralError("subkey signature invalid: openpgp: invalid data: error while verifying cross-signature: openpgp: invalid signature: hash tag doesn't match")},
+			errors.StructuralError("subkey signature invalid: openpgp: invalid data: error while verifying cross-signature: openpgp: invalid signature: RSA verification failure")},
 		{"invalidSubpacketLen", invalidSubpacketLength, "", errors.StructuralError("signature subpacket truncated")},
 	} {
 		block, err := armor.Decode(strings.NewReader(test.key))
@@ -308,3 +347,90 @@ func TestInvalidKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestPGPPolicy(t *testing.T) {
+	block, err := armor.Decode(strings.NewReader(eccGood))
+	if err != nil {
+		t.Fatalf("armor.Decode(): %v", err)
+	}
+	key, err := ioutil.ReadAll(block.Body)
+	if err != nil {
+		t.Fatalf("ReadAll(): %v", err)
+	}
+	for _, test := range []struct {
+		label  string
+		policy func(p *PGPPolicy)
+		userID string
+		want   error
+	}{
+		{"default", func(p *PGPPolicy) {}, "ecc@good.com", nil},
+		{"wrong user", func(p *PGPPolicy) {}, "ecc@bad.com", ErrUserID},
+		{"email match", func(p *PGPPolicy) { p.UIDMatch = UIDEmail }, "ECC@good.com", nil},
+		{"email mismatch", func(p *PGPPolicy) { p.UIDMatch = UIDEmail }, "other@good.com", ErrUserID},
+		{"domain mismatch", func(p *PGPPolicy) { p.UIDMatch = UIDDomain }, "ecc@other.com", ErrUserID},
+		{"key algo", func(p *PGPPolicy) { p.KeyAlgos = []packet.PublicKeyAlgorithm{packet.PubKeyAlgoRSA} }, "ecc@good.com", ErrAlgo},
+		{"subkey algo", func(p *PGPPolicy) { p.SubkeyAlgos = []packet.PublicKeyAlgorithm{packet.PubKeyAlgoRSA} }, "ecc@good.com", ErrAlgo},
+		{"require expiry", func(p *PGPPolicy) { p.RequireExpiry = true }, "ecc@good.com", ErrNoExpiry},
+		{"unlimited lifetime", func(p *PGPPolicy) { p.MaxLifetime = 2 * 365 * 24 * time.Hour }, "ecc@good.com", ErrLifetime},
+	} {
+		policy := DefaultPGPPolicy()
+		test.policy(policy)
+		if got := policy.Validate(test.userID, key); got != test.want {
+			t.Errorf("%v: Validate(%v): %v, want %v", test.label, test.userID, got, test.want)
+		}
+	}
+}
+
+func TestPGPPolicyUserIDs(t *testing.T) {
+	block, err := armor.Decode(strings.NewReader(domainGood))
+	if err != nil {
+		t.Fatalf("armor.Decode(): %v", err)
+	}
+	key, err := ioutil.ReadAll(block.Body)
+	if err != nil {
+		t.Fatalf("ReadAll(): %v", err)
+	}
+	for _, test := range []struct {
+		label    string
+		uidMatch UIDMatch
+		userID   string
+		want     error
+	}{
+		{"exact", UIDExact, "alice@good.com", ErrEntityCount},
+		{"email", UIDEmail, "alice@good.com", ErrUserID},
+		{"domain", UIDDomain, "alice@good.com", nil},
+		{"domain second user ID", UIDDomain, "bob@good.com", nil},
+		{"domain case", UIDDomain, "Bob@Good.com", nil},
+		{"domain missing user ID", UIDDomain, "carol@good.com", ErrUserID},
+		{"domain mismatch", UIDDomain, "alice@other.com", ErrUserID},
+	} {
+		policy := DefaultPGPPolicy()
+		policy.UIDMatch = test.uidMatch
+		if got := policy.Validate(test.userID, key); got != test.want {
+			t.Errorf("%v: Validate(%v): %v, want %v", test.label, test.userID, got, test.want)
+		}
+	}
+}
+
+func TestParsePGPAlgos(t *testing.T) {
+	for _, test := range []struct {
+		algos string
+		want  []packet.PublicKeyAlgorithm
+		ok    bool
+	}{
+		{"", nil, true},
+		{"ecdsa", []packet.PublicKeyAlgorithm{packet.PubKeyAlgoECDSA}, true},
+		{"rsa,ecdh", []packet.PublicKeyAlgorithm{packet.PubKeyAlgoRSA, packet.PubKeyAlgoECDH}, true},
+		{"ecdsa,foo", nil, false},
+		{"eddsa,ecdh", []packet.PublicKeyAlgorithm{packet.PubKeyAlgoEdDSA, packet.PubKeyAlgoECDH}, true},
+	} {
+		got, err := ParsePGPAlgos(test.algos)
+		if (err == nil) != test.ok {
+			t.Errorf("ParsePGPAlgos(%q): %v, want success %v", test.algos, err, test.ok)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParsePGPAlgos(%q): %v, want %v", test.algos, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"crypto/elliptic"
+	"crypto/x509"
+	"errors"
+)
+
+// signalKeyType prefixes Curve25519 keys in the Signal protocol.
+const signalKeyType = 0x05
+
+var (
+	// ErrPublicKey occurs when the data is not a public key of the declared
+	// type.
+	ErrPublicKey = errors.New("pubkey: invalid public key")
+
+	// publicKeyTypes maps the supported public key types to their
+	// validation functions.
+	publicKeyTypes = map[string]func([]byte) bool{
+		// ed25519 and x25519 keys are the 32 raw key bytes.
+		"ed25519": func(b []byte) bool { return len(b) == 32 },
+		"x25519":  func(b []byte) bool { return len(b) == 32 },
+		// signal keys are x25519 keys prefixed with the key type.
+		"signal": func(b []byte) bool { return len(b) == 33 && b[0] == signalKeyType },
+		// p256 keys are uncompressed points.
+		"p256": func(b []byte) bool {
+			x, _ := elliptic.Unmarshal(elliptic.P256(), b)
+			return x != nil
+		},
+		// pkix keys are DER encoded SubjectPublicKeyInfo structures.
+		"pkix": func(b []byte) bool {
+			_, err := x509.ParsePKIXPublicKey(b)
+			return err == nil
+		},
+	}
+)
+
+// validatePublicKey verifies that data is a raw public key of keyType, one of
+// ed25519, x25519, signal, p256 or pkix.
+func validatePublicKey(keyType string, data []byte) error {
+	valid, ok := publicKeyTypes[keyType]
+	if !ok || !valid(data) {
+		return ErrPublicKey
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// ErrSSHKeyCount occurs when more than one authorized key is found.
+	ErrSSHKeyCount = errors.New("ssh: one authorized key allowed")
+	// ErrSSHOptions occurs when the authorized key has options. Options
+	// restrict the use of a key on a particular server and have no meaning
+	// in a published profile.
+	ErrSSHOptions = errors.New("ssh: options not allowed")
+)
+
+// validateSSH verifies that data is a single OpenSSH authorized_keys line
+// without options.
+func validateSSH(userID string, data []byte) error {
+	_, _, options, rest, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return err
+	}
+	if len(options) != 0 {
+		return ErrSSHOptions
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return ErrSSHKeyCount
+	}
+	return nil
+}
@@ -65,9 +65,9 @@ func TestValidateKey(t *testing.T) {
 		{primaryUserEmail, "foo", []byte("junk"), true},
 		{primaryUserEmail, primaryAppID, []byte("junk"), false},
 	} {
-		err := validateKey(tc.userID, tc.appID, tc.key)
+		err := DefaultValidators().Validate(tc.userID, tc.appID, tc.key)
 		if got := err == nil; got != tc.want {
-			t.Errorf("validateKey(%v, %v, %v) = %v, wanted %v", tc.userID, tc.appID, tc.key, err, tc.want)
+			t.Errorf("Validate(%v, %v, %v) = %v, wanted %v", tc.userID, tc.appID, tc.key, err, tc.want)
 		}
 	}
 }
@@ -118,7 +118,7 @@ func TestValidateUpdateEntryRequest(t *testing.T) {
 				},
 			},
 		}
-		err := validateUpdateEntryRequest(req, vrfPriv)
+		err := validateUpdateEntryRequest(req, vrfPriv, DefaultValidators())
 		if got := err == nil; got != tc.want {
 			t.Errorf("validateUpdateEntryRequest(%v): %v, want %v", req, err, tc.want)
 		}
@@ -0,0 +1,67 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrX509Email occurs when no email SAN of the certificate matches the
+	// user ID.
+	ErrX509Email = errors.New("x509: no email address matches userID")
+	// ErrX509Expired occurs when the certificate is expired or not yet
+	// valid.
+	ErrX509Expired = errors.New("x509: certificate expired or not yet valid")
+	// ErrX509Trailing occurs when data follows the certificate.
+	ErrX509Trailing = errors.New("x509: trailing data after certificate")
+)
+
+// validateX509 verifies that data is a single PEM or DER encoded X.509
+// certificate which
+// - has an email address SAN matching userID.
+// - is within its validity period.
+// The certificate chain is not verified.
+func validateX509(userID string, data []byte) error {
+	der := data
+	if block, rest := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("x509: unexpected PEM block %v", block.Type)
+		}
+		if len(strings.TrimSpace(string(rest))) != 0 {
+			return ErrX509Trailing
+		}
+		der = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return ErrX509Expired
+	}
+	for _, email := range cert.EmailAddresses {
+		if strings.EqualFold(email, userID) {
+			return nil
+		}
+	}
+	return ErrX509Email
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnknownApp occurs when no validator is registered for the app and
+	// unknown apps are rejected.
+	ErrUnknownApp = errors.New("no validator registered for app")
+)
+
+// ValidationError describes why the profile of an app was rejected.
+type ValidationError struct {
+	AppID string
+	// Err is the reason of the rejection, e.g. ErrAlgo.
+	Err error
+}
+
+// Error returns the app and the reason of the rejection.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %v profile: %v", e.AppID, e.Err)
+}
+
+// Validator verifies that the profile data a user publishes for an app is
+// well formed.
+type Validator interface {
+	// Validate returns an error if data is not a valid profile for userID.
+	Validate(userID string, data []byte) error
+}
+
+// ValidatorFunc adapts an ordinary function to a Validator.
+type ValidatorFunc func(userID string, data []byte) error
+
+// Validate calls f(userID, data).
+func (f ValidatorFunc) Validate(userID string, data []byte) error {
+	return f(userID, data)
+}
+
+// Validators is a registry of the validators of each app. Validators must be
+// registered before the registry is used by the server.
+type Validators struct {
+	apps          map[string]Validator
+	rejectUnknown bool
+}
+
+// NewValidators returns an empty registry. If rejectUnknown is set, profiles
+// of apps without a registered validator are rejected. Otherwise they are
+// accepted as opaque bytes.
+func NewValidators(rejectUnknown bool) *Validators {
+	return &Validators{
+		apps:          make(map[string]Validator),
+		rejectUnknown: rejectUnknown,
+	}
+}
+
+// DefaultValidators returns a registry which validates PGP keys for PGPAppID
+// and accepts the profiles of all other apps as opaque bytes.
+func DefaultValidators() *Validators {
+	v := NewValidators(false)
+	v.Register(PGPAppID, DefaultPGPPolicy())
+	return v
+}
+
+// Register sets the validator of appID.
+func (v *Validators) Register(appID string, validator Validator) {
+	v.apps[appID] = validator
+}
+
+// Validate verifies:
+// - appID is present.
+// - appID has a validator, if unknown apps are rejected.
+// - data is valid according to the validator of appID.
+// Rejected profiles are reported as *ValidationError.
+func (v *Validators) Validate(userID, appID string, data []byte) error {
+	if appID == "" {
+		return ErrNoAppID
+	}
+	validator, ok := v.apps[appID]
+	if !ok {
+		if v.rejectUnknown {
+			return &ValidationError{AppID: appID, Err: ErrUnknownApp}
+		}
+		return nil
+	}
+	if err := validator.Validate(userID, data); err != nil {
+		return &ValidationError{AppID: appID, Err: err}
+	}
+	return nil
+}
+
+// ParseValidators creates a registry from a comma separated list of
+// appID=validator pairs, e.g. "pgp=pgp,ssh=ssh,smime=x509". See NewValidator
+// for the names of the built-in validators. PGP keys are validated with
+// pgpPolicy, or DefaultPGPPolicy if nil.
+func ParseValidators(spec string, rejectUnknown bool, pgpPolicy *PGPPolicy) (*Validators, error) {
+	v := NewValidators(rejectUnknown)
+	for _, kv := range strings.Split(spec, ",") {
+		if kv == "" {
+			continue
+		}
+		p := strings.SplitN(kv, "=", 2)
+		if len(p) != 2 || p[0] == "" {
+			return nil, fmt.Errorf("invalid validator %q, want appID=validator", kv)
+		}
+		validator, err := NewValidator(p[1], pgpPolicy)
+		if err != nil {
+			return nil, err
+		}
+		v.Register(p[0], validator)
+	}
+	return v, nil
+}
+
+// NewValidator returns the built-in validator with the given name:
+// - pgp: an OpenPGP key ring for the user ID, accepted by pgpPolicy.
+// - ssh: a single OpenSSH authorized_keys line.
+// - x509: an X.509 certificate with an email SAN matching the user ID.
+// - pubkey:<type>: a raw public key of the given type, see validatePublicKey.
+// - opaque: any data.
+// DefaultPGPPolicy is used if pgpPolicy is nil.
+func NewValidator(name string, pgpPolicy *PGPPolicy) (Validator, error) {
+	switch {
+	case name == "pgp":
+		if pgpPolicy == nil {
+			pgpPolicy = DefaultPGPPolicy()
+		}
+		return pgpPolicy, nil
+	case name == "ssh":
+		return ValidatorFunc(validateSSH), nil
+	case name == "x509":
+		return ValidatorFunc(validateX509), nil
+	case strings.HasPrefix(name, "pubkey:"):
+		keyType := strings.TrimPrefix(name, "pubkey:")
+		if _, ok := publicKeyTypes[keyType]; !ok {
+			return nil, fmt.Errorf("unknown public key type %q", keyType)
+		}
+		return ValidatorFunc(func(userID string, data []byte) error {
+			return validatePublicKey(keyType, data)
+		}), nil
+	case name == "opaque":
+		return ValidatorFunc(func(string, []byte) error { return nil }), nil
+	default:
+		return nil, fmt.Errorf("unknown validator %q", name)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const sshKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBC9l0VrOI9DqVmgvMJw9NTwq7136IbjOviqe7kj5A6i alice@example.com"
+
+func newTestCert(t *testing.T, email string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: email},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate(): %v", err)
+	}
+	return der
+}
+
+func TestValidators(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): %v", err)
+	}
+	pkixKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey(): %v", err)
+	}
+	p256Key := elliptic.Marshal(elliptic.P256(), key.X, key.Y)
+	signalKey := append([]byte{signalKeyType}, make([]byte, 32)...)
+	cert := newTestCert(t, "alice@example.com", time.Now().Add(time.Hour))
+	expired := newTestCert(t, "alice@example.com", time.Now().Add(-time.Minute))
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+
+	v, err := ParseValidators("pgp=pgp,ssh=ssh,smime=x509,signal=pubkey:signal,"+
+		"ed=pubkey:ed25519,p256=pubkey:p256,pkix=pubkey:pkix,blob=opaque", true, nil)
+	if err != nil {
+		t.Fatalf("ParseValidators(): %v", err)
+	}
+	for _, tc := range []struct {
+		userID string
+		appID  string
+		data   []byte
+		want   bool
+	}{
+		{primaryUserEmail, "pgp", primaryUserKeyRing, true},
+		{primaryUserEmail, "pgp", []byte("junk"), false},
+		{"alice@example.com", "ssh", []byte(sshKey), true},
+		{"alice@example.com", "ssh", []byte(sshKey + "\n"), true},
+		{"alice@example.com", "ssh", []byte(sshKey + "\n" + sshKey), false},
+		{"alice@example.com", "ssh", []byte("command=\"ls\" " + sshKey), false},
+		{"alice@example.com", "ssh", []byte("junk"), false},
+		{"alice@example.com", "smime", cert, true},
+		{"Alice@Example.com", "smime", pemCert, true},
+		{"bob@example.com", "smime", cert, false},
+		{"alice@example.com", "smime", expired, false},
+		{"alice@example.com", "smime", append(pemCert, pemCert...), false},
+		{"alice@example.com", "signal", signalKey, true},
+		{"alice@example.com", "signal", signalKey[1:], false},
+		{"alice@example.com", "ed", make([]byte, 32), true},
+		{"alice@example.com", "ed", make([]byte, 31), false},
+		{"alice@example.com", "p256", p256Key, true},
+		{"alice@example.com", "p256", pkixKey, false},
+		{"alice@example.com", "pkix", pkixKey, true},
+		{"alice@example.com", "pkix", p256Key, false},
+		{"alice@example.com", "blob", []byte("junk"), true},
+		{"alice@example.com", "unknown", []byte("junk"), false},
+		{"alice@example.com", "", []byte("junk"), false},
+	} {
+		err := v.Validate(tc.userID, tc.appID, tc.data)
+		if got := err == nil; got != tc.want {
+			t.Errorf("Validate(%v, %v): %v, want success %v", tc.userID, tc.appID, err, tc.want)
+		}
+	}
+}
+
+func TestParseValidators(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want bool
+	}{
+		{"", true},
+		{"pgp=pgp", true},
+		{"a=pubkey:x25519,b=opaque", true},
+		{"pgp", false},
+		{"=pgp", false},
+		{"a=unknown", false},
+		{"a=pubkey:dsa", false},
+	} {
+		_, err := ParseValidators(tc.spec, false, nil)
+		if got := err == nil; got != tc.want {
+			t.Errorf("ParseValidators(%q): %v, want success %v", tc.spec, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+var witnessMismatchCtr = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "kt_monitor_witness_mismatches",
+	Help: "Number of epochs whose map root differs from the witnessed map root.",
+})
+
+func init() {
+	prometheus.MustRegister(witnessMismatchCtr)
+}
+
+// Alert describes a detected misbehavior of the key server.
+type Alert struct {
+	MapID         int64  `json:"map_id"`
+	Epoch         int64  `json:"epoch"`
+	Root          []byte `json:"root"`
+	WitnessedRoot []byte `json:"witnessed_root"`
+	Reason        string `json:"reason"`
+}
+
+// Alerter notifies operators about alerts.
+type Alerter interface {
+	// Alert sends a.
+	Alert(ctx context.Context, a *Alert) error
+}
@@ -15,38 +15,59 @@
 package monitor
 
 import (
+	"bytes"
 	"crypto"
+	"errors"
 	"fmt"
 	"time"
-	"net/http"
-	"io/ioutil"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 
 	"github.com/google/keytransparency/core/monitor/storage"
+	"github.com/google/keytransparency/core/mutator"
+	"github.com/google/keytransparency/core/mutator/entry"
 	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/keytransparency/core/witness"
 
 	"github.com/google/trillian"
+	"github.com/google/trillian/client"
 	tcrypto "github.com/google/trillian/crypto"
-	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/crypto/keys/der"
 	"github.com/google/trillian/merkle/hashers"
 )
 
+// ErrWitnessMismatch occurs when the map root received from the key server
+// differs from the map root the sequencer published to the witness.
+var ErrWitnessMismatch = errors.New("map root does not match witnessed root")
+
+// MutationsClient fetches mutations responses from the key server. The monitor
+// uses it to fetch the map roots of epochs it has not verified itself.
+type MutationsClient interface {
+	GetMutations(ctx context.Context, in *ktpb.GetMutationsRequest, opts ...grpc.CallOption) (*ktpb.GetMutationsResponse, error)
+}
+
 // Monitor holds the internal state for a monitor accessing the mutations API
 // and for verifying its responses.
 type Monitor struct {
+	mutations   MutationsClient
 	hasher      hashers.MapHasher
-	logPubKey   crypto.PublicKey
 	mapPubKey   crypto.PublicKey
-	logVerifier merkle.LogVerifier
+	logVerifier client.LogVerifier
+	mutator     mutator.Mutator
 	signer      *tcrypto.Signer
-	// TODO(ismail): update last trusted signed log root
-	//trusted     trillian.SignedLogRoot
-	store storage.Storage
+	trusted     trillian.SignedLogRoot
+	store       storage.Storage
+	witness     witness.Witness
+	alerter     Alerter
 }
 
-// New creates a new instance of the monitor.
-func New(logTree, mapTree *trillian.Tree, signer *tcrypto.Signer, store storage.Storage) (*Monitor, error) {
+// New creates a new instance of the monitor verifying the responses of the
+// key server reachable through mutations. If witness is not nil, map roots are
+// compared with the roots published to the witness. If alerter is not nil, it
+// is notified about roots differing from the witnessed roots.
+func New(mutations MutationsClient, logTree, mapTree *trillian.Tree, signer *tcrypto.Signer, store storage.Storage, witness witness.Witness, alerter Alerter) (*Monitor, error) {
 	logHasher, err := hashers.NewLogHasher(logTree.GetHashStrategy())
 	if err != nil {
 		return nil, fmt.Errorf("Failed creating LogHasher: %v", err)
@@ -55,13 +76,24 @@ func New(logTree, mapTree *trillian.Tree, signer *tcrypto.Signer, store storage.
 	if err != nil {
 		return nil, fmt.Errorf("Failed creating MapHasher: %v", err)
 	}
+	logPubKey, err := der.UnmarshalPublicKey(logTree.GetPublicKey().GetDer())
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing Log public key: %v", err)
+	}
+	mapPubKey, err := der.UnmarshalPublicKey(mapTree.GetPublicKey().GetDer())
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing Map public key: %v", err)
+	}
 	return &Monitor{
+		mutations:   mutations,
 		hasher:      mapHasher,
-		logVerifier: merkle.NewLogVerifier(logHasher),
-		logPubKey:   logTree.GetPublicKey(),
-		mapPubKey:   mapTree.GetPublicKey(),
+		logVerifier: client.NewLogVerifier(logHasher, logPubKey),
+		mapPubKey:   mapPubKey,
+		mutator:     entry.New(),
 		signer:      signer,
 		store:       store,
+		witness:     witness,
+		alerter:     alerter,
 	}, nil
 }
 
@@ -73,6 +105,9 @@ func (m *Monitor) Process(resp *ktpb.GetMutationsResponse) error {
 	var err error
 	seen := time.Now().Unix()
 	errs := m.verifyMutationsResponse(resp)
+	if err := m.verifyWitness(resp.GetSmr()); err != nil {
+		errs = append(errs, err)
+	}
 	if len(errs) == 0 {
 		glog.Infof("Successfully verified mutations response for epoch: %v", resp.Epoch)
 		smr, err = m.signMapRoot(resp)
@@ -85,44 +120,40 @@ func (m *Monitor) Process(resp *ktpb.GetMutationsResponse) error {
 		glog.Errorf("m.store.Set(%v, %v, _, _, %v): %v", resp.Epoch, seen, errs, err)
 		return err
 	}
-
-	smrBFTKVKey := string(smr.MapId) + "|" + string(resp.Epoch)
-	glog.Infof("Requesting smr with key: %s", smrBFTKVKey)
-	bftkvSMRHash := readFromBFTKV(smrBFTKVKey)
-	glog.Infof("BFTKV root hash: %s", bftkvSMRHash)
-	glog.Infof("KT root hash: %s", smr.RootHash)
-	// compare smr received from the kt server and from bftkv
-	if bftkvSMRHash != "" {
-		if fmt.Sprintf("%s", smr.RootHash) == bftkvSMRHash {
-			glog.Infoln("Root hashes match.")
-		} else {
-			glog.Infoln("Root hashes don't match.")
-		}
-	}
-
 	return nil
 }
 
-func readFromBFTKV(key string) string  {
-	glog.Infoln("Reading from BFTKV...")
-	req, err := http.NewRequest("GET", "http://docker.for.mac.localhost:6001/read/" + key, nil)
-	if err != nil {
-		glog.Errorf("BFTKV read error: %v", err)
-	}
-	client := http.DefaultClient
-	resp, err := client.Do(req)
-	if err != nil {
-		glog.Errorf("BFTKV request error: %v", err)
+// verifyWitness compares the root of smr with the root published to the
+// witness. Missing or unavailable witnessed roots are not treated as errors.
+// A differing root indicates a split view; it is counted and alerted.
+func (m *Monitor) verifyWitness(smr *trillian.SignedMapRoot) error {
+	if m.witness == nil {
+		return nil
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		glog.Errorf("Response body read error: %v", err)
-	}
-	// the response is right only if the status okay, otherwise it'll include an error message
-	// so don't return the body if the status is not ok
-	if resp.StatusCode == http.StatusOK {
-		return fmt.Sprintf("%s", bodyBytes)
+	ctx := context.Background()
+	root, err := m.witness.Lookup(ctx, smr.GetMapId(), smr.GetMapRevision())
+	switch {
+	case err == witness.ErrNotFound:
+		glog.Warningf("No witnessed root for revision %v", smr.GetMapRevision())
+		return nil
+	case err != nil:
+		glog.Errorf("witness.Lookup(%v, %v): %v", smr.GetMapId(), smr.GetMapRevision(), err)
+		return nil
+	case !bytes.Equal(root, smr.GetRootHash()):
+		glog.Errorf("Map root of revision %v: %x, witnessed %x", smr.GetMapRevision(), smr.GetRootHash(), root)
+		witnessMismatchCtr.Inc()
+		if m.alerter != nil {
+			if err := m.alerter.Alert(ctx, &Alert{
+				MapID:         smr.GetMapId(),
+				Epoch:         smr.GetMapRevision(),
+				Root:          smr.GetRootHash(),
+				WitnessedRoot: root,
+				Reason:        ErrWitnessMismatch.Error(),
+			}); err != nil {
+				glog.Errorf("alerter.Alert(): %v", err)
+			}
+		}
+		return ErrWitnessMismatch
 	}
-	return ""
+	return nil
 }
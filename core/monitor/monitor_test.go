@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"testing"
+
+	"github.com/google/keytransparency/core/fake"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+type fakeAlerter struct {
+	alerts []*Alert
+}
+
+func (f *fakeAlerter) Alert(ctx context.Context, a *Alert) error {
+	f.alerts = append(f.alerts, a)
+	return nil
+}
+
+func TestVerifyWitness(t *testing.T) {
+	w := fake.NewFakeWitness()
+	if err := w.Publish(context.Background(), 1, 2, []byte("root")); err != nil {
+		t.Fatalf("Publish(): %v", err)
+	}
+	for _, tc := range []struct {
+		m    *Monitor
+		smr  *trillian.SignedMapRoot
+		want error
+	}{
+		{&Monitor{}, &trillian.SignedMapRoot{MapId: 1, MapRevision: 2, RootHash: []byte("other")}, nil},
+		{&Monitor{witness: w}, &trillian.SignedMapRoot{MapId: 1, MapRevision: 2, RootHash: []byte("root")}, nil},
+		{&Monitor{witness: w}, &trillian.SignedMapRoot{MapId: 1, MapRevision: 2, RootHash: []byte("other")}, ErrWitnessMismatch},
+		{&Monitor{witness: w}, &trillian.SignedMapRoot{MapId: 1, MapRevision: 3, RootHash: []byte("other")}, nil},
+	} {
+		if got := tc.m.verifyWitness(tc.smr); got != tc.want {
+			t.Errorf("verifyWitness(%v): %v, want %v", tc.smr, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyWitnessAlerts(t *testing.T) {
+	w := fake.NewFakeWitness()
+	if err := w.Publish(context.Background(), 1, 2, []byte("root")); err != nil {
+		t.Fatalf("Publish(): %v", err)
+	}
+	a := &fakeAlerter{}
+	m := &Monitor{witness: w, alerter: a}
+	smr := &trillian.SignedMapRoot{MapId: 1, MapRevision: 2, RootHash: []byte("other")}
+	if got, want := m.verifyWitness(smr), ErrWitnessMismatch; got != want {
+		t.Fatalf("verifyWitness(%v): %v, want %v", smr, got, want)
+	}
+	if got, want := len(a.alerts), 1; got != want {
+		t.Fatalf("len(alerts): %v, want %v", got, want)
+	}
+	if got, want := string(a.alerts[0].WitnessedRoot), "root"; got != want {
+		t.Errorf("alert.WitnessedRoot: %v, want %v", got, want)
+	}
+}
@@ -18,14 +18,268 @@
 package monitor
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/google/keytransparency/core/mutator/entry"
 	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+
+	"github.com/google/trillian"
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+)
+
+var (
+	// ErrInvalidMapSignature occurs if the signature on the map root does not
+	// verify under the map's public key.
+	ErrInvalidMapSignature = errors.New("invalid signature on map root")
+	// ErrInvalidLogRoot occurs if the log root's signature or its
+	// consistency proof with the previously trusted log root does not verify.
+	ErrInvalidLogRoot = errors.New("invalid log root or log consistency proof")
+	// ErrInvalidLogInclusion occurs if the inclusion proof of the signed map
+	// root in the log does not verify.
+	ErrInvalidLogInclusion = errors.New("invalid log inclusion proof")
+	// ErrInvalidMutationProof occurs if the inclusion proof of a mutation's
+	// previous leaf does not verify against the previous epoch's map root.
+	ErrInvalidMutationProof = errors.New("invalid mutation inclusion proof")
+	// ErrInconsistentProofs occurs when the server returned different hashes
+	// for the same node in the inclusion proofs of the mutations.
+	ErrInconsistentProofs = errors.New("inconsistent mutation inclusion proofs")
+	// ErrNotMatchingRoot occurs when the map root recomputed from the
+	// mutations differs from the map root received from the server.
+	ErrNotMatchingRoot = errors.New("recreated map root does not match")
+	// ErrUnverifiedPreviousRoot occurs if the map root of the previous
+	// epoch, against which the mutations are proven, cannot be verified.
+	ErrUnverifiedPreviousRoot = errors.New("previous map root not verified")
 )
 
+// fetchTimeout is the timeout for fetching the map root of a previous epoch.
+const fetchTimeout = 10 * time.Second
+
 // verifyMutationsResponse verifies a response received by the GetMutations API.
 // Additionally to the response it takes a complete list of mutations. The list
 // of received mutations may differ from those included in the initial response
 // because of the max. page size. If any verification check failed it returns
 // an error.
 func (m *Monitor) verifyMutationsResponse(in *ktpb.GetMutationsResponse) []error {
+	errList := make([]error, 0)
+	if in.GetSmr() == nil {
+		return append(errList, ErrInvalidMapSignature)
+	}
+
+	if err := m.verifySignedMapRoot(in.GetSmr()); err != nil {
+		glog.Infof("VerifyObject(SMR %v): %v", in.GetEpoch(), err)
+		errList = append(errList, ErrInvalidMapSignature)
+	}
+
+	// Verify the log root and its consistency with the trusted log root.
+	logRootVerified := true
+	if err := m.logVerifier.VerifyRoot(&m.trusted, in.GetLogRoot(), in.GetLogConsistency()); err != nil {
+		glog.Infof("VerifyRoot(%v, %v): %v", in.GetLogRoot(), in.GetLogConsistency(), err)
+		errList = append(errList, ErrInvalidLogRoot)
+		logRootVerified = false
+	}
+
+	if err := m.verifyLogInclusion(in.GetLogRoot(), in.GetSmr(), in.GetLogInclusion()); err != nil {
+		glog.Infof("VerifyInclusionAtIndex(_, %v, _): %v", in.GetSmr().GetMapRevision(), err)
+		errList = append(errList, ErrInvalidLogInclusion)
+	}
+
+	// Only advance the trusted log root if it was consistent with the
+	// previous one.
+	if logRootVerified {
+		m.trusted = *in.GetLogRoot()
+	}
+
+	// The inclusion proofs of the mutations are relative to the map root of
+	// the previous epoch, which has to be verified as well.
+	oldRoot, err := m.previousRoot(in.GetEpoch(), in.GetSmr().GetMapId())
+	if err != nil {
+		glog.Infof("Cannot verify mutations of epoch %v: %v", in.GetEpoch(), err)
+		return append(errList, ErrUnverifiedPreviousRoot)
+	}
+	errList = append(errList, m.verifyMutations(in.GetMutations(), oldRoot,
+		in.GetSmr().GetRootHash(), in.GetSmr().GetMapId())...)
+
+	return errList
+}
+
+// verifySignedMapRoot verifies the signature of smr.
+func (m *Monitor) verifySignedMapRoot(smr *trillian.SignedMapRoot) error {
+	// SignedMapRoot contains its own signature. To verify, we need to create
+	// a local copy of the object and return the object to the state it was
+	// in when signed by removing the signature from the object.
+	unsigned := *smr
+	unsigned.Signature = nil
+	return tcrypto.VerifyObject(m.mapPubKey, unsigned, smr.GetSignature())
+}
+
+// verifyLogInclusion verifies the inclusion of smr in the log with logRoot.
+// The map root of revision r is stored in the log at leaf index r.
+func (m *Monitor) verifyLogInclusion(logRoot *trillian.SignedLogRoot, smr *trillian.SignedMapRoot, proof [][]byte) error {
+	b, err := json.Marshal(smr)
+	if err != nil {
+		return err
+	}
+	return m.logVerifier.VerifyInclusionAtIndex(logRoot, b, smr.GetMapRevision(), proof)
+}
+
+// previousRoot returns the verified map root of the epoch preceding epoch.
+// The roots of epochs which passed all verifications are taken from the
+// storage. Other roots, e.g. the root preceding the first polled epoch, are
+// fetched from the server and verified like the roots of polled epochs. The
+// map of epoch 0 is empty.
+func (m *Monitor) previousRoot(epoch, mapID int64) ([]byte, error) {
+	prevEpoch := epoch - 1
+	if prevEpoch == 0 {
+		return m.hasher.HashEmpty(mapID, make([]byte, m.hasher.Size()), m.hasher.BitLen()), nil
+	}
+	// The monitor only signs map roots which passed all verifications.
+	if prev, err := m.store.Get(prevEpoch); err == nil && prev.Smr != nil {
+		return prev.Smr.GetRootHash(), nil
+	}
+	if m.mutations == nil {
+		return nil, fmt.Errorf("map root of epoch %v unknown", prevEpoch)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	resp, err := m.mutations.GetMutations(ctx, &ktpb.GetMutationsRequest{
+		Epoch:         prevEpoch,
+		FirstTreeSize: m.trusted.GetTreeSize(),
+		PageSize:      1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetMutations(%v): %v", prevEpoch, err)
+	}
+	smr := resp.GetSmr()
+	if got := smr.GetMapRevision(); got != prevEpoch {
+		return nil, fmt.Errorf("map root of revision %v, want %v", got, prevEpoch)
+	}
+	if err := m.verifySignedMapRoot(smr); err != nil {
+		return nil, fmt.Errorf("VerifyObject(SMR %v): %v", prevEpoch, err)
+	}
+	// Verify the log root against a copy of the trusted log root, as the
+	// consistency proofs of polled epochs are relative to the trusted root.
+	trusted := m.trusted
+	if err := m.logVerifier.VerifyRoot(&trusted, resp.GetLogRoot(), resp.GetLogConsistency()); err != nil {
+		return nil, fmt.Errorf("VerifyRoot(): %v", err)
+	}
+	if err := m.verifyLogInclusion(resp.GetLogRoot(), smr, resp.GetLogInclusion()); err != nil {
+		return nil, fmt.Errorf("VerifyInclusionAtIndex(_, %v, _): %v", prevEpoch, err)
+	}
+	return smr.GetRootHash(), nil
+}
+
+// verifyMutations verifies the inclusion proofs of the mutations against
+// oldRoot, applies them to their previous leaf values the same way the
+// sequencer does, and checks that the resulting map root equals
+// expectedNewRoot.
+func (m *Monitor) verifyMutations(muts []*ktpb.Mutation, oldRoot, expectedNewRoot []byte, mapID int64) []error {
+	errList := make([]error, 0)
+	// oldProofNodes contains the hashes of the previous epoch's tree nodes
+	// revealed by the inclusion proofs, indexed by node ID.
+	oldProofNodes := make(map[string][]byte)
+	// newLeaves contains the last valid mutation for each index, as the
+	// sequencer only keeps the last valid mutation per leaf.
+	newLeaves := make(map[string]merkle.HStar2LeafHash)
+
+	for _, mut := range muts {
+		index := mut.GetUpdate().GetKeyValue().GetKey()
+		oldLeafValue := mut.GetProof().GetLeaf().GetLeafValue()
+		proof := mut.GetProof().GetInclusion()
+
+		// Verify that the provided previous leaf is included in the
+		// previous epoch.
+		if err := merkle.VerifyMapInclusionProof(mapID, index, oldLeafValue,
+			oldRoot, proof, m.hasher); err != nil {
+			glog.Infof("VerifyMapInclusionProof(%x): %v", index, err)
+			errList = append(errList, ErrInvalidMutationProof)
+			continue
+		}
+
+		// Store the proof hashes to recompute the new root below. Proof
+		// nodes shared by several mutations must be equal.
+		nID := storage.NewNodeIDFromPrefixSuffix(index, storage.Suffix{}, m.hasher.BitLen())
+		sibIDs := nID.Siblings()
+		for level, p := range proof {
+			if len(p) == 0 {
+				continue
+			}
+			pID := sibIDs[level].String()
+			if stored, ok := oldProofNodes[pID]; ok && !bytes.Equal(stored, p) {
+				glog.Infof("Proof node %v: %x, want %x", pID, p, stored)
+				errList = append(errList, ErrInconsistentProofs)
+				continue
+			}
+			oldProofNodes[pID] = p
+		}
+
+		// Apply the mutation. Invalid mutations are skipped by the
+		// sequencer and do not make the whole epoch fail.
+		oldEntry, err := entry.FromLeafValue(oldLeafValue)
+		if err != nil {
+			glog.Infof("entry.FromLeafValue(%x): %v", index, err)
+			continue
+		}
+		newValue, err := m.mutator.Mutate(oldEntry, mut.GetUpdate())
+		if err != nil {
+			glog.Infof("Mutate(%x): %v", index, err)
+			continue
+		}
+		newLeaves[string(index)] = merkle.HStar2LeafHash{
+			Index:    nID.BigInt(),
+			LeafHash: m.hasher.HashLeaf(mapID, index, newValue),
+		}
+	}
+
+	if err := m.verifyNewRoot(newLeaves, oldProofNodes, oldRoot, expectedNewRoot, mapID); err != nil {
+		errList = append(errList, err)
+	}
+	return errList
+}
+
+// verifyNewRoot recomputes the map root from the new leaves and the previous
+// epoch's proof nodes and compares it with expectedNewRoot.
+func (m *Monitor) verifyNewRoot(newLeaves map[string]merkle.HStar2LeafHash,
+	oldProofNodes map[string][]byte, oldRoot, expectedNewRoot []byte, mapID int64) error {
+	// An epoch without any applied mutations does not change the root.
+	if len(newLeaves) == 0 {
+		if !bytes.Equal(oldRoot, expectedNewRoot) {
+			glog.Infof("Root changed without mutations: %x, want %x", expectedNewRoot, oldRoot)
+			return ErrNotMatchingRoot
+		}
+		return nil
+	}
+	leaves := make([]merkle.HStar2LeafHash, 0, len(newLeaves))
+	for _, l := range newLeaves {
+		leaves = append(leaves, l)
+	}
+
+	hs2 := merkle.NewHStar2(mapID, m.hasher)
+	newRoot, err := hs2.HStar2Nodes([]byte{}, m.hasher.BitLen(), leaves,
+		func(depth int, index *big.Int) ([]byte, error) {
+			nID := storage.NewNodeIDFromBigInt(depth, index, m.hasher.BitLen())
+			if p, ok := oldProofNodes[nID.String()]; ok {
+				return p, nil
+			}
+			return nil, nil
+		}, nil)
+	if err != nil {
+		glog.Errorf("HStar2Nodes(): %v", err)
+		return ErrNotMatchingRoot
+	}
+	if !bytes.Equal(newRoot, expectedNewRoot) {
+		glog.Infof("Recreated root %x, want %x", newRoot, expectedNewRoot)
+		return ErrNotMatchingRoot
+	}
 	return nil
 }
@@ -14,5 +14,332 @@
 
 package monitor
 
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/keytransparency/core/fake"
+	"github.com/google/keytransparency/core/monitor/storage"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/coniks"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+const mapID = 5
+
+// fakeServer serves mutations responses with real proofs of a fake map and
+// log, like the mutations API of the key server.
+type fakeServer struct {
+	t         *testing.T
+	tmap      *fake.TrillianMap
+	tlog      *fake.TrillianLog
+	mutations map[int64][]*ktpb.Mutation
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	s := &fakeServer{
+		t:         t,
+		tmap:      fake.NewFakeTrillianMapClient(mapID),
+		tlog:      fake.NewFakeTrillianLogClient(),
+		mutations: make(map[int64][]*ktpb.Mutation),
+	}
+	resp, err := s.tmap.GetSignedMapRootByRevision(context.Background(), &trillian.GetSignedMapRootByRevisionRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRootByRevision(0): %v", err)
+	}
+	s.logRoot(resp.GetMapRoot())
+	return s
+}
+
+// logRoot appends smr to the log.
+func (s *fakeServer) logRoot(smr *trillian.SignedMapRoot) {
+	b, err := json.Marshal(smr)
+	if err != nil {
+		s.t.Fatalf("json.Marshal(): %v", err)
+	}
+	if _, err := s.tlog.QueueLeaf(context.Background(), &trillian.QueueLeafRequest{
+		Leaf: &trillian.LogLeaf{LeafValue: b},
+	}); err != nil {
+		s.t.Fatalf("QueueLeaf(): %v", err)
+	}
+}
+
+// createEpoch sets the values of keys in a new map revision.
+func (s *fakeServer) createEpoch(kvs map[string]string) {
+	ctx := context.Background()
+	var muts []*ktpb.Mutation
+	var leaves []*trillian.MapLeaf
+	for k, v := range kvs {
+		index := sha256.Sum256([]byte(k))
+		resp, err := s.tmap.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
+			MapId:    mapID,
+			Index:    [][]byte{index[:]},
+			Revision: -1,
+		})
+		if err != nil {
+			s.t.Fatalf("GetLeaves(): %v", err)
+		}
+		value, err := proto.Marshal(&ktpb.Entry{Commitment: []byte(v)})
+		if err != nil {
+			s.t.Fatalf("proto.Marshal(): %v", err)
+		}
+		muts = append(muts, &ktpb.Mutation{
+			Update: &ktpb.SignedKV{KeyValue: &ktpb.KeyValue{Key: index[:], Value: value}},
+			Proof:  resp.MapLeafInclusion[0],
+		})
+		leaves = append(leaves, &trillian.MapLeaf{Index: index[:], LeafValue: value})
+	}
+	resp, err := s.tmap.SetLeaves(ctx, &trillian.SetMapLeavesRequest{MapId: mapID, Leaves: leaves})
+	if err != nil {
+		s.t.Fatalf("SetLeaves(): %v", err)
+	}
+	s.mutations[resp.GetMapRoot().GetMapRevision()] = muts
+	s.logRoot(resp.GetMapRoot())
+}
+
+// GetMutations returns all mutations of in.Epoch. Log proofs are relative to
+// in.FirstTreeSize.
+func (s *fakeServer) GetMutations(ctx context.Context, in *ktpb.GetMutationsRequest, opts ...grpc.CallOption) (*ktpb.GetMutationsResponse, error) {
+	smr, err := s.tmap.GetSignedMapRootByRevision(ctx, &trillian.GetSignedMapRootByRevisionRequest{
+		MapId:    mapID,
+		Revision: in.Epoch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logRoot, err := s.tlog.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{})
+	if err != nil {
+		return nil, err
+	}
+	treeSize := logRoot.GetSignedLogRoot().GetTreeSize()
+	var consistency [][]byte
+	if in.FirstTreeSize != 0 {
+		resp, err := s.tlog.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{
+			FirstTreeSize:  in.FirstTreeSize,
+			SecondTreeSize: treeSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		consistency = resp.GetProof().GetHashes()
+	}
+	inclusion, err := s.tlog.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+		LeafIndex: in.Epoch,
+		TreeSize:  treeSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ktpb.GetMutationsResponse{
+		Epoch:          in.Epoch,
+		Smr:            smr.GetMapRoot(),
+		LogRoot:        logRoot.GetSignedLogRoot(),
+		LogConsistency: consistency,
+		LogInclusion:   inclusion.GetProof().GetHashes(),
+		Mutations:      s.mutations[in.Epoch],
+	}, nil
+}
+
+// fakeMutator sets the values of mutations.
+type fakeMutator struct{}
+
+func (fakeMutator) Mutate(value, mutation proto.Message) ([]byte, error) {
+	return mutation.(*ktpb.SignedKV).GetKeyValue().GetValue(), nil
+}
+
+// fakeStorage stores monitoring results in memory.
+type fakeStorage map[int64]*storage.MonitoringResult
+
+func (s fakeStorage) Set(epoch int64, seen int64, smr *trillian.SignedMapRoot, resp *ktpb.GetMutationsResponse, errs []error) error {
+	s[epoch] = &storage.MonitoringResult{Smr: smr, Seen: seen, Response: resp, Errors: errs}
+	return nil
+}
+
+func (s fakeStorage) Get(epoch int64) (*storage.MonitoringResult, error) {
+	r, ok := s[epoch]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return r, nil
+}
+
+func (s fakeStorage) LatestEpoch() int64 {
+	var latest int64
+	for e := range s {
+		if e > latest {
+			latest = e
+		}
+	}
+	return latest
+}
+
+func newMonitor(srv *fakeServer, mutations MutationsClient) *Monitor {
+	return &Monitor{
+		mutations:   mutations,
+		hasher:      coniks.Default,
+		mapPubKey:   srv.tmap.PublicKey(),
+		logVerifier: srv.tlog.Verifier(),
+		mutator:     fakeMutator{},
+		store:       fakeStorage{},
+	}
+}
+
+// verify verifies the mutations response of epoch and stores the result like
+// Process, without signing the map root.
+func verify(t *testing.T, m *Monitor, srv *fakeServer, epoch int64) []error {
+	resp, err := srv.GetMutations(context.Background(), &ktpb.GetMutationsRequest{
+		Epoch:         epoch,
+		FirstTreeSize: m.trusted.TreeSize,
+	})
+	if err != nil {
+		t.Fatalf("GetMutations(%v): %v", epoch, err)
+	}
+	errs := m.verifyMutationsResponse(resp)
+	var smr *trillian.SignedMapRoot
+	if len(errs) == 0 {
+		smr = resp.GetSmr()
+	}
+	if err := m.store.Set(epoch, 0, smr, resp, errs); err != nil {
+		t.Fatalf("Set(%v): %v", epoch, err)
+	}
+	return errs
+}
+
 // TODO(ismail): write extensive tests for verification steps, where necessary
 // tests should go into integration tests
+
+func TestVerifyMutationsResponseMissingSMR(t *testing.T) {
+	m := &Monitor{}
+	errs := m.verifyMutationsResponse(&ktpb.GetMutationsResponse{Epoch: 1})
+	if got, want := len(errs), 1; got != want {
+		t.Fatalf("len(verifyMutationsResponse())=%v, want %v", got, want)
+	}
+	if got, want := errs[0], ErrInvalidMapSignature; got != want {
+		t.Errorf("verifyMutationsResponse()=%v, want %v", got, want)
+	}
+}
+
+func TestVerifyNewRootWithoutMutations(t *testing.T) {
+	m := &Monitor{}
+	for _, tc := range []struct {
+		oldRoot, newRoot []byte
+		want             error
+	}{
+		{[]byte("root"), []byte("root"), nil},
+		{[]byte("root"), []byte("other"), ErrNotMatchingRoot},
+	} {
+		if got := m.verifyNewRoot(nil, nil, tc.oldRoot, tc.newRoot, 0); got != tc.want {
+			t.Errorf("verifyNewRoot(_, _, %s, %s)=%v, want %v", tc.oldRoot, tc.newRoot, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyMutations(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.createEpoch(map[string]string{"alice": "a1", "bob": "b1"})
+	srv.createEpoch(map[string]string{"alice": "a2", "carol": "c1"})
+	srv.createEpoch(nil)
+	srv.createEpoch(map[string]string{"bob": "b2", "carol": "c2", "dave": "d1"})
+
+	for _, tc := range []struct {
+		desc   string
+		fetch  bool
+		epochs []int64
+		want   []error
+	}{
+		{"from epoch 1", false, []int64{1, 2, 3, 4}, nil},
+		{"resume", true, []int64{2, 3, 4}, nil},
+		{"resume without client", false, []int64{2}, []error{ErrUnverifiedPreviousRoot}},
+	} {
+		m := newMonitor(srv, nil)
+		if tc.fetch {
+			m.mutations = srv
+		}
+		for _, epoch := range tc.epochs {
+			errs := verify(t, m, srv, epoch)
+			if got, want := len(errs), len(tc.want); got != want {
+				t.Fatalf("%v: verifyMutationsResponse(%v): %v, want %v", tc.desc, epoch, errs, tc.want)
+			}
+			for i := range errs {
+				if errs[i] != tc.want[i] {
+					t.Errorf("%v: verifyMutationsResponse(%v): %v, want %v", tc.desc, epoch, errs, tc.want)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyTamperedMutations(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.createEpoch(map[string]string{"alice": "a1", "bob": "b1"})
+	srv.createEpoch(map[string]string{"alice": "a2"})
+	for _, tc := range []struct {
+		desc   string
+		tamper func(m *ktpb.Mutation)
+		want   error
+	}{
+		{"value", func(m *ktpb.Mutation) {
+			m.Update.KeyValue.Value = []byte("forged")
+		}, ErrNotMatchingRoot},
+		{"previous leaf", func(m *ktpb.Mutation) {
+			m.Proof.Leaf.LeafValue = []byte("forged")
+		}, ErrInvalidMutationProof},
+		{"proof", func(m *ktpb.Mutation) {
+			m.Proof.Inclusion[len(m.Proof.Inclusion)-1] = make([]byte, 32)
+		}, ErrInvalidMutationProof},
+	} {
+		m := newMonitor(srv, srv)
+		resp, err := srv.GetMutations(context.Background(), &ktpb.GetMutationsRequest{Epoch: 2})
+		if err != nil {
+			t.Fatalf("GetMutations(): %v", err)
+		}
+		resp = proto.Clone(resp).(*ktpb.GetMutationsResponse)
+		tc.tamper(resp.Mutations[0])
+		errs := m.verifyMutationsResponse(resp)
+		found := false
+		for _, err := range errs {
+			found = found || err == tc.want
+		}
+		if !found {
+			t.Errorf("%v: verifyMutationsResponse(): %v, want %v", tc.desc, errs, tc.want)
+		}
+	}
+}
+
+func TestVerifyAfterFailedEpoch(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.createEpoch(map[string]string{"alice": "a1"})
+	srv.createEpoch(map[string]string{"alice": "a2"})
+	for _, tc := range []struct {
+		mutations MutationsClient
+		want      []error
+	}{
+		{nil, []error{ErrUnverifiedPreviousRoot}},
+		{srv, nil},
+	} {
+		m := newMonitor(srv, tc.mutations)
+		// Epoch 1 failed verification, so its root is not trusted.
+		resp, err := srv.GetMutations(context.Background(), &ktpb.GetMutationsRequest{Epoch: 1})
+		if err != nil {
+			t.Fatalf("GetMutations(): %v", err)
+		}
+		if err := m.store.Set(1, 0, nil, resp, []error{ErrNotMatchingRoot}); err != nil {
+			t.Fatalf("Set(): %v", err)
+		}
+		errs := verify(t, m, srv, 2)
+		if got, want := len(errs), len(tc.want); got != want {
+			t.Fatalf("verifyMutationsResponse(2): %v, want %v", errs, tc.want)
+		}
+		for i := range errs {
+			if errs[i] != tc.want[i] {
+				t.Errorf("verifyMutationsResponse(2): %v, want %v", errs, tc.want)
+			}
+		}
+	}
+}
@@ -19,6 +19,7 @@ package mutation
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/keytransparency/core/mutator"
@@ -40,6 +41,9 @@ type Server struct {
 	tmap      trillian.TrillianMapClient
 	mutations mutator.Mutation
 	factory   transaction.Factory
+	// streamPollPeriod is the period in which GetMutationsStream checks
+	// for new epochs.
+	streamPollPeriod time.Duration
 }
 
 // New creates a new instance of the monitor server.
@@ -50,12 +54,13 @@ func New(logID int64,
 	mutations mutator.Mutation,
 	factory transaction.Factory) *Server {
 	return &Server{
-		logID:     logID,
-		mapID:     mapID,
-		tlog:      tlog,
-		tmap:      tmap,
-		mutations: mutations,
-		factory:   factory,
+		logID:            logID,
+		mapID:            mapID,
+		tlog:             tlog,
+		tmap:             tmap,
+		mutations:        mutations,
+		factory:          factory,
+		streamPollPeriod: defaultStreamPollPeriod,
 	}
 }
 
@@ -104,9 +109,10 @@ func (s *Server) GetMutations(ctx context.Context, in *tpb.GetMutationsRequest)
 		mutations = append(mutations, &tpb.Mutation{Update: m})
 		indexes = append(indexes, m.GetKeyValue().GetKey())
 	}
-	// Get leaf proofs.
+	// Get leaf proofs. The mutations of an epoch are applied to the leaves
+	// of the previous epoch.
 	// TODO: allow leaf proofs to be optional.
-	proofs, err := s.inclusionProofs(ctx, indexes, in.Epoch)
+	proofs, err := s.inclusionProofs(ctx, indexes, in.Epoch-1)
 	if err != nil {
 		return nil, err
 	}
@@ -114,10 +120,9 @@ func (s *Server) GetMutations(ctx context.Context, in *tpb.GetMutationsRequest)
 		mutations[i].Proof = p
 	}
 
-	// MapRevisions start at 1. Log leave's index starts at 0.
-	// MapRevision should be at least 1 since the Signer is
-	// supposed to create at least one revision on startup.
-	respEpoch := resp.GetMapRoot().GetMapRevision() - 1
+	// The sequencer stores the SignedMapRoot of revision 0 at log index 0,
+	// hence the log index of a SignedMapRoot equals its MapRevision.
+	respEpoch := resp.GetMapRoot().GetMapRevision()
 	// Fetch log proofs.
 	logRoot, logConsistency, logInclusion, err := s.logProofs(ctx, in.GetFirstTreeSize(), respEpoch)
 	if err != nil {
@@ -139,6 +144,32 @@ func (s *Server) GetMutations(ctx context.Context, in *tpb.GetMutationsRequest)
 	}, nil
 }
 
+// GetEpoch returns all mutations of epoch, following the pages of
+// GetMutations, together with the proofs of the epoch's map root. Log proofs
+// are relative to firstTreeSize.
+func (s *Server) GetEpoch(ctx context.Context, epoch, firstTreeSize int64) (*tpb.GetMutationsResponse, error) {
+	req := &tpb.GetMutationsRequest{
+		Epoch:         epoch,
+		FirstTreeSize: firstTreeSize,
+		PageSize:      maxPageSize,
+	}
+	resp, err := s.GetMutations(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for token := resp.GetNextPageToken(); token != ""; {
+		req.PageToken = token
+		page, err := s.GetMutations(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Mutations = append(resp.Mutations, page.GetMutations()...)
+		token = page.GetNextPageToken()
+	}
+	resp.NextPageToken = ""
+	return resp, nil
+}
+
 func (s *Server) logProofs(ctx context.Context, firstTreeSize int64, epoch int64) (*trillian.GetLatestSignedLogRootResponse, *trillian.GetConsistencyProofResponse, *trillian.GetInclusionProofResponse, error) {
 	logRoot, err := s.tlog.GetLatestSignedLogRoot(ctx,
 		&trillian.GetLatestSignedLogRootRequest{
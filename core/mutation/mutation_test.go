@@ -17,6 +17,8 @@
 package mutation
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
 	"fmt"
 	"reflect"
@@ -26,7 +28,6 @@ import (
 	"github.com/google/keytransparency/core/transaction"
 
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 
 	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
 	"github.com/google/trillian"
@@ -43,39 +44,68 @@ func signedKV(t *testing.T, start, end int) []*tpb.SignedKV {
 	}
 	kvs := make([]*tpb.SignedKV, 0, end-start)
 	for i := start; i <= end; i++ {
+		// Keys are map indexes.
+		index := sha256.Sum256([]byte(fmt.Sprintf("key_%v", i)))
 		kvs = append(kvs, &tpb.SignedKV{
 			KeyValue: &tpb.KeyValue{
-				Key:   []byte(fmt.Sprintf("key_%v", i)),
+				Key:   index[:],
 				Value: []byte(fmt.Sprintf("value_%v", i)),
 			}})
 	}
 	return kvs
 }
 
-func prepare(t *testing.T, mutations *fakeMutation, fakeMap *fakeTrillianMapClient) {
+func prepare(t *testing.T, mutations *fakeMutation, fakeMap *fake.TrillianMap) {
 	createEpoch(t, mutations, fakeMap, 1, 1, 6)
 	createEpoch(t, mutations, fakeMap, 2, 7, 10)
 }
 
-func createEpoch(t *testing.T, mutations *fakeMutation, fakeMap *fakeTrillianMapClient, epoch int64, start, end int) {
+// createEpoch writes the mutations start to end and applies them to the map
+// in a new revision, epoch.
+func createEpoch(t *testing.T, mutations *fakeMutation, fakeMap *fake.TrillianMap, epoch int64, start, end int) {
 	kvs := signedKV(t, start, end)
+	leaves := make([]*trillian.MapLeaf, 0, len(kvs))
 	for _, kv := range kvs {
 		if _, err := mutations.Write(nil, kv); err != nil {
 			t.Fatalf("mutations.Write failed: %v", err)
 		}
+		leaves = append(leaves, &trillian.MapLeaf{
+			Index:     kv.GetKeyValue().GetKey(),
+			LeafValue: kv.GetKeyValue().GetValue(),
+		})
 	}
-	fakeMap.tmap[epoch] = &trillian.SignedMapRoot{
-		Metadata: &trillian.MapperMetadata{
+	resp, err := fakeMap.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{
+		MapId:  mapID,
+		Leaves: leaves,
+		MapperData: &trillian.MapperMetadata{
 			HighestFullyCompletedSeq: int64(end),
 		},
-		MapRevision: epoch,
+	})
+	if err != nil {
+		t.Fatalf("SetLeaves(): %v", err)
+	}
+	if got, want := resp.GetMapRoot().GetMapRevision(), epoch; got != want {
+		t.Fatalf("SetLeaves(): revision %v, want %v", got, want)
 	}
 }
 
+// newFakeLog returns a log containing the map roots of epochs 0 to last.
+func newFakeLog(t *testing.T, last int64) *fake.TrillianLog {
+	tlog := fake.NewFakeTrillianLogClient()
+	for epoch := int64(0); epoch <= last; epoch++ {
+		if _, err := tlog.QueueLeaf(context.Background(), &trillian.QueueLeafRequest{
+			Leaf: &trillian.LogLeaf{LeafValue: []byte(fmt.Sprintf("epoch %v", epoch))},
+		}); err != nil {
+			t.Fatalf("QueueLeaf(): %v", err)
+		}
+	}
+	return tlog
+}
+
 func TestGetMutations(t *testing.T) {
 	ctx := context.Background()
 	fakeMutations := &fakeMutation{}
-	fakeMap := newFakeTrillianMapClient()
+	fakeMap := fake.NewFakeTrillianMapClient(mapID)
 	prepare(t, fakeMutations, fakeMap)
 
 	for _, tc := range []struct {
@@ -97,7 +127,7 @@ func TestGetMutations(t *testing.T) {
 		{"working case with page token and small page size", 1, "2", 2, signedKV(t, 3, 4), "4", true},
 		{"invalid page token", 1, "some_token", 0, nil, "", false},
 	} {
-		srv := New(logID, mapID, fake.NewFakeTrillianLogClient(), fakeMap, fakeMutations, &fakeFactory{})
+		srv := New(logID, mapID, newFakeLog(t, 2), fakeMap, fakeMutations, &fakeFactory{})
 		resp, err := srv.GetMutations(ctx, &tpb.GetMutationsRequest{
 			Epoch:     tc.epoch,
 			PageToken: tc.token,
@@ -119,6 +149,17 @@ func TestGetMutations(t *testing.T) {
 			if got, want := resp.Mutations[i].Update, tc.mutations[i]; !reflect.DeepEqual(got, want) {
 				t.Errorf("%v: resp.Mutations[i].Update=%v, want %v", tc.description, got, want)
 			}
+			// Proofs are of the leaves the mutations were applied to.
+			proof := resp.Mutations[i].GetProof()
+			if got, want := proof.GetLeaf().GetIndex(), tc.mutations[i].GetKeyValue().GetKey(); !bytes.Equal(got, want) {
+				t.Errorf("%v: resp.Mutations[%v].Proof.Leaf.Index=%x, want %x", tc.description, i, got, want)
+			}
+			if got := len(proof.GetInclusion()); got != 256 {
+				t.Errorf("%v: resp.Mutations[%v] has %v inclusion proof hashes, want 256", tc.description, i, got)
+			}
+		}
+		if got, want := resp.GetSmr().GetMapRevision(), tc.epoch; got != want {
+			t.Errorf("%v: resp.Smr.MapRevision=%v, want %v", tc.description, got, want)
 		}
 		if got, want := resp.NextPageToken, tc.nextToken; got != want {
 			t.Errorf("%v: resp.NextPageToken=%v, %v", tc.description, got, want)
@@ -129,7 +170,7 @@ func TestGetMutations(t *testing.T) {
 func TestLowestSequenceNumber(t *testing.T) {
 	ctx := context.Background()
 	fakeMutations := &fakeMutation{}
-	fakeMap := newFakeTrillianMapClient()
+	fakeMap := fake.NewFakeTrillianMapClient(mapID)
 	prepare(t, fakeMutations, fakeMap)
 
 	for _, tc := range []struct {
@@ -155,46 +196,6 @@ func TestLowestSequenceNumber(t *testing.T) {
 	}
 }
 
-// trillian.TrillianMapClient fake.
-type fakeTrillianMapClient struct {
-	tmap map[int64]*trillian.SignedMapRoot
-}
-
-func newFakeTrillianMapClient() *fakeTrillianMapClient {
-	return &fakeTrillianMapClient{
-		tmap: make(map[int64]*trillian.SignedMapRoot),
-	}
-}
-
-func (*fakeTrillianMapClient) GetLeaves(ctx context.Context, in *trillian.GetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.GetMapLeavesResponse, error) {
-	leaves := make([]*trillian.MapLeafInclusion, 0, len(in.Index))
-	for _, index := range in.Index {
-		leaves = append(leaves, &trillian.MapLeafInclusion{
-			Leaf: &trillian.MapLeaf{
-				Index: index,
-			},
-		})
-	}
-	return &trillian.GetMapLeavesResponse{
-		MapLeafInclusion: leaves,
-	}, nil
-}
-
-func (*fakeTrillianMapClient) SetLeaves(ctx context.Context, in *trillian.SetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.SetMapLeavesResponse, error) {
-	return nil, nil
-}
-
-func (*fakeTrillianMapClient) GetSignedMapRoot(ctx context.Context, in *trillian.GetSignedMapRootRequest, opts ...grpc.CallOption) (*trillian.GetSignedMapRootResponse, error) {
-	return nil, nil
-}
-
-// GetSignedMapRootByRevision echos in.MapId in HighestFullyCompletedSeq.
-func (m *fakeTrillianMapClient) GetSignedMapRootByRevision(ctx context.Context, in *trillian.GetSignedMapRootByRevisionRequest, opts ...grpc.CallOption) (*trillian.GetSignedMapRootResponse, error) {
-	return &trillian.GetSignedMapRootResponse{
-		MapRoot: m.tmap[in.Revision],
-	}, nil
-}
-
 // transaction.Txn fake.
 type fakeTxn struct{}
 
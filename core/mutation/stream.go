@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutation
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/trillian"
+)
+
+// defaultStreamPollPeriod is the default period in which GetMutationsStream
+// checks for new epochs once all existing epochs have been sent.
+const defaultStreamPollPeriod = 5 * time.Second
+
+// SetStreamPollPeriod sets the period in which GetMutationsStream checks for
+// new epochs once all existing epochs have been sent.
+func (s *Server) SetStreamPollPeriod(period time.Duration) {
+	s.streamPollPeriod = period
+}
+
+// GetMutationsStream sends the mutations and proofs of every epoch starting at
+// in.Epoch, in order, to send. After all existing epochs have been sent it
+// waits for new epochs until ctx is done or send fails. Clients resume after a
+// disconnect by setting in.Epoch to the epoch following the last one received.
+func (s *Server) GetMutationsStream(ctx context.Context, in *tpb.GetMutationsRequest, send func(*tpb.GetMutationsResponse) error) error {
+	if err := validateGetMutationsRequest(in); err != nil {
+		glog.Errorf("validateGetMutationsRequest(%v): %v", in, err)
+		return grpc.Errorf(codes.InvalidArgument, "Invalid request")
+	}
+	epoch := in.Epoch
+	treeSize := in.FirstTreeSize
+	for {
+		latest, err := s.latestEpoch(ctx)
+		if err != nil {
+			return err
+		}
+		for ; epoch <= latest; epoch++ {
+			resp, err := s.GetEpoch(ctx, epoch, treeSize)
+			if err != nil {
+				return err
+			}
+			if err := send(resp); err != nil {
+				return err
+			}
+			treeSize = resp.GetLogRoot().GetTreeSize()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.streamPollPeriod):
+		}
+	}
+}
+
+// latestEpoch returns the latest epoch whose SignedMapRoot is contained in
+// the log.
+func (s *Server) latestEpoch(ctx context.Context) (int64, error) {
+	logRoot, err := s.tlog.GetLatestSignedLogRoot(ctx,
+		&trillian.GetLatestSignedLogRootRequest{
+			LogId: s.logID,
+		})
+	if err != nil {
+		glog.Errorf("tlog.GetLatestSignedLogRoot(%v): %v", s.logID, err)
+		return 0, grpc.Errorf(codes.Internal, "Cannot fetch SignedLogRoot")
+	}
+	// The maximum index in the log is one minus the number of items in the
+	// log. The SignedMapRoot of epoch e is stored at index e.
+	return logRoot.GetSignedLogRoot().GetTreeSize() - 1, nil
+}
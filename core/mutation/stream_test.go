@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/fake"
+
+	"golang.org/x/net/context"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+func TestGetMutationsStream(t *testing.T) {
+	errDone := errors.New("done")
+	ctx := context.Background()
+	fakeMutations := &fakeMutation{}
+	fakeMap := fake.NewFakeTrillianMapClient(mapID)
+	prepare(t, fakeMutations, fakeMap)
+	// The log contains the map roots of epochs 0, 1 and 2.
+	fakeLog := newFakeLog(t, 2)
+	srv := New(logID, mapID, fakeLog, fakeMap, fakeMutations, &fakeFactory{})
+	srv.SetStreamPollPeriod(time.Millisecond)
+
+	for _, tc := range []struct {
+		start      int64
+		wantEpochs []int64
+		wantMuts   []int
+	}{
+		{1, []int64{1, 2}, []int{6, 4}},
+		{2, []int64{2}, []int{4}}, // Resume.
+	} {
+		var epochs []int64
+		var muts []int
+		err := srv.GetMutationsStream(ctx, &tpb.GetMutationsRequest{Epoch: tc.start},
+			func(resp *tpb.GetMutationsResponse) error {
+				epochs = append(epochs, resp.Epoch)
+				muts = append(muts, len(resp.Mutations))
+				if resp.Epoch == 2 {
+					return errDone
+				}
+				return nil
+			})
+		if err != errDone {
+			t.Errorf("GetMutationsStream(%v): %v, want %v", tc.start, err, errDone)
+		}
+		if got, want := epochs, tc.wantEpochs; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetMutationsStream(%v) epochs: %v, want %v", tc.start, got, want)
+		}
+		if got, want := muts, tc.wantMuts; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetMutationsStream(%v) len(mutations): %v, want %v", tc.start, got, want)
+		}
+	}
+}
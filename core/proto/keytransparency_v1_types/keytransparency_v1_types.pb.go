@@ -45,6 +45,7 @@ import fmt "fmt"
 import math "math"
 import keyspb "github.com/google/trillian/crypto/keyspb"
 import sigpb "github.com/google/trillian/crypto/sigpb"
+import google_rpc "google.golang.org/genproto/googleapis/rpc/status"
 import trillian "github.com/google/trillian"
 import trillian1 "github.com/google/trillian"
 
@@ -873,6 +874,9 @@ func (m *BatchUpdateEntriesRequest) GetKeyId() string {
 type BatchUpdateEntriesResponse struct {
 	// errors is a map from user_ids to errors, if there was an error for that user.
 	Errors map[string]string `protobuf:"bytes,1,rep,name=errors" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// statuses is a map from user_ids to the status of their failed update.
+	// Rejected updates carry the reason of the rejection in the details.
+	Statuses map[string]*google_rpc.Status `protobuf:"bytes,2,rep,name=statuses" json:"statuses,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *BatchUpdateEntriesResponse) Reset()                    { *m = BatchUpdateEntriesResponse{} }
@@ -887,6 +891,13 @@ func (m *BatchUpdateEntriesResponse) GetErrors() map[string]string {
 	return nil
 }
 
+func (m *BatchUpdateEntriesResponse) GetStatuses() map[string]*google_rpc.Status {
+	if m != nil {
+		return m.Statuses
+	}
+	return nil
+}
+
 // GetEpochsRequest is an empty proto message used as input to GetEpochs API.
 type GetEpochsRequest struct {
 }
@@ -942,82 +953,85 @@ func init() {
 func init() { proto.RegisterFile("keytransparency_v1_types.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1225 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0xdd, 0x6e, 0x1b, 0x37,
-	0x13, 0xcd, 0x4a, 0x5e, 0x59, 0x1a, 0x3b, 0x76, 0xc2, 0x38, 0xce, 0x46, 0x1f, 0x12, 0xf8, 0xdb,
-	0xa0, 0x6d, 0x5a, 0x14, 0x6a, 0xac, 0xc0, 0x69, 0x93, 0x00, 0x6d, 0x9a, 0x1f, 0xc4, 0x86, 0x1d,
-	0xc0, 0xa0, 0x93, 0xb4, 0x77, 0x0b, 0x5a, 0x4b, 0xc9, 0x84, 0x56, 0xcb, 0x2d, 0x49, 0x09, 0x59,
-	0x03, 0x05, 0xf2, 0x00, 0x05, 0x8a, 0xbe, 0x43, 0x5f, 0xa0, 0x37, 0x7d, 0x81, 0xbe, 0x45, 0x1f,
-	0xa4, 0xd7, 0x05, 0x7f, 0x56, 0x5a, 0xd9, 0x92, 0x1d, 0xe7, 0xa2, 0x37, 0x36, 0x39, 0x9c, 0x21,
-	0x67, 0xce, 0x9c, 0xc3, 0xa5, 0xe0, 0x76, 0x9f, 0xe6, 0x4a, 0x90, 0x54, 0x66, 0x44, 0xd0, 0xb4,
-	0x93, 0x47, 0xa3, 0xcd, 0x48, 0xe5, 0x19, 0x95, 0xad, 0x4c, 0x70, 0xc5, 0x51, 0x70, 0x62, 0xbd,
-	0x35, 0xda, 0x6c, 0x99, 0xf5, 0x66, 0xb3, 0x23, 0xf2, 0x4c, 0xf1, 0xaf, 0xfa, 0x34, 0x97, 0xd9,
-	0xa1, 0xfb, 0x67, 0xa3, 0x9a, 0x81, 0x5b, 0x93, 0xac, 0x97, 0x1d, 0xda, 0xbf, 0x6e, 0x65, 0x45,
-	0x09, 0x96, 0x24, 0x8c, 0xa4, 0x6e, 0xbe, 0x5e, 0xcc, 0xa3, 0x01, 0xc9, 0x22, 0x92, 0x31, 0x6b,
-	0x0f, 0x37, 0xa1, 0xf1, 0x8c, 0x0f, 0x06, 0x4c, 0x29, 0x1a, 0xa3, 0x2b, 0x50, 0xed, 0xd3, 0x3c,
-	0xf0, 0x36, 0xbc, 0xbb, 0xcb, 0x58, 0x0f, 0x11, 0x82, 0x85, 0x98, 0x28, 0x12, 0x54, 0x8c, 0xc9,
-	0x8c, 0xc3, 0x5f, 0x3c, 0x58, 0x7a, 0x91, 0x2a, 0x91, 0xbf, 0xc9, 0x62, 0xa2, 0x28, 0x7a, 0x04,
-	0xb5, 0xa1, 0x19, 0x19, 0xaf, 0xa5, 0x76, 0xd8, 0x9a, 0x57, 0x4b, 0xeb, 0x80, 0xf5, 0x52, 0x1a,
-	0xef, 0xbe, 0xc5, 0x2e, 0x02, 0x7d, 0x0f, 0x8d, 0x4e, 0x71, 0x7c, 0x50, 0x35, 0xe1, 0x77, 0xe6,
-	0x87, 0x8f, 0x33, 0xc5, 0x93, 0xa8, 0xf0, 0x37, 0x0f, 0x7c, 0x93, 0x0e, 0xba, 0x0d, 0x60, 0xcd,
-	0x03, 0x9a, 0x2a, 0x57, 0x45, 0xc9, 0x82, 0xf6, 0x60, 0x95, 0x0c, 0xd5, 0x11, 0x17, 0xec, 0x98,
-	0xc6, 0x91, 0x06, 0x32, 0xa8, 0x6c, 0x54, 0xcf, 0x3e, 0x72, 0x7f, 0x78, 0x98, 0xb0, 0xce, 0x2e,
-	0xcd, 0xf1, 0xca, 0x24, 0x76, 0x97, 0xe6, 0x12, 0x35, 0xa1, 0x9e, 0x09, 0x3a, 0x62, 0x7c, 0x28,
-	0x4d, 0xe6, 0xcb, 0x78, 0x3c, 0x0f, 0x7f, 0xf7, 0xa0, 0x31, 0x8e, 0x44, 0x4d, 0x58, 0xa4, 0x71,
-	0x7b, 0x6b, 0x6b, 0xf3, 0xa1, 0x4d, 0x6a, 0xfb, 0x12, 0x2e, 0x0c, 0xe8, 0x31, 0xdc, 0x14, 0x92,
-	0x44, 0x23, 0x2a, 0x58, 0x37, 0x67, 0x69, 0x2f, 0x92, 0x47, 0xa4, 0xbd, 0xf5, 0x20, 0xba, 0x7f,
-	0xef, 0xeb, 0xb6, 0x45, 0x7d, 0xfb, 0x12, 0x5e, 0x17, 0x92, 0xbc, 0x2d, 0x3c, 0x0e, 0x8c, 0x83,
-	0x5e, 0x47, 0x6d, 0x58, 0xa3, 0x9d, 0x78, 0x2a, 0x3c, 0x6b, 0x6f, 0x3d, 0xb0, 0xe9, 0x6c, 0x5f,
-	0xc2, 0xc8, 0xac, 0x8e, 0x23, 0xf7, 0xdb, 0x5b, 0x0f, 0x9e, 0x02, 0xd4, 0xfb, 0x34, 0x37, 0xdc,
-	0x0b, 0xdb, 0x50, 0xdf, 0xa5, 0xf9, 0x5b, 0x92, 0x0c, 0xe9, 0x8c, 0xde, 0xaf, 0x81, 0x3f, 0xd2,
-	0x4b, 0xae, 0xf9, 0x76, 0x12, 0xfe, 0xe3, 0x41, 0xbd, 0x68, 0x23, 0xfa, 0x0e, 0x1a, 0x7a, 0x33,
-	0xeb, 0xe6, 0x9d, 0xd7, 0xfd, 0xe2, 0x2c, 0xac, 0x33, 0xb0, 0xa7, 0x62, 0x00, 0xc9, 0x7a, 0x29,
-	0x51, 0x43, 0x41, 0x8b, 0x6e, 0xb4, 0xcf, 0xe7, 0x8f, 0x19, 0xd8, 0x20, 0xd3, 0x7a, 0x5c, 0xda,
-	0xa5, 0xf9, 0x06, 0x56, 0x4f, 0x2c, 0x97, 0x8b, 0x6b, 0xd8, 0xe2, 0xbe, 0x2c, 0x17, 0xb7, 0xd4,
-	0x5e, 0x6f, 0x59, 0xf1, 0x3c, 0x67, 0x3d, 0xa6, 0x48, 0x92, 0xe4, 0xf6, 0x24, 0x57, 0xf4, 0xa3,
-	0xca, 0x37, 0x5e, 0xf8, 0x0e, 0xea, 0xaf, 0x86, 0x8a, 0x28, 0xc6, 0xd3, 0x12, 0xe5, 0xbd, 0x0b,
-	0x53, 0xfe, 0x1e, 0xf8, 0x99, 0xe0, 0xbc, 0xeb, 0x4e, 0x6e, 0xb6, 0xc6, 0x4a, 0x7d, 0x45, 0xb2,
-	0x3d, 0x4a, 0xba, 0x3b, 0x69, 0x27, 0x19, 0x4a, 0xc6, 0x53, 0x6c, 0x1d, 0x43, 0x06, 0xab, 0x2f,
-	0xa9, 0xb2, 0x85, 0xd2, 0x9f, 0x86, 0x54, 0x2a, 0x74, 0x03, 0x16, 0x87, 0x92, 0x8a, 0x88, 0xc5,
-	0xae, 0xa8, 0x9a, 0x9e, 0xee, 0xc4, 0xe8, 0x3a, 0xd4, 0x48, 0x96, 0x69, 0x7b, 0xc5, 0xd8, 0x7d,
-	0x92, 0x65, 0x3b, 0x31, 0xfa, 0x14, 0x56, 0xbb, 0x4c, 0x48, 0x15, 0x29, 0x41, 0x69, 0x24, 0xd9,
-	0x31, 0x35, 0x24, 0xa9, 0xe2, 0xcb, 0xc6, 0xfc, 0x5a, 0x50, 0x7a, 0xc0, 0x8e, 0x69, 0xf8, 0x77,
-	0x05, 0xae, 0x4c, 0xce, 0x92, 0x19, 0x4f, 0x25, 0x45, 0xff, 0x83, 0xc6, 0x48, 0x74, 0x23, 0x9b,
-	0xb5, 0x25, 0x48, 0x7d, 0x24, 0xba, 0xfb, 0x7a, 0x3e, 0xad, 0xe0, 0xca, 0xc7, 0x28, 0x18, 0x3d,
-	0x04, 0x48, 0x28, 0x29, 0x0e, 0xa8, 0x9e, 0x0b, 0x4b, 0x43, 0x7b, 0xdb, 0xd3, 0x3f, 0x87, 0xaa,
-	0x1c, 0x88, 0x60, 0xc1, 0xc4, 0xdc, 0x98, 0xc4, 0x58, 0xd4, 0x5f, 0x91, 0x0c, 0x73, 0xae, 0xb0,
-	0xf6, 0x41, 0x6d, 0xa8, 0x27, 0xbc, 0x17, 0x09, 0xce, 0x55, 0xe0, 0xcf, 0xf6, 0xdf, 0xe3, 0x3d,
-	0xe3, 0xbf, 0x98, 0xd8, 0x01, 0xfa, 0x0c, 0x56, 0x75, 0x4c, 0x87, 0xa7, 0x92, 0x49, 0xa5, 0x4b,
-	0x09, 0x6a, 0x1b, 0xd5, 0xbb, 0xcb, 0x78, 0x25, 0xe1, 0xbd, 0x67, 0x13, 0x2b, 0xba, 0x03, 0x97,
-	0xb5, 0x23, 0x2b, 0x72, 0x0c, 0x16, 0x8d, 0xdb, 0x72, 0xc2, 0x7b, 0xe3, 0xbc, 0xf5, 0xad, 0x70,
-	0x63, 0x8f, 0x49, 0x8b, 0xee, 0x36, 0x93, 0x8a, 0x7f, 0x40, 0x43, 0xd7, 0xc0, 0x97, 0x8a, 0x08,
-	0x65, 0xb0, 0xad, 0x62, 0x3b, 0xd1, 0x2d, 0xc9, 0x48, 0xaf, 0xd4, 0x49, 0x1f, 0xd7, 0xb5, 0x41,
-	0x37, 0xb1, 0xc4, 0x81, 0x85, 0x73, 0x38, 0xe0, 0xcf, 0xe2, 0xc0, 0xcf, 0x10, 0x9c, 0xce, 0xd2,
-	0x51, 0xe1, 0x29, 0xd4, 0x8c, 0x22, 0x64, 0xe0, 0x19, 0xad, 0x7e, 0x31, 0xbf, 0xd5, 0x27, 0x69,
-	0x84, 0x5d, 0x24, 0xba, 0x05, 0x90, 0xd2, 0x77, 0x2a, 0x2a, 0x97, 0xd5, 0xd0, 0x96, 0x03, 0x6d,
-	0x08, 0xff, 0xf4, 0x00, 0xd9, 0x2f, 0xcb, 0x7f, 0xc1, 0x78, 0xb4, 0x0d, 0xcb, 0x54, 0x9f, 0x13,
-	0x39, 0x41, 0x5b, 0x2a, 0x7d, 0x32, 0xbf, 0xae, 0xd2, 0xa7, 0x0f, 0x2f, 0xd1, 0xc9, 0x24, 0xfc,
-	0x01, 0xae, 0x4d, 0xe5, 0xed, 0x20, 0x7b, 0x52, 0xe8, 0xdd, 0x5e, 0x15, 0x17, 0x41, 0xcc, 0xe9,
-	0xff, 0x57, 0x0f, 0xae, 0xbd, 0xa4, 0xaa, 0xb8, 0x7d, 0x64, 0x01, 0xc9, 0x1a, 0xf8, 0x34, 0xe3,
-	0x9d, 0x23, 0xb3, 0x73, 0x15, 0xdb, 0xc9, 0xac, 0xc2, 0x2b, 0xb3, 0x0a, 0xbf, 0x05, 0x60, 0x28,
-	0xa4, 0x78, 0x9f, 0xa6, 0x06, 0x9b, 0x06, 0x36, 0xa4, 0x7a, 0xad, 0x0d, 0xd3, 0x0c, 0x5b, 0x98,
-	0x66, 0x58, 0xf8, 0x57, 0x05, 0xd6, 0xa6, 0x33, 0x72, 0xc5, 0xce, 0x4e, 0xc9, 0xa9, 0xb4, 0x72,
-	0x41, 0x95, 0x56, 0x3f, 0x5e, 0xa5, 0x0b, 0x1f, 0xa6, 0x52, 0xff, 0xb4, 0x4a, 0xd1, 0x13, 0x68,
-	0x0c, 0x8a, 0xba, 0x8c, 0xda, 0xcf, 0xbc, 0xde, 0x0b, 0x08, 0xf0, 0x24, 0x48, 0x77, 0xc0, 0x10,
-	0xbc, 0x04, 0xef, 0xa2, 0x81, 0xf7, 0xb2, 0x36, 0xef, 0x17, 0x10, 0x87, 0xeb, 0x06, 0xc4, 0xe7,
-	0x7c, 0x40, 0x58, 0xba, 0x93, 0x76, 0xb9, 0xeb, 0x6b, 0xf8, 0xde, 0x83, 0xeb, 0x27, 0x16, 0x1c,
-	0xbc, 0x1b, 0x50, 0x4d, 0x78, 0xcf, 0x31, 0x69, 0x65, 0x02, 0x8c, 0x6e, 0x2a, 0xd6, 0x4b, 0xda,
-	0x63, 0x40, 0x32, 0x07, 0xf5, 0x29, 0x8f, 0x01, 0xc9, 0xd0, 0x1d, 0xa8, 0x8e, 0x44, 0x71, 0xcd,
-	0x5e, 0x6d, 0xb9, 0xf7, 0xe4, 0xe4, 0x9d, 0xa3, 0x57, 0xc3, 0xff, 0xc3, 0xd2, 0x1b, 0x49, 0xc5,
-	0xbe, 0xe0, 0x5d, 0x96, 0xd0, 0xf1, 0x33, 0xd0, 0x2b, 0x3d, 0x03, 0xdf, 0x57, 0xe0, 0xe6, 0x53,
-	0xa2, 0x3a, 0x47, 0x13, 0xd2, 0x33, 0x3a, 0xe6, 0xe6, 0x6b, 0xf0, 0xb5, 0x3e, 0x8b, 0x7b, 0xe2,
-	0xdb, 0xf9, 0x08, 0xce, 0xdd, 0xa3, 0xa5, 0x33, 0x70, 0xdf, 0x77, 0xbb, 0xd9, 0x3c, 0xad, 0x5f,
-	0x87, 0x9a, 0x7e, 0x86, 0xb0, 0xd8, 0xd1, 0xd8, 0xef, 0xd3, 0x7c, 0x27, 0x6e, 0x46, 0x00, 0x93,
-	0x2d, 0x66, 0xbc, 0x01, 0x1e, 0x4f, 0xbf, 0x01, 0xce, 0xd0, 0x7c, 0x09, 0x8b, 0xf2, 0x93, 0xe0,
-	0x0f, 0x0f, 0x9a, 0xb3, 0xd2, 0x77, 0xdd, 0xfa, 0x11, 0x6a, 0x54, 0x08, 0x3e, 0x06, 0xe1, 0xc9,
-	0xc5, 0x40, 0xb0, 0xbb, 0xb4, 0x5e, 0x98, 0x2d, 0x2c, 0x0c, 0x6e, 0xbf, 0xe6, 0x43, 0x58, 0x2a,
-	0x99, 0x67, 0x94, 0x36, 0xf5, 0x76, 0x6b, 0x94, 0x73, 0x46, 0xf6, 0x03, 0xaf, 0x75, 0x59, 0x00,
-	0x1d, 0x12, 0xb8, 0x5a, 0xb2, 0xb9, 0xec, 0xf7, 0xca, 0x3a, 0xb0, 0x8c, 0x6b, 0x9d, 0x79, 0x77,
-	0x9d, 0xba, 0x0d, 0x4a, 0x9a, 0x38, 0xac, 0x99, 0x9f, 0x1b, 0xf7, 0xff, 0x0d, 0x00, 0x00, 0xff,
-	0xff, 0xba, 0xa9, 0xd3, 0xc5, 0x08, 0x0d, 0x00, 0x00,
+	// 1279 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0xef, 0x6e, 0x13, 0x47,
+	0x10, 0xe7, 0xec, 0xd8, 0xb1, 0x27, 0xff, 0x60, 0x09, 0x89, 0x71, 0x05, 0x4a, 0x0f, 0xb5, 0xa5,
+	0x55, 0x75, 0x10, 0xa3, 0xd0, 0x02, 0x52, 0x4b, 0x03, 0x88, 0x44, 0x09, 0x6a, 0x74, 0x01, 0xda,
+	0x4f, 0x3d, 0x6d, 0xec, 0xf5, 0x65, 0xe5, 0xf3, 0xed, 0x76, 0x77, 0x6d, 0x71, 0x48, 0x95, 0x78,
+	0x80, 0x4a, 0x55, 0xdf, 0xa1, 0xcf, 0xd0, 0x17, 0xe8, 0x5b, 0xf4, 0x63, 0x1f, 0xa2, 0x9f, 0xab,
+	0xfd, 0x73, 0xf6, 0x39, 0xd8, 0x09, 0xc9, 0x87, 0x7e, 0x49, 0x76, 0x67, 0x67, 0x66, 0x67, 0x7e,
+	0xf3, 0x9b, 0xbd, 0x31, 0xdc, 0xec, 0x91, 0x4c, 0x09, 0x9c, 0x4a, 0x8e, 0x05, 0x49, 0xdb, 0x59,
+	0x34, 0xdc, 0x8c, 0x54, 0xc6, 0x89, 0x0c, 0xb8, 0x60, 0x8a, 0xa1, 0xc6, 0x89, 0xf3, 0x60, 0xb8,
+	0x19, 0x98, 0xf3, 0x66, 0xb3, 0x2d, 0x32, 0xae, 0xd8, 0x9d, 0x1e, 0xc9, 0x24, 0x3f, 0x72, 0xff,
+	0xac, 0x55, 0xb3, 0xe1, 0xce, 0x24, 0x8d, 0xf9, 0x91, 0xfd, 0xeb, 0x4e, 0xd6, 0x63, 0xc6, 0xe2,
+	0x84, 0xdc, 0x11, 0xbc, 0x7d, 0x47, 0x2a, 0xac, 0x06, 0xee, 0xa2, 0xe6, 0xb2, 0x12, 0x34, 0x49,
+	0x28, 0x4e, 0xdd, 0x7e, 0x2d, 0xdf, 0x47, 0x7d, 0xcc, 0x23, 0xcc, 0xa9, 0x95, 0xfb, 0x9b, 0x50,
+	0x7f, 0xc2, 0xfa, 0x7d, 0xaa, 0x14, 0xe9, 0xa0, 0xcb, 0x50, 0xee, 0x91, 0xac, 0xe1, 0x6d, 0x78,
+	0xb7, 0x17, 0x43, 0xbd, 0x44, 0x08, 0xe6, 0x3a, 0x58, 0xe1, 0x46, 0xc9, 0x88, 0xcc, 0xda, 0xff,
+	0xd5, 0x83, 0x85, 0x67, 0xa9, 0x12, 0xd9, 0x2b, 0xde, 0xc1, 0x8a, 0xa0, 0x87, 0x50, 0x1d, 0x98,
+	0x95, 0xd1, 0x5a, 0x68, 0xf9, 0xc1, 0xac, 0x24, 0x83, 0x43, 0x1a, 0xa7, 0xa4, 0xb3, 0xf7, 0x3a,
+	0x74, 0x16, 0xe8, 0x3b, 0xa8, 0xb7, 0xf3, 0xeb, 0x1b, 0x65, 0x63, 0x7e, 0x6b, 0xb6, 0xf9, 0x28,
+	0xd2, 0x70, 0x6c, 0xe5, 0xff, 0xee, 0x41, 0xc5, 0x84, 0x83, 0x6e, 0x02, 0x58, 0x71, 0x9f, 0xa4,
+	0xca, 0x65, 0x51, 0x90, 0xa0, 0x7d, 0x58, 0xc1, 0x03, 0x75, 0xcc, 0x04, 0x7d, 0x4b, 0x3a, 0x91,
+	0x46, 0xb8, 0x51, 0xda, 0x28, 0x9f, 0x7e, 0xe5, 0xc1, 0xe0, 0x28, 0xa1, 0xed, 0x3d, 0x92, 0x85,
+	0xcb, 0x63, 0xdb, 0x3d, 0x92, 0x49, 0xd4, 0x84, 0x1a, 0x17, 0x64, 0x48, 0xd9, 0x40, 0x9a, 0xc8,
+	0x17, 0xc3, 0xd1, 0xde, 0xff, 0xc3, 0x83, 0xfa, 0xc8, 0x12, 0x35, 0x61, 0x9e, 0x74, 0x5a, 0x5b,
+	0x5b, 0x9b, 0x0f, 0x6c, 0x50, 0x3b, 0x97, 0xc2, 0x5c, 0x80, 0x1e, 0xc1, 0x75, 0x21, 0x71, 0x34,
+	0x24, 0x82, 0x76, 0x33, 0x9a, 0xc6, 0x91, 0x3c, 0xc6, 0xad, 0xad, 0xfb, 0xd1, 0xbd, 0xbb, 0x5f,
+	0xb5, 0x2c, 0xea, 0x3b, 0x97, 0xc2, 0x35, 0x21, 0xf1, 0xeb, 0x5c, 0xe3, 0xd0, 0x28, 0xe8, 0x73,
+	0xd4, 0x82, 0x55, 0xd2, 0xee, 0x4c, 0x98, 0xf3, 0xd6, 0xd6, 0x7d, 0x1b, 0xce, 0xce, 0xa5, 0x10,
+	0x99, 0xd3, 0x91, 0xe5, 0x41, 0x6b, 0xeb, 0xfe, 0x36, 0x40, 0xad, 0x47, 0x32, 0x43, 0x4a, 0xbf,
+	0x05, 0xb5, 0x3d, 0x92, 0xbd, 0xc6, 0xc9, 0x80, 0x4c, 0xa9, 0xfd, 0x2a, 0x54, 0x86, 0xfa, 0xc8,
+	0x15, 0xdf, 0x6e, 0xfc, 0x7f, 0x3d, 0xa8, 0xe5, 0x65, 0x44, 0xdf, 0x42, 0x5d, 0x3b, 0xb3, 0x6a,
+	0xde, 0x59, 0xd5, 0xcf, 0xef, 0x0a, 0x6b, 0x3d, 0xb7, 0x42, 0x21, 0x80, 0xa4, 0x71, 0x8a, 0xd5,
+	0x40, 0x90, 0xbc, 0x1a, 0xad, 0xb3, 0xf9, 0x13, 0x1c, 0x8e, 0x8c, 0x4c, 0xe9, 0xc3, 0x82, 0x97,
+	0xe6, 0x2b, 0x58, 0x39, 0x71, 0x5c, 0x4c, 0xae, 0x6e, 0x93, 0xfb, 0xb2, 0x98, 0xdc, 0x42, 0x6b,
+	0x2d, 0xb0, 0x5d, 0xf5, 0x94, 0xc6, 0x54, 0xe1, 0x24, 0xc9, 0xec, 0x4d, 0x2e, 0xe9, 0x87, 0xa5,
+	0xaf, 0x3d, 0xff, 0x0d, 0xd4, 0x5e, 0x0c, 0x14, 0x56, 0x94, 0xa5, 0x05, 0xca, 0x7b, 0xe7, 0xa6,
+	0xfc, 0x5d, 0xa8, 0x70, 0xc1, 0x58, 0xd7, 0xdd, 0xdc, 0x0c, 0x46, 0x9d, 0xfa, 0x02, 0xf3, 0x7d,
+	0x82, 0xbb, 0xbb, 0x69, 0x3b, 0x19, 0x48, 0xca, 0xd2, 0xd0, 0x2a, 0xfa, 0x14, 0x56, 0x9e, 0x13,
+	0x65, 0x13, 0x25, 0x3f, 0x0f, 0x88, 0x54, 0x68, 0x1d, 0xe6, 0x07, 0x92, 0x88, 0x88, 0x76, 0x5c,
+	0x52, 0x55, 0xbd, 0xdd, 0xed, 0xa0, 0x6b, 0x50, 0xc5, 0x9c, 0x6b, 0x79, 0xc9, 0xc8, 0x2b, 0x98,
+	0xf3, 0xdd, 0x0e, 0xfa, 0x14, 0x56, 0xba, 0x54, 0x48, 0x15, 0x29, 0x41, 0x48, 0x24, 0xe9, 0x5b,
+	0x62, 0x48, 0x52, 0x0e, 0x97, 0x8c, 0xf8, 0xa5, 0x20, 0xe4, 0x90, 0xbe, 0x25, 0xfe, 0xdf, 0x25,
+	0xb8, 0x3c, 0xbe, 0x4b, 0x72, 0x96, 0x4a, 0x82, 0x3e, 0x82, 0xfa, 0x50, 0x74, 0x23, 0x1b, 0xb5,
+	0x25, 0x48, 0x6d, 0x28, 0xba, 0x07, 0x7a, 0x3f, 0xd9, 0xc1, 0xa5, 0x8b, 0x74, 0x30, 0x7a, 0x00,
+	0x90, 0x10, 0x9c, 0x5f, 0x50, 0x3e, 0x13, 0x96, 0xba, 0xd6, 0xb6, 0xb7, 0x7f, 0x0e, 0x65, 0xd9,
+	0x17, 0x8d, 0x39, 0x63, 0xb3, 0x3e, 0xb6, 0xb1, 0xa8, 0xbf, 0xc0, 0x3c, 0x64, 0x4c, 0x85, 0x5a,
+	0x07, 0xb5, 0xa0, 0x96, 0xb0, 0x38, 0x12, 0x8c, 0xa9, 0x46, 0x65, 0xba, 0xfe, 0x3e, 0x8b, 0x8d,
+	0xfe, 0x7c, 0x62, 0x17, 0xe8, 0x33, 0x58, 0xd1, 0x36, 0x6d, 0x96, 0x4a, 0x2a, 0x95, 0x4e, 0xa5,
+	0x51, 0xdd, 0x28, 0xdf, 0x5e, 0x0c, 0x97, 0x13, 0x16, 0x3f, 0x19, 0x4b, 0xd1, 0x2d, 0x58, 0xd2,
+	0x8a, 0x34, 0x8f, 0xb1, 0x31, 0x6f, 0xd4, 0x16, 0x13, 0x16, 0x8f, 0xe2, 0xd6, 0xaf, 0xc2, 0xfa,
+	0x3e, 0x95, 0x16, 0xdd, 0x1d, 0x2a, 0x15, 0xfb, 0x80, 0x82, 0xae, 0x42, 0x45, 0x2a, 0x2c, 0x94,
+	0xc1, 0xb6, 0x1c, 0xda, 0x8d, 0x2e, 0x09, 0xc7, 0x71, 0xa1, 0x92, 0x95, 0xb0, 0xa6, 0x05, 0xba,
+	0x88, 0x05, 0x0e, 0xcc, 0x9d, 0xc1, 0x81, 0xca, 0x34, 0x0e, 0xfc, 0x02, 0x8d, 0xf7, 0xa3, 0x74,
+	0x54, 0xd8, 0x86, 0xaa, 0xe9, 0x08, 0xd9, 0xf0, 0x4c, 0xaf, 0x7e, 0x31, 0xbb, 0xd4, 0x27, 0x69,
+	0x14, 0x3a, 0x4b, 0x74, 0x03, 0x20, 0x25, 0x6f, 0x54, 0x54, 0x4c, 0xab, 0xae, 0x25, 0x87, 0x5a,
+	0xe0, 0xff, 0xe9, 0x01, 0xb2, 0x5f, 0x96, 0xff, 0x83, 0xf1, 0x68, 0x07, 0x16, 0x89, 0xbe, 0x27,
+	0x72, 0x0d, 0x6d, 0xa9, 0xf4, 0xc9, 0xec, 0xbc, 0x0a, 0x9f, 0xbe, 0x70, 0x81, 0x8c, 0x37, 0xfe,
+	0x0f, 0x70, 0x75, 0x22, 0x6e, 0x07, 0xd9, 0xe3, 0xbc, 0xdf, 0xed, 0x53, 0x71, 0x1e, 0xc4, 0x5c,
+	0xff, 0xff, 0xe6, 0xc1, 0xd5, 0xe7, 0x44, 0xe5, 0xaf, 0x8f, 0xcc, 0x21, 0x59, 0x85, 0x0a, 0xe1,
+	0xac, 0x7d, 0x6c, 0x3c, 0x97, 0x43, 0xbb, 0x99, 0x96, 0x78, 0x69, 0x5a, 0xe2, 0x37, 0x00, 0x0c,
+	0x85, 0x14, 0xeb, 0x91, 0xd4, 0x60, 0x53, 0x0f, 0x0d, 0xa9, 0x5e, 0x6a, 0xc1, 0x24, 0xc3, 0xe6,
+	0x26, 0x19, 0xe6, 0xff, 0x55, 0x82, 0xd5, 0xc9, 0x88, 0x5c, 0xb2, 0xd3, 0x43, 0x72, 0x5d, 0x5a,
+	0x3a, 0x67, 0x97, 0x96, 0x2f, 0xde, 0xa5, 0x73, 0x1f, 0xd6, 0xa5, 0x95, 0xf7, 0xbb, 0x14, 0x3d,
+	0x86, 0x7a, 0x3f, 0xcf, 0xcb, 0x74, 0xfb, 0xa9, 0xcf, 0x7b, 0x0e, 0x41, 0x38, 0x36, 0xd2, 0x15,
+	0x30, 0x04, 0x2f, 0xc0, 0x3b, 0x6f, 0xe0, 0x5d, 0xd2, 0xe2, 0x83, 0x1c, 0x62, 0x7f, 0xcd, 0x80,
+	0xf8, 0x94, 0xf5, 0x31, 0x4d, 0x77, 0xd3, 0x2e, 0x73, 0x75, 0xf5, 0xdf, 0x79, 0x70, 0xed, 0xc4,
+	0x81, 0x83, 0x77, 0x03, 0xca, 0x09, 0x8b, 0x1d, 0x93, 0x96, 0xc7, 0xc0, 0xe8, 0xa2, 0x86, 0xfa,
+	0x48, 0x6b, 0xf4, 0x31, 0x6f, 0x94, 0xa6, 0x6b, 0xf4, 0x31, 0x47, 0xb7, 0xa0, 0x3c, 0x14, 0xf9,
+	0x33, 0x7b, 0x25, 0x70, 0x83, 0xe6, 0x78, 0xce, 0xd1, 0xa7, 0xfe, 0xc7, 0xb0, 0xf0, 0x4a, 0x12,
+	0x71, 0x20, 0x58, 0x97, 0x26, 0x64, 0x34, 0x06, 0x7a, 0x85, 0x31, 0xf0, 0x5d, 0x09, 0xae, 0x6f,
+	0x63, 0xd5, 0x3e, 0x1e, 0x93, 0x9e, 0x92, 0x11, 0x37, 0x5f, 0x42, 0x45, 0xf7, 0x67, 0xfe, 0x4e,
+	0x7c, 0x33, 0x1b, 0xc1, 0x99, 0x3e, 0x02, 0x1d, 0x81, 0xfb, 0xbe, 0x5b, 0x67, 0xb3, 0x7a, 0xfd,
+	0x1a, 0x54, 0xf5, 0x18, 0x42, 0x3b, 0x8e, 0xc6, 0x95, 0x1e, 0xc9, 0x76, 0x3b, 0xcd, 0x08, 0x60,
+	0xec, 0x62, 0xca, 0x0c, 0xf0, 0x68, 0x72, 0x06, 0x38, 0xa5, 0xe7, 0x0b, 0x58, 0x14, 0x47, 0x82,
+	0x7f, 0x4a, 0xd0, 0x9c, 0x16, 0xbe, 0xab, 0xd6, 0x8f, 0x50, 0x25, 0x42, 0xb0, 0x11, 0x08, 0x8f,
+	0xcf, 0x07, 0x82, 0xf5, 0x12, 0x3c, 0x33, 0x2e, 0x2c, 0x0c, 0xce, 0x1f, 0xfa, 0x09, 0x6a, 0x76,
+	0xda, 0x1f, 0x0d, 0x4d, 0xdb, 0x17, 0xf2, 0x7d, 0xe8, 0x9c, 0x58, 0xef, 0x23, 0x9f, 0xcd, 0x07,
+	0xb0, 0x50, 0xb8, 0x76, 0x0a, 0x74, 0x13, 0xb3, 0x61, 0xbd, 0x80, 0x49, 0xf3, 0x7b, 0x58, 0x9a,
+	0xf0, 0x3a, 0xc5, 0xf8, 0xf6, 0x24, 0xee, 0x28, 0xb0, 0x3f, 0x62, 0x02, 0xc1, 0xdb, 0x2e, 0xa2,
+	0x22, 0xc8, 0xc8, 0x4e, 0x24, 0xfa, 0x21, 0xc9, 0x99, 0xe1, 0x63, 0xb8, 0x52, 0x90, 0x39, 0xb8,
+	0xf7, 0x8b, 0x8d, 0x6b, 0x5b, 0x24, 0x38, 0xf5, 0xb1, 0x7d, 0xef, 0xf9, 0x2a, 0x34, 0xf1, 0x51,
+	0xd5, 0xfc, 0x3e, 0xba, 0xf7, 0xdf, 0x00, 0xfd, 0x9a, 0x61, 0xd5, 0xd2, 0x0d, 0x00, 0x00,
 }
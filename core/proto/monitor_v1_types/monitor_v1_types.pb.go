@@ -13,6 +13,7 @@ It is generated from these files:
 It has these top-level messages:
 	GetMonitoringRequest
 	GetMonitoringResponse
+	MonitoringRecord
 */
 package monitor_v1_types
 
@@ -113,32 +114,83 @@ func (m *GetMonitoringResponse) GetErrorData() *keytransparency_v1_types.GetMuta
 	return nil
 }
 
+// MonitoringRecord is the stored monitoring result of an epoch. Unlike
+// GetMonitoringResponse it always contains the mutations response, such that
+// the verification of any epoch can be re-run from storage.
+type MonitoringRecord struct {
+	// smr contains the map root signed with the monitor's key on success.
+	Smr *trillian.SignedMapRoot `protobuf:"bytes,1,opt,name=smr" json:"smr,omitempty"`
+	// seen_timestamp_nanos contains the time in nanoseconds where the signed
+	// map root was retrieved and processed.
+	SeenTimestampNanos int64 `protobuf:"varint,2,opt,name=seen_timestamp_nanos,json=seenTimestampNanos" json:"seen_timestamp_nanos,omitempty"`
+	// errors contains the verification checks that failed, if any.
+	Errors []string `protobuf:"bytes,3,rep,name=errors" json:"errors,omitempty"`
+	// response contains the original response from the mutations API.
+	Response *keytransparency_v1_types.GetMutationsResponse `protobuf:"bytes,4,opt,name=response" json:"response,omitempty"`
+}
+
+func (m *MonitoringRecord) Reset()                    { *m = MonitoringRecord{} }
+func (m *MonitoringRecord) String() string            { return proto.CompactTextString(m) }
+func (*MonitoringRecord) ProtoMessage()               {}
+func (*MonitoringRecord) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *MonitoringRecord) GetSmr() *trillian.SignedMapRoot {
+	if m != nil {
+		return m.Smr
+	}
+	return nil
+}
+
+func (m *MonitoringRecord) GetSeenTimestampNanos() int64 {
+	if m != nil {
+		return m.SeenTimestampNanos
+	}
+	return 0
+}
+
+func (m *MonitoringRecord) GetErrors() []string {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+func (m *MonitoringRecord) GetResponse() *keytransparency_v1_types.GetMutationsResponse {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*GetMonitoringRequest)(nil), "monitor.v1.types.GetMonitoringRequest")
 	proto.RegisterType((*GetMonitoringResponse)(nil), "monitor.v1.types.GetMonitoringResponse")
+	proto.RegisterType((*MonitoringRecord)(nil), "monitor.v1.types.MonitoringRecord")
 }
 
 func init() { proto.RegisterFile("monitor_v1_types.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 301 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x90, 0xc1, 0x6b, 0xc2, 0x30,
-	0x18, 0xc5, 0xe9, 0x3a, 0x05, 0x33, 0x18, 0x23, 0xa8, 0x2b, 0x9e, 0xc4, 0x93, 0xbb, 0xa4, 0x73,
-	0xfb, 0x13, 0x36, 0xd8, 0x45, 0x77, 0xc8, 0xe6, 0x39, 0xc4, 0xfa, 0x51, 0x83, 0x36, 0x5f, 0x96,
-	0x7c, 0x0a, 0xfe, 0xad, 0xfb, 0x67, 0x46, 0x53, 0xed, 0xa1, 0xb0, 0x5b, 0xdf, 0xf7, 0x6b, 0xde,
-	0x7b, 0x3c, 0x36, 0xae, 0xd0, 0x1a, 0x42, 0xaf, 0x4e, 0x0b, 0x45, 0x67, 0x07, 0x41, 0x38, 0x8f,
-	0x84, 0xfc, 0xe1, 0x72, 0x17, 0xa7, 0x85, 0x88, 0xf7, 0xc9, 0xb6, 0x34, 0xb4, 0x3b, 0x6e, 0x44,
-	0x81, 0x55, 0x5e, 0x22, 0x96, 0x07, 0xc8, 0xf7, 0x70, 0x26, 0xaf, 0x6d, 0x70, 0xda, 0x83, 0x2d,
-	0xce, 0x79, 0x81, 0x1e, 0xf2, 0xf8, 0xbe, 0x8b, 0x5a, 0xfb, 0x7f, 0x41, 0x93, 0x3b, 0xb9, 0x27,
-	0x6f, 0x0e, 0x07, 0xa3, 0x6d, 0xa3, 0x67, 0x6f, 0x6c, 0xf8, 0x01, 0xb4, 0x6a, 0xca, 0x18, 0x5b,
-	0x4a, 0xf8, 0x39, 0x42, 0x20, 0x3e, 0x64, 0x3d, 0x70, 0x58, 0xec, 0xb2, 0x64, 0x9a, 0xcc, 0x53,
-	0xd9, 0x08, 0x3e, 0x62, 0xfd, 0x3d, 0xa9, 0xb5, 0x5c, 0x66, 0x37, 0xd3, 0x64, 0x3e, 0x90, 0xbd,
-	0x3d, 0xad, 0xe5, 0x72, 0xf6, 0x9b, 0xb0, 0x51, 0xc7, 0x25, 0x38, 0xb4, 0x01, 0xf8, 0x13, 0x4b,
-	0x43, 0xe5, 0xa3, 0xc9, 0xdd, 0xcb, 0xa3, 0x68, 0xc3, 0xbf, 0x4c, 0x69, 0x61, 0xbb, 0xd2, 0x4e,
-	0x22, 0x92, 0xac, 0xff, 0xe1, 0xcf, 0x6c, 0x18, 0x00, 0xac, 0x22, 0x53, 0x41, 0x20, 0x5d, 0x39,
-	0x65, 0xb5, 0xc5, 0x10, 0x93, 0x52, 0xc9, 0x6b, 0xf6, 0x7d, 0x45, 0x9f, 0x35, 0xe1, 0x63, 0xd6,
-	0x07, 0xef, 0xd1, 0x87, 0x2c, 0x9d, 0xa6, 0xf3, 0x81, 0xbc, 0x28, 0xbe, 0x62, 0x2c, 0x7e, 0xa9,
-	0xad, 0x26, 0x9d, 0xdd, 0xc6, 0x6c, 0x21, 0x3a, 0xc3, 0xb4, 0xc3, 0x8b, 0xba, 0xf9, 0x91, 0x34,
-	0x19, 0xb4, 0xe1, 0x5a, 0x5c, 0x0e, 0xa2, 0xc3, 0xbb, 0x26, 0xbd, 0xe9, 0xc7, 0xa5, 0x5e, 0xff,
-	0x02, 0x00, 0x00, 0xff, 0xff, 0x1c, 0xdc, 0x29, 0x97, 0xcb, 0x01, 0x00, 0x00,
+	// 327 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x51, 0xcd, 0x6a, 0xea, 0x40,
+	0x14, 0x26, 0x37, 0x57, 0xb9, 0xce, 0x85, 0x8b, 0x0c, 0xea, 0x0d, 0xae, 0xc4, 0x95, 0xdd, 0x4c,
+	0x6a, 0xfb, 0x08, 0x2d, 0x14, 0x8a, 0x76, 0x31, 0xad, 0xeb, 0x30, 0x26, 0x87, 0x38, 0x68, 0xe6,
+	0x4c, 0x67, 0x8e, 0x82, 0xcf, 0xd9, 0x65, 0x5f, 0xa6, 0x64, 0xa2, 0xa1, 0x08, 0xdd, 0x74, 0xd3,
+	0x5d, 0xbe, 0xf3, 0xe5, 0x7c, 0x3f, 0x67, 0xd8, 0xa8, 0x42, 0xa3, 0x09, 0x5d, 0x76, 0x98, 0x67,
+	0x74, 0xb4, 0xe0, 0x85, 0x75, 0x48, 0xc8, 0xfb, 0xa7, 0xb9, 0x38, 0xcc, 0x45, 0x98, 0x8f, 0x8b,
+	0x52, 0xd3, 0x66, 0xbf, 0x16, 0x39, 0x56, 0x69, 0x89, 0x58, 0xee, 0x20, 0xdd, 0xc2, 0x91, 0x9c,
+	0x32, 0xde, 0x2a, 0x07, 0x26, 0x3f, 0xa6, 0x39, 0x3a, 0x48, 0xc3, 0xfe, 0x25, 0xd5, 0xca, 0x7f,
+	0x49, 0x34, 0xbe, 0xe3, 0x7f, 0xe4, 0xf4, 0x6e, 0xa7, 0x95, 0x69, 0xf0, 0xf4, 0x8e, 0x0d, 0x1e,
+	0x80, 0x96, 0x4d, 0x18, 0x6d, 0x4a, 0x09, 0xaf, 0x7b, 0xf0, 0xc4, 0x07, 0xac, 0x03, 0x16, 0xf3,
+	0x4d, 0x12, 0x4d, 0xa2, 0x59, 0x2c, 0x1b, 0xc0, 0x87, 0xac, 0xbb, 0xa5, 0x6c, 0x25, 0x17, 0xc9,
+	0xaf, 0x49, 0x34, 0xeb, 0xc9, 0xce, 0x96, 0x56, 0x72, 0x31, 0x7d, 0x8f, 0xd8, 0xf0, 0x42, 0xc5,
+	0x5b, 0x34, 0x1e, 0xf8, 0x15, 0x8b, 0x7d, 0xe5, 0x82, 0xc8, 0xdf, 0x9b, 0xff, 0xa2, 0x35, 0x7f,
+	0xd6, 0xa5, 0x81, 0x62, 0xa9, 0xac, 0x44, 0x24, 0x59, 0xff, 0xc3, 0xaf, 0xd9, 0xc0, 0x03, 0x98,
+	0x8c, 0x74, 0x05, 0x9e, 0x54, 0x65, 0x33, 0xa3, 0x0c, 0xfa, 0xe0, 0x14, 0x4b, 0x5e, 0x73, 0x2f,
+	0x67, 0xea, 0xa9, 0x66, 0xf8, 0x88, 0x75, 0xc1, 0x39, 0x74, 0x3e, 0x89, 0x27, 0xf1, 0xac, 0x27,
+	0x4f, 0x88, 0x2f, 0x19, 0x0b, 0x5f, 0x59, 0xa1, 0x48, 0x25, 0xbf, 0x83, 0xb7, 0x10, 0x17, 0x87,
+	0x69, 0x0f, 0x2f, 0xea, 0xe4, 0x7b, 0x52, 0xa4, 0xd1, 0xf8, 0x73, 0x70, 0xd9, 0x0b, 0x0a, 0xf7,
+	0x8a, 0xd4, 0xf4, 0x2d, 0x62, 0xfd, 0xcf, 0xd5, 0x72, 0x74, 0xc5, 0xcf, 0x14, 0x7b, 0x64, 0x7f,
+	0xdc, 0x29, 0xe0, 0x37, 0x6b, 0xb5, 0xfb, 0xeb, 0x6e, 0x78, 0xff, 0xdb, 0x8f, 0x01, 0x00, 0x1d,
+	0x7f, 0x4a, 0x26, 0xa1, 0x02, 0x00, 0x00,
 }
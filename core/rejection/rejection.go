@@ -0,0 +1,148 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rejection describes why the key server refused an update. The
+// reason is carried in the details of the gRPC status as a
+// google.rpc.PreconditionFailure violation, whose type is a stable Reason.
+package rejection
+
+import (
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reason is a stable identifier of the cause of a rejection.
+type Reason string
+
+// Reasons for refusing an update.
+const (
+	// MissingAppID is returned when the request has no app ID.
+	MissingAppID Reason = "MISSING_APP_ID"
+	// WrongIndex is returned when the index of the update does not match
+	// the VRF output for the user and app.
+	WrongIndex Reason = "WRONG_INDEX"
+	// MissingCommitment is returned when the committed profile is missing.
+	MissingCommitment Reason = "MISSING_COMMITMENT"
+	// CommitmentKeyTooShort is returned when the commitment key is too
+	// short.
+	CommitmentKeyTooShort Reason = "COMMITMENT_KEY_TOO_SHORT"
+	// InvalidCommitment is returned when the commitment does not match the
+	// committed profile.
+	InvalidCommitment Reason = "INVALID_COMMITMENT"
+	// UnknownApp is returned when the server does not accept profiles for
+	// the app.
+	UnknownApp Reason = "UNKNOWN_APP"
+	// InvalidProfile is returned when the profile is rejected by the
+	// validator of the app. The subject is the app ID.
+	InvalidProfile Reason = "INVALID_PROFILE"
+	// PreviousHash is returned when the entry changed since the update was
+	// created.
+	PreviousHash Reason = "PREVIOUS_HASH_MISMATCH"
+	// MissingKey is returned when the update does not contain authorized
+	// keys.
+	MissingKey Reason = "MISSING_AUTHORIZED_KEY"
+	// InvalidSignature is returned when a signature of the update is
+	// invalid.
+	InvalidSignature Reason = "INVALID_SIGNATURE"
+	// Unauthorized is returned when the update is not signed by an
+	// authorized key of the entry.
+	Unauthorized Reason = "UNAUTHORIZED_KEY"
+	// TooLarge is returned when the update exceeds the maximum size.
+	TooLarge Reason = "TOO_LARGE"
+	// Malformed is returned when the request cannot be parsed.
+	Malformed Reason = "MALFORMED_REQUEST"
+)
+
+// hints explain to users how to resolve a rejection.
+var hints = map[Reason]string{
+	MissingAppID:          "specify the app of the profile",
+	WrongIndex:            "the client and server disagree on the VRF key; check the client configuration",
+	MissingCommitment:     "the client did not send the committed profile",
+	CommitmentKeyTooShort: "the client used a too short commitment key",
+	InvalidCommitment:     "the committed profile does not match its commitment",
+	UnknownApp:            "the server does not accept profiles for this app",
+	InvalidProfile:        "the profile data is not valid for this app",
+	PreviousHash:          "the entry was changed concurrently; fetch the entry and retry",
+	MissingKey:            "the entry must keep at least one authorized key",
+	InvalidSignature:      "the update is not correctly signed",
+	Unauthorized:          "the update is not signed by a key authorized to change the entry; check the local keystore",
+	TooLarge:              "the profile is too large",
+	Malformed:             "the request is malformed",
+}
+
+// Rejection is a refused update.
+type Rejection struct {
+	// Code is the gRPC status code of the rejection.
+	Code codes.Code
+	// Reason identifies the cause of the rejection.
+	Reason Reason
+	// Subject is the object the rejection applies to, e.g. an app ID.
+	Subject string
+	// Description is a human readable description of the cause.
+	Description string
+}
+
+// Error returns a description of the rejection for users.
+func (r *Rejection) Error() string {
+	msg := r.Description
+	if r.Subject != "" {
+		msg = fmt.Sprintf("%v: %v", r.Subject, msg)
+	}
+	if hint, ok := hints[r.Reason]; ok {
+		msg = fmt.Sprintf("%v (%v)", msg, hint)
+	}
+	return fmt.Sprintf("update refused: %v [%v]", msg, r.Reason)
+}
+
+// Error returns a gRPC error with code and a status detail carrying reason,
+// subject and description.
+func Error(code codes.Code, reason Reason, subject, description string) error {
+	s := status.New(code, description)
+	detailed, err := s.WithDetails(&errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{{
+			Type:        string(reason),
+			Subject:     subject,
+			Description: description,
+		}},
+	})
+	if err != nil {
+		return s.Err()
+	}
+	return detailed.Err()
+}
+
+// FromError returns the rejection carried by the gRPC error err, if any.
+func FromError(err error) (*Rejection, bool) {
+	s, ok := status.FromError(err)
+	if !ok || s == nil {
+		return nil, false
+	}
+	for _, d := range s.Details() {
+		pf, ok := d.(*errdetails.PreconditionFailure)
+		if !ok || len(pf.Violations) == 0 {
+			continue
+		}
+		v := pf.Violations[0]
+		return &Rejection{
+			Code:        s.Code(),
+			Reason:      Reason(v.Type),
+			Subject:     v.Subject,
+			Description: v.Description,
+		}, true
+	}
+	return nil, false
+}
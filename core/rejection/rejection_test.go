@@ -0,0 +1,61 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rejection
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestFromError(t *testing.T) {
+	err := Error(codes.InvalidArgument, InvalidProfile, "pgp", "pgp: unsupported algorithm")
+	if got, want := grpc.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("grpc.Code(): %v, want %v", got, want)
+	}
+	r, ok := FromError(err)
+	if !ok {
+		t.Fatalf("FromError(%v): no rejection", err)
+	}
+	want := &Rejection{
+		Code:        codes.InvalidArgument,
+		Reason:      InvalidProfile,
+		Subject:     "pgp",
+		Description: "pgp: unsupported algorithm",
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("FromError(): %+v, want %+v", r, want)
+	}
+	for _, s := range []string{"pgp: unsupported algorithm", string(InvalidProfile), hints[InvalidProfile]} {
+		if !strings.Contains(r.Error(), s) {
+			t.Errorf("Error(): %q does not contain %q", r.Error(), s)
+		}
+	}
+}
+
+func TestFromErrorWithoutDetails(t *testing.T) {
+	for _, err := range []error{
+		errors.New("not a status"),
+		grpc.Errorf(codes.Internal, "no details"),
+	} {
+		if r, ok := FromError(err); ok {
+			t.Errorf("FromError(%v): %v, want no rejection", err, r)
+		}
+	}
+}
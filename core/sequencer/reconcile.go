@@ -0,0 +1,179 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sequencer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrOutOfSync occurs when the log lacks the SignedMapRoots of some map
+	// revisions. No epochs are created until the log has caught up with
+	// the map.
+	ErrOutOfSync = errors.New("log and map out of sync")
+	// ErrLogAhead occurs when the log contains more SignedMapRoots than
+	// there are map revisions, e.g. because the map has been restored from
+	// an old backup. No epochs are created until an operator intervenes.
+	ErrLogAhead = errors.New("log ahead of map")
+)
+
+// reconcileGrace is how long the log may lack SignedMapRoots before they are
+// queued again. Roots which have just been queued are usually still waiting
+// to be sequenced by the log.
+const reconcileGrace = 30 * time.Second
+
+var (
+	logGapGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kt_signer_log_gap",
+		Help: "Number of map revisions whose SignedMapRoot is missing from the log. Negative if the log contains more roots than there are map revisions.",
+	})
+	requeuedCtr = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kt_signer_roots_requeued",
+		Help: "Number of SignedMapRoots the signer has queued again because they were missing from the log.",
+	})
+	refusedCtr = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kt_signer_epochs_refused",
+		Help: "Number of epochs not created because the log and the map were out of sync.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(logGapGauge)
+	prometheus.MustRegister(requeuedCtr)
+	prometheus.MustRegister(refusedCtr)
+}
+
+// logSize returns the number of leaves sequenced by the log.
+func (s *Sequencer) logSize(ctx context.Context) (int64, error) {
+	logRoot, err := s.tlog.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{
+		LogId: s.logID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("GetLatestSignedLogRoot(%v): %v", s.logID, err)
+	}
+	return logRoot.GetSignedLogRoot().GetTreeSize(), nil
+}
+
+// Reconcile compares the size of the log with the latest map revision. As the
+// SignedMapRoot of map revision r is stored at log index r, the log has one
+// more leaf than the latest map revision. The roots of map revisions missing
+// from the log for longer than a grace period, e.g. because queueing them
+// failed after the map had been updated, are queued again in revision order.
+//
+// ErrOutOfSync is returned if the log still lacks roots afterwards, which is
+// expected until the log has sequenced the queued roots. ErrLogAhead is
+// returned if the log contains more roots than there are map revisions, which
+// needs an operator.
+func (s *Sequencer) Reconcile(ctx context.Context) error {
+	return s.reconcile(ctx, s.gracePeriod)
+}
+
+// reconcile reconciles the log with the map, queueing missing roots again if
+// they have been missing for at least grace.
+func (s *Sequencer) reconcile(ctx context.Context, grace time.Duration) error {
+	logSize, err := s.logSize(ctx)
+	if err != nil {
+		return err
+	}
+	rootResp, err := s.tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{
+		MapId: s.mapID,
+	})
+	if err != nil {
+		return fmt.Errorf("GetSignedMapRoot(%v): %v", s.mapID, err)
+	}
+	revision := rootResp.GetMapRoot().GetMapRevision()
+	gap := revision + 1 - logSize
+	logGapGauge.Set(float64(gap))
+	switch {
+	case gap == 0:
+		s.setLogGapSince(time.Time{})
+		return nil
+	case gap < 0:
+		glog.Errorf("ALERT: log %v contains %v SignedMapRoots, but map %v only has revisions 0 to %v",
+			s.logID, logSize, s.mapID, revision)
+		return ErrLogAhead
+	}
+
+	// Give the log time to sequence roots which have just been queued.
+	now := s.clock.Now()
+	since := s.logGapSince(now)
+	if now.Sub(since) < grace {
+		glog.V(2).Infof("Reconcile: log %v is sequencing the SignedMapRoots of map revisions %v to %v",
+			s.logID, logSize, revision)
+		return ErrOutOfSync
+	}
+
+	glog.Warningf("Reconcile: log %v lacks the SignedMapRoots of map revisions %v to %v since %v, queueing them",
+		s.logID, logSize, revision, since)
+	for r := logSize; r <= revision; r++ {
+		smr := rootResp.GetMapRoot()
+		if r != revision {
+			resp, err := s.tmap.GetSignedMapRootByRevision(ctx, &trillian.GetSignedMapRootByRevisionRequest{
+				MapId:    s.mapID,
+				Revision: r,
+			})
+			if err != nil {
+				return fmt.Errorf("GetSignedMapRootByRevision(%v, %v): %v", s.mapID, r, err)
+			}
+			smr = resp.GetMapRoot()
+		}
+		if err := queueLogLeaf(ctx, s.tlog, s.logID, smr); err != nil {
+			return err
+		}
+		requeuedCtr.Inc()
+	}
+	// Queue the roots again only if the log still lacks them after
+	// another grace period.
+	s.setLogGapSince(now)
+
+	// The log may sequence the queued roots asynchronously.
+	if logSize, err = s.logSize(ctx); err != nil {
+		return err
+	}
+	gap = revision + 1 - logSize
+	logGapGauge.Set(float64(gap))
+	if gap != 0 {
+		return ErrOutOfSync
+	}
+	s.setLogGapSince(time.Time{})
+	glog.Infof("Reconcile: log %v caught up with map revision %v", s.logID, revision)
+	return nil
+}
+
+// logGapSince returns the time since which the log lacks roots, which is now
+// if the log was in sync with the map before.
+func (s *Sequencer) logGapSince(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.gapSince.IsZero() {
+		s.gapSince = now
+	}
+	return s.gapSince
+}
+
+// setLogGapSince sets the time since which the log lacks roots. The zero time
+// indicates that the log is in sync with the map.
+func (s *Sequencer) setLogGapSince(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gapSince = t
+}
@@ -0,0 +1,219 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sequencer
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/fake"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	mapID = 2
+	logID = 3
+)
+
+// setRevisions creates n new map revisions.
+func setRevisions(t *testing.T, tmap trillian.TrillianMapClient, n int) {
+	for i := 0; i < n; i++ {
+		if _, err := tmap.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{
+			MapId: mapID,
+		}); err != nil {
+			t.Fatalf("SetLeaves(): %v", err)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc      string
+		revisions int   // Map revisions after revision 0.
+		logged    int64 // Map revisions whose root is in the log.
+		wantGrace error // Reconcile within the grace period.
+		want      error // Reconcile after the grace period.
+	}{
+		{"empty", 0, 0, ErrOutOfSync, nil},
+		{"in sync", 2, 3, nil, nil},
+		{"missing roots", 3, 1, ErrOutOfSync, nil},
+		{"empty log", 2, 0, ErrOutOfSync, nil},
+		{"log ahead", 1, 3, ErrLogAhead, ErrLogAhead},
+	} {
+		tmap := fake.NewFakeTrillianMapClient(mapID)
+		tlog := fake.NewFakeTrillianLogClient()
+		clock := util.NewFakeTimeSource(fakeNow)
+		s := New(mapID, tmap, logID, tlog, nil, nil, nil, nil, maxMutations)
+		s.clock = clock
+		setRevisions(t, tmap, tc.revisions)
+		for r := int64(0); r < tc.logged; r++ {
+			smr := &trillian.SignedMapRoot{MapId: mapID, MapRevision: r}
+			if r <= int64(tc.revisions) {
+				resp, err := tmap.GetSignedMapRootByRevision(ctx, &trillian.GetSignedMapRootByRevisionRequest{
+					MapId:    mapID,
+					Revision: r,
+				})
+				if err != nil {
+					t.Fatalf("GetSignedMapRootByRevision(%v): %v", r, err)
+				}
+				smr = resp.GetMapRoot()
+			}
+			if err := queueLogLeaf(ctx, tlog, logID, smr); err != nil {
+				t.Fatalf("queueLogLeaf(%v): %v", r, err)
+			}
+		}
+
+		// Missing roots are only queued again once they have been
+		// missing for the grace period.
+		if err := s.Reconcile(ctx); err != tc.wantGrace {
+			t.Errorf("%v: Reconcile(): %v, want %v", tc.desc, err, tc.wantGrace)
+		}
+		clock.Set(fakeNow.Add(reconcileGrace))
+		if err := s.Reconcile(ctx); err != tc.want {
+			t.Errorf("%v: Reconcile() after grace period: %v, want %v", tc.desc, err, tc.want)
+		}
+		if tc.want != nil {
+			if err := s.CreateEpoch(ctx, true); err != tc.want {
+				t.Errorf("%v: CreateEpoch(): %v, want %v", tc.desc, err, tc.want)
+			}
+			continue
+		}
+
+		// The root of every map revision is stored at its log index.
+		for r := int64(0); r <= int64(tc.revisions); r++ {
+			resp, err := tmap.GetSignedMapRootByRevision(ctx, &trillian.GetSignedMapRootByRevisionRequest{
+				MapId:    mapID,
+				Revision: r,
+			})
+			if err != nil {
+				t.Fatalf("GetSignedMapRootByRevision(%v): %v", r, err)
+			}
+			want, err := json.Marshal(resp.GetMapRoot())
+			if err != nil {
+				t.Fatalf("json.Marshal(): %v", err)
+			}
+			leaves, err := tlog.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{
+				LogId:     logID,
+				LeafIndex: []int64{r},
+			})
+			if err != nil {
+				t.Fatalf("%v: GetLeavesByIndex(%v): %v", tc.desc, r, err)
+			}
+			if got := leaves.GetLeaves()[0].GetLeafValue(); !bytes.Equal(got, want) {
+				t.Errorf("%v: log leaf %v: %s, want %s", tc.desc, r, got, want)
+			}
+		}
+		count, err := tlog.GetSequencedLeafCount(ctx, &trillian.GetSequencedLeafCountRequest{LogId: logID})
+		if err != nil {
+			t.Fatalf("GetSequencedLeafCount(): %v", err)
+		}
+		if got, want := count.GetLeafCount(), int64(tc.revisions+1); got != want {
+			t.Errorf("%v: log size %v, want %v", tc.desc, got, want)
+		}
+	}
+}
+
+// delayedLog sequences queued leaves only after delay, like a Trillian log
+// with a sequencing interval.
+type delayedLog struct {
+	*fake.TrillianLog
+	delay   time.Duration
+	mu      sync.Mutex
+	pending []*trillian.QueueLeafRequest
+	queued  []time.Time
+}
+
+func (l *delayedLog) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending = append(l.pending, in)
+	l.queued = append(l.queued, time.Now())
+	return &trillian.QueueLeafResponse{}, nil
+}
+
+func (l *delayedLog) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	l.mu.Lock()
+	for len(l.pending) > 0 && time.Since(l.queued[0]) >= l.delay {
+		if _, err := l.TrillianLog.QueueLeaf(ctx, l.pending[0]); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		l.pending, l.queued = l.pending[1:], l.queued[1:]
+	}
+	l.mu.Unlock()
+	return l.TrillianLog.GetLatestSignedLogRoot(ctx, in)
+}
+
+func TestReconcilePendingRoot(t *testing.T) {
+	ctx := context.Background()
+	tmap := fake.NewFakeTrillianMapClient(mapID)
+	tlog := &delayedLog{TrillianLog: fake.NewFakeTrillianLogClient(), delay: time.Hour}
+	clock := util.NewFakeTimeSource(fakeNow)
+	s := New(mapID, tmap, logID, tlog, nil, nil, nil, nil, maxMutations)
+	s.clock = clock
+	tlog.delay = 0
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize(): %v", err)
+	}
+
+	// The root of revision 1 has been queued, but the log has not
+	// sequenced it yet.
+	tlog.delay = time.Hour
+	setRevisions(t, tmap, 1)
+	resp, err := tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	if err := queueLogLeaf(ctx, tlog, logID, resp.GetMapRoot()); err != nil {
+		t.Fatalf("queueLogLeaf(): %v", err)
+	}
+	for _, step := range []struct {
+		desc    string
+		elapsed time.Duration
+		delay   time.Duration
+		want    error
+		queued  int // Roots waiting to be sequenced after Reconcile.
+	}{
+		{"pending", 0, time.Hour, ErrOutOfSync, 1},
+		{"pending within grace period", reconcileGrace - time.Second, time.Hour, ErrOutOfSync, 1},
+		{"requeued after grace period", reconcileGrace, time.Hour, ErrOutOfSync, 2},
+		{"not requeued again within grace period", 2*reconcileGrace - time.Second, time.Hour, ErrOutOfSync, 2},
+		{"sequenced", 2*reconcileGrace - time.Second, 0, nil, 0},
+	} {
+		clock.Set(fakeNow.Add(step.elapsed))
+		tlog.delay = step.delay
+		if err := s.Reconcile(ctx); err != step.want {
+			t.Errorf("%v: Reconcile(): %v, want %v", step.desc, err, step.want)
+		}
+		if got := len(tlog.pending); got != step.queued {
+			t.Errorf("%v: %v roots queued, want %v", step.desc, got, step.queued)
+		}
+	}
+	count, err := tlog.GetSequencedLeafCount(ctx, &trillian.GetSequencedLeafCountRequest{LogId: logID})
+	if err != nil {
+		t.Fatalf("GetSequencedLeafCount(): %v", err)
+	}
+	if got, want := count.GetLeafCount(), int64(2); got != want {
+		t.Errorf("log size %v, want %v", got, want)
+	}
+}
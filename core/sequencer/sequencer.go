@@ -19,13 +19,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sync"
 	"time"
-	"net/http"
-	"strings"
 
 	"github.com/google/keytransparency/core/mutator"
 	"github.com/google/keytransparency/core/mutator/entry"
 	"github.com/google/keytransparency/core/transaction"
+	"github.com/google/keytransparency/core/witness"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
@@ -55,13 +55,38 @@ var (
 		Help:    "Seconds spent generating epoch",
 		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, math.Inf(1)},
 	})
+	witnessFailedCtr = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kt_signer_witness_publish_failed",
+		Help: "Number of map roots the signer failed to publish to the witness.",
+	})
+	unwitnessedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kt_signer_witness_pending",
+		Help: "Number of map roots waiting to be published to the witness.",
+	})
+)
+
+// getLeavesBatchSize is the maximum number of map leaves read with a single
+// GetLeaves request.
+const getLeavesBatchSize = 1000
+
+// logPollMin and logPollMax bound the interval between polls of the log while
+// waiting for it to sequence the SignedMapRoot of the latest map revision.
+const (
+	logPollMin = 10 * time.Millisecond
+	logPollMax = time.Second
 )
 
+// subscriberBuffer is the number of epochs buffered for each subscriber before
+// the oldest buffered epochs are dropped for that subscriber.
+const subscriberBuffer = 16
+
 func init() {
 	prometheus.MustRegister(mutationsCtr)
 	prometheus.MustRegister(indexCtr)
 	prometheus.MustRegister(mapUpdateHist)
 	prometheus.MustRegister(createEpochHist)
+	prometheus.MustRegister(witnessFailedCtr)
+	prometheus.MustRegister(unwitnessedGauge)
 }
 
 // Sequencer processes mutations and sends them to the trillian map.
@@ -73,58 +98,151 @@ type Sequencer struct {
 	mutator   mutator.Mutator
 	mutations mutator.Mutation
 	factory   transaction.Factory
+	witness   witness.Witness
+	// maxMutations is the maximum number of mutations in one epoch.
+	maxMutations int32
+	batchSize    int
+	clock        util.TimeSource
+	// gracePeriod is how long the log may lack roots before Reconcile
+	// queues them again.
+	gracePeriod time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan int64]bool
+	// published is the latest revision sent to subscribers.
+	published int64
+	// gapSince is the time since which the log lacks roots, if it does.
+	gapSince time.Time
+	// pending holds the roots which have yet to be published to the
+	// witness.
+	pending witness.Pending
+	// witnessc wakes up runWitness after an epoch has been created.
+	witnessc chan struct{}
 }
 
-// New creates a new instance of the signer.
+// New creates a new instance of the signer. Each epoch includes at most
+// maxMutations mutations. Larger backlogs are processed in several
+// consecutive epochs.
 func New(mapID int64,
 	tmap trillian.TrillianMapClient,
 	logID int64,
 	tlog trillian.TrillianLogClient,
 	mutator mutator.Mutator,
 	mutations mutator.Mutation,
-	factory transaction.Factory) *Sequencer {
+	factory transaction.Factory,
+	witness witness.Witness,
+	maxMutations int32) *Sequencer {
 	return &Sequencer{
-		mapID:     mapID,
-		tmap:      tmap,
-		logID:     logID,
-		tlog:      tlog,
-		mutator:   mutator,
-		mutations: mutations,
-		factory:   factory,
+		mapID:        mapID,
+		tmap:         tmap,
+		logID:        logID,
+		tlog:         tlog,
+		mutator:      mutator,
+		mutations:    mutations,
+		factory:      factory,
+		witness:      witness,
+		maxMutations: maxMutations,
+		batchSize:    getLeavesBatchSize,
+		clock:        util.SystemTimeSource{},
+		gracePeriod:  reconcileGrace,
+		subscribers:  make(map[chan int64]bool),
+		pending:      newMemPending(),
+		witnessc:     make(chan struct{}, 1),
 	}
 }
 
-// Initialize inserts the object hash of an empty struct into the log if it is empty.
-// This keeps the log leaves in-sync with the map which starts off with an
-// empty log root at map revision 0.
-func (s *Sequencer) Initialize(ctx context.Context) error {
-	logRoot, err := s.tlog.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{
-		LogId: s.logID,
-	})
-	if err != nil {
-		return fmt.Errorf("GetLatestSignedLogRoot(%v): %v", s.logID, err)
+// Subscribe registers a subscriber for newly created epochs. The returned
+// channel receives the map revision of every epoch created after the call.
+// The oldest revisions are dropped if the subscriber does not keep up, so
+// subscribers should treat a received revision as "all epochs up to this
+// revision are available". The latest revision is never dropped. The returned
+// function cancels the subscription and closes the channel.
+func (s *Sequencer) Subscribe() (<-chan int64, func()) {
+	ch := make(chan int64, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = true
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.subscribers[ch] {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
 	}
-	mapRoot, err := s.tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{
-		MapId: s.mapID,
-	})
-	if err != nil {
-		return fmt.Errorf("GetSignedMapRoot(%v): %v", s.mapID, err)
+}
+
+// publish notifies all subscribers about a newly created epoch without
+// blocking on slow subscribers. The buffer of a slow subscriber makes room for
+// revision by dropping its oldest revision. Revisions which are not newer than
+// the latest published revision are ignored.
+func (s *Sequencer) publish(revision int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if revision <= s.published {
+		return
 	}
+	s.published = revision
+	for ch := range s.subscribers {
+		select {
+		case ch <- revision:
+			continue
+		default:
+		}
+		// Only publish sends on ch, so there is room for revision
+		// once either publish or the subscriber received from ch.
+		select {
+		case dropped := <-ch:
+			glog.Warningf("Subscriber is not keeping up, dropping epoch %v", dropped)
+		default:
+		}
+		select {
+		case ch <- revision:
+		default:
+		}
+	}
+}
 
-	// If the tree is empty and the map is empty,
-	// add the empty map root to the log.
-	if logRoot.GetSignedLogRoot().GetTreeSize() == 0 &&
-		mapRoot.GetMapRoot().GetMapRevision() == 0 {
-		glog.Infof("Initializing Trillian Log with empty map root")
-		if err := queueLogLeaf(ctx, s.tlog, s.logID, mapRoot.GetMapRoot()); err != nil {
-			return err
+// Follow polls the map once per period and notifies subscribers about epochs
+// created by other replicas, until ctx is done. This lets every replica serve
+// newly created epochs, not only the master.
+func (s *Sequencer) Follow(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		rootResp, err := s.tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{
+			MapId: s.mapID,
+		})
+		if err != nil {
+			glog.Warningf("Follow: GetSignedMapRoot(%v): %v", s.mapID, err)
+		} else {
+			s.publish(rootResp.GetMapRoot().GetMapRevision())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 	}
+}
+
+// Initialize queues the SignedMapRoots of the map revisions missing from the
+// log, starting with the empty map root of revision 0 if the log is empty.
+// This keeps the log leaves in-sync with the map. Unlike Reconcile, roots are
+// queued without a grace period. Roots which have been queued but not yet
+// sequenced by the log are not an error.
+func (s *Sequencer) Initialize(ctx context.Context) error {
+	if err := s.reconcile(ctx, 0); err != nil && err != ErrOutOfSync {
+		return err
+	}
 	return nil
 }
 
 // StartSigning advance epochs once per minInterval, if there were mutations,
-// and at least once per maxElapsed minIntervals.
+// and at least once per maxElapsed minIntervals. A backlog of more than
+// maxMutations mutations is drained with consecutive epochs without waiting
+// for the next tick. It returns when ctx is done, e.g. when this replica is no
+// longer the master.
 func (s *Sequencer) StartSigning(ctx context.Context, minInterval, maxInterval time.Duration) {
 	if err := s.Initialize(ctx); err != nil {
 		glog.Errorf("Initialize() failed: %v", err)
@@ -149,24 +267,88 @@ func (s *Sequencer) StartSigning(ctx context.Context, minInterval, maxInterval t
 		}
 	}
 	cancel()
+	if s.witness != nil {
+		go s.runWitness(ctx)
+	}
 	// Fetch last time from previous map head (as stored in the map server)
 	mapRoot := rootResp.GetMapRoot()
 	last := time.Unix(0, mapRoot.GetTimestampNanos())
 	// Start issuing epochs:
-	clock := util.SystemTimeSource{}
-	tc := time.NewTicker(minInterval).C
-	for f := range genEpochTicks(clock, last, tc, minInterval, maxInterval) {
-		ctxTime, cancel := context.WithTimeout(ctx, minInterval)
-		if err := s.CreateEpoch(ctxTime, f); err != nil {
-			glog.Errorf("CreateEpoch failed: %v", err)
+	ticker := time.NewTicker(minInterval)
+	defer ticker.Stop()
+	for f := range genEpochTicks(s.clock, last, untilDone(ctx, ticker.C), minInterval, maxInterval) {
+		if ctx.Err() != nil {
+			continue // Drain pending ticks.
 		}
+		s.createEpochs(ctx, f, minInterval)
+	}
+}
+
+// createEpochs creates epochs until there are no pending mutations left, or
+// until an epoch cannot be created. Each epoch must be created within timeout.
+// Before the next epoch of a backlog is created, the log is given timeout to
+// sequence the SignedMapRoot of the previous epoch.
+func (s *Sequencer) createEpochs(ctx context.Context, forceNewEpoch bool, timeout time.Duration) {
+	for more := true; more && ctx.Err() == nil; forceNewEpoch = false {
+		ctxTime, cancel := context.WithTimeout(ctx, timeout)
+		var err error
+		more, err = s.createEpoch(ctxTime, forceNewEpoch)
 		cancel()
+		switch {
+		case err == ErrOutOfSync:
+			glog.Warningf("CreateEpoch: waiting for the log to catch up with the map")
+			return
+		case err == ErrLogAhead:
+			glog.Errorf("CreateEpoch: the log is ahead of the map, not creating epochs")
+			return
+		case err != nil:
+			glog.Errorf("CreateEpoch failed: %v", err)
+			return
+		case more:
+			glog.Infof("CreateEpoch: draining backlog of mutations")
+			ctxTime, cancel := context.WithTimeout(ctx, timeout)
+			err := s.waitForLog(ctxTime)
+			cancel()
+			if err != nil {
+				glog.Warningf("CreateEpoch: log did not catch up with the map: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// waitForLog polls the log with exponential backoff until it contains the
+// SignedMapRoot of the latest map revision, or until ctx is done.
+func (s *Sequencer) waitForLog(ctx context.Context) error {
+	for delay := logPollMin; ; delay *= 2 {
+		logSize, err := s.logSize(ctx)
+		if err != nil {
+			return err
+		}
+		rootResp, err := s.tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{
+			MapId: s.mapID,
+		})
+		if err != nil {
+			return fmt.Errorf("GetSignedMapRoot(%v): %v", s.mapID, err)
+		}
+		if logSize > rootResp.GetMapRoot().GetMapRevision() {
+			return nil
+		}
+		if delay > logPollMax {
+			delay = logPollMax
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
 	}
 }
 
 // genEpochTicks returns and sends to a bool channel every time an epoch should
 // be created. If the boolean value is true this indicates that the epoch should
-// be created regardless of whether mutations exist.
+// be created regardless of whether mutations exist. The channel is closed
+// once minTick is closed.
 func genEpochTicks(t util.TimeSource, last time.Time, minTick <-chan time.Time, minElapsed, maxElapsed time.Duration) <-chan bool {
 	enforce := make(chan bool)
 	go func() {
@@ -185,33 +367,91 @@ func genEpochTicks(t util.TimeSource, last time.Time, minTick <-chan time.Time,
 				enforce <- false
 			}
 		}
+		close(enforce)
 	}()
 
 	return enforce
 }
 
-// newMutations returns a list of mutations to process and highest sequence
-// number returned.
+// untilDone forwards ticks until ctx is done and closes the returned channel
+// then.
+func untilDone(ctx context.Context, ticks <-chan time.Time) <-chan time.Time {
+	out := make(chan time.Time)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticks:
+				select {
+				case out <- t:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// newMutations returns at most maxMutations mutations to process and the
+// highest sequence number returned, or startSequence if there are no new
+// mutations.
 func (s *Sequencer) newMutations(ctx context.Context, startSequence int64) ([]*tpb.SignedKV, int64, error) {
 	txn, err := s.factory.NewTxn(ctx)
 	if err != nil {
 		return nil, 0, fmt.Errorf("NewDBTxn(): %v", err)
 	}
 
-	maxSequence, mutations, err := s.mutations.ReadAll(txn, uint64(startSequence))
+	maxSequence, mutations, err := s.mutations.ReadRange(txn, uint64(startSequence), math.MaxInt64, s.maxMutations)
 	if err != nil {
 		if err := txn.Rollback(); err != nil {
 			glog.Errorf("Cannot rollback the transaction: %v", err)
 		}
-		return nil, 0, fmt.Errorf("ReadAll(%v): %v", startSequence, err)
+		return nil, 0, fmt.Errorf("ReadRange(%v, %v): %v", startSequence, s.maxMutations, err)
 	}
 
 	if err := txn.Commit(); err != nil {
 		return nil, 0, fmt.Errorf("txn.Commit(): %v", err)
 	}
+	if len(mutations) == 0 {
+		// Keep the sequence number of an epoch without mutations.
+		return mutations, startSequence, nil
+	}
 	return mutations, int64(maxSequence), nil
 }
 
+// getLeaves returns the values of the map leaves at indexes in revision. The
+// leaves are read with batches of at most batchSize indexes, to keep the size
+// of the requests bounded.
+func (s *Sequencer) getLeaves(ctx context.Context, indexes [][]byte, revision int64) ([]*trillian.MapLeaf, error) {
+	leaves := make([]*trillian.MapLeaf, 0, len(indexes))
+	for start := 0; start < len(indexes); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(indexes) {
+			end = len(indexes)
+		}
+		getResp, err := s.tmap.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
+			MapId:    s.mapID,
+			Index:    indexes[start:end],
+			Revision: revision,
+		})
+		if err != nil {
+			return nil, err
+		}
+		glog.V(3).Infof("CreateEpoch: len(GetLeaves.MapLeafInclusions): %v",
+			len(getResp.MapLeafInclusion))
+		// Trust the leaf values provided by the map server.
+		// If the map server is run by an untrusted entity, perform inclusion
+		// and signature verification here.
+		for _, m := range getResp.MapLeafInclusion {
+			leaves = append(leaves, m.Leaf)
+		}
+	}
+	return leaves, nil
+}
+
 // toArray returns the first 32 bytes from b.
 // If b is less than 32 bytes long, the output is zero padded.
 func toArray(b []byte) [32]byte {
@@ -239,7 +479,7 @@ func (s *Sequencer) applyMutations(mutations []*tpb.SignedKV, leaves []*trillian
 			var err error
 			oldValue, err = entry.FromLeafValue(leaf.GetLeafValue())
 			if err != nil {
-				glog.Warningf("entry.FromLeafValue(%v): %v", err)
+				glog.Warningf("entry.FromLeafValue(%x): %v", index, err)
 				continue
 			}
 		}
@@ -263,16 +503,32 @@ func (s *Sequencer) applyMutations(mutations []*tpb.SignedKV, leaves []*trillian
 	return ret, nil
 }
 
-// CreateEpoch signs the current map head.
+// CreateEpoch signs the current map head. No epoch is created while the log
+// lacks the SignedMapRoots of previous map revisions, as the root of revision
+// r has to be stored at log index r. The epoch includes at most maxMutations
+// of the pending mutations.
 func (s *Sequencer) CreateEpoch(ctx context.Context, forceNewEpoch bool) error {
+	_, err := s.createEpoch(ctx, forceNewEpoch)
+	return err
+}
+
+// createEpoch creates an epoch and returns whether the epoch was full, i.e.
+// whether more mutations may be pending.
+func (s *Sequencer) createEpoch(ctx context.Context, forceNewEpoch bool) (bool, error) {
 	glog.V(2).Infof("CreateEpoch: starting sequencing run")
 	start := time.Now()
+	if err := s.Reconcile(ctx); err != nil {
+		if err == ErrOutOfSync || err == ErrLogAhead {
+			refusedCtr.Inc()
+		}
+		return false, err
+	}
 	// Get the current root.
 	rootResp, err := s.tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{
 		MapId: s.mapID,
 	})
 	if err != nil {
-		return fmt.Errorf("GetSignedMapRoot(%v): %v", s.mapID, err)
+		return false, fmt.Errorf("GetSignedMapRoot(%v): %v", s.mapID, err)
 	}
 	startSequence := rootResp.GetMapRoot().GetMetadata().GetHighestFullyCompletedSeq()
 	revision := rootResp.GetMapRoot().GetMapRevision()
@@ -281,46 +537,36 @@ func (s *Sequencer) CreateEpoch(ctx context.Context, forceNewEpoch bool) error {
 	// Get the list of new mutations to process.
 	mutations, seq, err := s.newMutations(ctx, startSequence)
 	if err != nil {
-		return fmt.Errorf("newMutations(%v): %v", startSequence, err)
+		return false, fmt.Errorf("newMutations(%v): %v", startSequence, err)
 	}
 
 	// Don't create epoch if there is nothing to process unless explicitly
 	// specified by caller
 	if len(mutations) == 0 && !forceNewEpoch {
 		glog.Infof("CreateEpoch: No mutations found. Exiting.")
-		return nil
+		return false, nil
 	}
 
 	// Get current leaf values.
 	indexes := make([][]byte, 0, len(mutations))
+	seen := make(map[[32]byte]bool)
 	for _, m := range mutations {
-		indexes = append(indexes, m.KeyValue.Key)
+		if i := toArray(m.KeyValue.Key); !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, m.KeyValue.Key)
+		}
 	}
 	glog.V(2).Infof("CreateEpoch: len(mutations): %v, len(indexes): %v",
 		len(mutations), len(indexes))
-	getResp, err := s.tmap.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
-		MapId:    s.mapID,
-		Index:    indexes,
-		Revision: -1, // Get the latest version.
-	})
+	leaves, err := s.getLeaves(ctx, indexes, revision)
 	if err != nil {
-		return err
-	}
-	glog.V(3).Infof("CreateEpoch: len(GetLeaves.MapLeafInclusions): %v",
-		len(getResp.MapLeafInclusion))
-
-	// Trust the leaf values provided by the map server.
-	// If the map server is run by an untrusted entity, perform inclusion
-	// and signature verification here.
-	leaves := make([]*trillian.MapLeaf, 0, len(getResp.MapLeafInclusion))
-	for _, m := range getResp.MapLeafInclusion {
-		leaves = append(leaves, m.Leaf)
+		return false, err
 	}
 
 	// Apply mutations to values.
 	newLeaves, err := s.applyMutations(mutations, leaves)
 	if err != nil {
-		return err
+		return false, err
 	}
 	glog.V(2).Infof("CreateEpoch: applied %v mutations to %v leaves",
 		len(mutations), len(leaves))
@@ -336,26 +582,30 @@ func (s *Sequencer) CreateEpoch(ctx context.Context, forceNewEpoch bool) error {
 	})
 	mapSetEnd := time.Now()
 	if err != nil {
-		return err
+		return false, err
 	}
 	revision = setResp.GetMapRoot().GetMapRevision()
 	glog.V(2).Infof("CreateEpoch: SetLeaves:{Revision: %v, HighestFullyCompletedSeq: %v}", revision, seq)
 
-	// Put SignedMapHead in an append only log.
+	// Put SignedMapHead in an append only log. If this fails, the next
+	// CreateEpoch queues the root again before extending the map.
 	if err := queueLogLeaf(ctx, s.tlog, s.logID, setResp.GetMapRoot()); err != nil {
-		// TODO(gdbelvin): If the log doesn't do this, we need to generate an emergency alert.
-		return err
+		return false, err
 	}
 
-	// store smr in BFTKV
-	writeToBFTKV(string(s.mapID) + "|" + string(revision), string(setResp.GetMapRoot().GetRootHash()))
+	// Publish the root to the witness so that monitors can compare it with
+	// the roots served by the key server. The epoch has been committed at
+	// this point, so the root is published in the background and failures
+	// are retried instead of failing the epoch.
+	s.queueWitness(ctx, revision, setResp.GetMapRoot().GetRootHash())
 
 	mutationsCtr.Add(float64(len(mutations)))
 	indexCtr.Add(float64(len(indexes)))
 	mapUpdateHist.Observe(mapSetEnd.Sub(mapSetStart).Seconds())
 	createEpochHist.Observe(time.Since(start).Seconds())
 	glog.Infof("CreatedEpoch: rev: %v, root: %x", revision, setResp.GetMapRoot().GetRootHash())
-	return nil
+	s.publish(revision)
+	return int32(len(mutations)) == s.maxMutations, nil
 }
 
 // TODO(gdbelvin): Add leaf at a specific index. trillian#423
@@ -378,18 +628,3 @@ func queueLogLeaf(ctx context.Context, tlog trillian.TrillianLogClient, logID in
 	}
 	return nil
 }
-
-func writeToBFTKV(key string, value string) {
-	glog.Infoln("Writing to BFTKV...")
-	req, err := http.NewRequest("GET", "http://docker.for.mac.localhost:6001/writeonce/" + key, strings.NewReader(value))
-	if err != nil {
-		glog.Errorf("BFTKV write error: %v", err)
-	}
-	client := http.DefaultClient
-	resp, err := client.Do(req)
-	if err != nil {
-		glog.Errorf("BFTKV request error: %v", err)
-	}
-	defer resp.Body.Close()
-	glog.Info("Response: ", resp)
-}
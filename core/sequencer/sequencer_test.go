@@ -17,12 +17,27 @@
 package sequencer
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/google/keytransparency/core/fake"
+	"github.com/google/keytransparency/core/transaction"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
 )
 
+const maxMutations = 3
+
 var (
 	minDurationS = time.Second * 1
 	maxDurationH = time.Hour * 6
@@ -84,6 +99,194 @@ func TestEpochCreation(t *testing.T) {
 	}
 }
 
+func TestSubscribe(t *testing.T) {
+	s := New(0, nil, 0, nil, nil, nil, nil, nil, maxMutations)
+	revisions, cancel := s.Subscribe()
+	for i := int64(1); i <= subscriberBuffer+1; i++ {
+		s.publish(i)
+	}
+	// The oldest revisions exceeding the buffer are dropped.
+	for i := int64(2); i <= subscriberBuffer+1; i++ {
+		if got := <-revisions; got != i {
+			t.Errorf("<-revisions: %v, want %v", got, i)
+		}
+	}
+	cancel()
+	if _, ok := <-revisions; ok {
+		t.Errorf("revisions not closed after cancel()")
+	}
+	// Publishing without subscribers and canceling twice must not panic.
+	s.publish(subscriberBuffer + 2)
+	cancel()
+
+	// Revisions which have been published already are ignored.
+	revisions, cancel = s.Subscribe()
+	defer cancel()
+	for _, r := range []int64{subscriberBuffer + 2, 1, subscriberBuffer + 3} {
+		s.publish(r)
+	}
+	if got, want := <-revisions, int64(subscriberBuffer+3); got != want {
+		t.Errorf("<-revisions: %v, want %v", got, want)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tmap := fake.NewFakeTrillianMapClient(mapID)
+	tlog := fake.NewFakeTrillianLogClient()
+	master := New(mapID, tmap, logID, tlog,
+		fakeMutator{}, &fakeMutations{}, fakeFactory{}, nil, maxMutations)
+	standby := New(mapID, tmap, logID, tlog,
+		fakeMutator{}, &fakeMutations{}, fakeFactory{}, nil, maxMutations)
+	if err := master.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize(): %v", err)
+	}
+	revisions, cancelSub := standby.Subscribe()
+	defer cancelSub()
+	done := make(chan struct{})
+	go func() {
+		standby.Follow(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	// Epochs created by the master are published by the standby.
+	for want := int64(1); want <= 3; want++ {
+		if err := master.CreateEpoch(ctx, true); err != nil {
+			t.Fatalf("CreateEpoch(): %v", err)
+		}
+		for got := int64(0); got != want; {
+			select {
+			case got = <-revisions:
+				if got > want {
+					t.Fatalf("<-revisions: %v, want %v", got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("epoch %v not published by the standby", want)
+			}
+		}
+	}
+	cancel()
+	<-done
+}
+
+// transaction.Factory fake.
+type fakeFactory struct{}
+
+func (fakeFactory) NewTxn(ctx context.Context) (transaction.Txn, error) {
+	return &fakeTxn{}, nil
+}
+
+type fakeTxn struct{}
+
+func (*fakeTxn) Prepare(query string) (*sql.Stmt, error) { return nil, nil }
+func (*fakeTxn) Commit() error                           { return nil }
+func (*fakeTxn) Rollback() error                         { return nil }
+
+// mutator.Mutation fake. Sequence numbers are 1-based.
+type fakeMutations struct {
+	mtns []*tpb.SignedKV
+}
+
+func (m *fakeMutations) ReadRange(txn transaction.Txn, startSequence, endSequence uint64, count int32) (uint64, []*tpb.SignedKV, error) {
+	if endSequence-startSequence > uint64(count) {
+		endSequence = startSequence + uint64(count)
+	}
+	if endSequence > uint64(len(m.mtns)) {
+		endSequence = uint64(len(m.mtns))
+	}
+	return endSequence, m.mtns[startSequence:endSequence], nil
+}
+
+func (m *fakeMutations) ReadAll(txn transaction.Txn, startSequence uint64) (uint64, []*tpb.SignedKV, error) {
+	return m.ReadRange(txn, startSequence, uint64(len(m.mtns)), int32(len(m.mtns)))
+}
+
+func (m *fakeMutations) Write(txn transaction.Txn, mutation *tpb.SignedKV) (uint64, error) {
+	m.mtns = append(m.mtns, mutation)
+	return uint64(len(m.mtns)), nil
+}
+
+// mutator.Mutator fake, which sets the value of the mutation.
+type fakeMutator struct{}
+
+func (fakeMutator) Mutate(value, mutation proto.Message) ([]byte, error) {
+	return mutation.(*tpb.SignedKV).GetKeyValue().GetValue(), nil
+}
+
+// batchMap records the number of indexes of every GetLeaves request.
+type batchMap struct {
+	*fake.TrillianMap
+	batches []int
+}
+
+func (m *batchMap) GetLeaves(ctx context.Context, in *trillian.GetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.GetMapLeavesResponse, error) {
+	m.batches = append(m.batches, len(in.GetIndex()))
+	return m.TrillianMap.GetLeaves(ctx, in, opts...)
+}
+
+func TestCreateEpochs(t *testing.T) {
+	ctx := context.Background()
+	tmap := &batchMap{TrillianMap: fake.NewFakeTrillianMapClient(mapID)}
+	mutations := &fakeMutations{}
+	for i := 0; i < 7; i++ {
+		key := sha256.Sum256([]byte(fmt.Sprintf("key %v", i)))
+		mutations.Write(nil, &tpb.SignedKV{KeyValue: &tpb.KeyValue{
+			Key:   key[:],
+			Value: []byte(fmt.Sprintf("value %v", i)),
+		}})
+	}
+	s := New(mapID, tmap, logID, fake.NewFakeTrillianLogClient(),
+		fakeMutator{}, mutations, fakeFactory{}, nil, maxMutations)
+	s.batchSize = 2
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize(): %v", err)
+	}
+
+	// The backlog is drained with several epochs of at most maxMutations
+	// mutations, each read with batches of at most batchSize leaves.
+	s.createEpochs(ctx, false, time.Second)
+	root, err := tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	if got, want := root.GetMapRoot().GetMapRevision(), int64(3); got != want {
+		t.Errorf("MapRevision: %v, want %v", got, want)
+	}
+	if got, want := root.GetMapRoot().GetMetadata().GetHighestFullyCompletedSeq(), int64(7); got != want {
+		t.Errorf("HighestFullyCompletedSeq: %v, want %v", got, want)
+	}
+	if got, want := fmt.Sprint(tmap.batches), "[2 1 2 1 1]"; got != want {
+		t.Errorf("GetLeaves batches: %v, want %v", got, want)
+	}
+	for _, m := range mutations.mtns {
+		resp, err := tmap.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
+			MapId:    mapID,
+			Index:    [][]byte{m.KeyValue.Key},
+			Revision: -1,
+		})
+		if err != nil {
+			t.Fatalf("GetLeaves(): %v", err)
+		}
+		if got, want := resp.MapLeafInclusion[0].Leaf.LeafValue, m.KeyValue.Value; !bytes.Equal(got, want) {
+			t.Errorf("LeafValue: %s, want %s", got, want)
+		}
+	}
+
+	// A forced epoch without mutations keeps the sequence number.
+	s.createEpochs(ctx, true, time.Second)
+	root, err = tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	if got, want := root.GetMapRoot().GetMapRevision(), int64(4); got != want {
+		t.Errorf("MapRevision: %v, want %v", got, want)
+	}
+	if got, want := root.GetMapRoot().GetMetadata().GetHighestFullyCompletedSeq(), int64(7); got != want {
+		t.Errorf("HighestFullyCompletedSeq: %v, want %v", got, want)
+	}
+}
+
 // genFakeTicker creates a time.Tick and generates n Ticks starting from start.
 func genFakeTicker(start time.Time, minInterval time.Duration, n int) <-chan time.Time {
 	tc := make(chan time.Time, n)
@@ -103,3 +306,57 @@ func parseTime(ts string) time.Time {
 	}
 	return ti
 }
+
+func TestCreateEpochsDelayedLog(t *testing.T) {
+	ctx := context.Background()
+	tmap := fake.NewFakeTrillianMapClient(mapID)
+	tlog := &delayedLog{TrillianLog: fake.NewFakeTrillianLogClient(), delay: 50 * time.Millisecond}
+	mutations := &fakeMutations{}
+	for i := 0; i < 7; i++ {
+		key := sha256.Sum256([]byte(fmt.Sprintf("key %v", i)))
+		mutations.Write(nil, &tpb.SignedKV{KeyValue: &tpb.KeyValue{
+			Key:   key[:],
+			Value: []byte(fmt.Sprintf("value %v", i)),
+		}})
+	}
+	s := New(mapID, tmap, logID, tlog,
+		fakeMutator{}, mutations, fakeFactory{}, nil, maxMutations)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize(): %v", err)
+	}
+	if err := s.waitForLog(ctx); err != nil {
+		t.Fatalf("waitForLog(): %v", err)
+	}
+
+	// Every epoch of the backlog waits for the log to sequence the root of
+	// the previous epoch.
+	s.createEpochs(ctx, false, time.Second)
+	root, err := tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	if got, want := root.GetMapRoot().GetMapRevision(), int64(3); got != want {
+		t.Errorf("MapRevision: %v, want %v", got, want)
+	}
+	if got, want := root.GetMapRoot().GetMetadata().GetHighestFullyCompletedSeq(), int64(7); got != want {
+		t.Errorf("HighestFullyCompletedSeq: %v, want %v", got, want)
+	}
+
+	// The log does not catch up within the timeout.
+	if err := s.waitForLog(ctx); err != nil {
+		t.Fatalf("waitForLog(): %v", err)
+	}
+	tlog.delay = time.Hour
+	for i := 7; i < 14; i++ {
+		key := sha256.Sum256([]byte(fmt.Sprintf("key %v", i)))
+		mutations.Write(nil, &tpb.SignedKV{KeyValue: &tpb.KeyValue{Key: key[:]}})
+	}
+	s.createEpochs(ctx, false, 100*time.Millisecond)
+	root, err = tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	if got, want := root.GetMapRoot().GetMapRevision(), int64(4); got != want {
+		t.Errorf("MapRevision: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sequencer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/keytransparency/core/witness"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// witnessPublishTimeout bounds the publication of a single root to the
+	// witness, including the retries of the witness client.
+	witnessPublishTimeout = time.Minute
+	// witnessRetryPeriod is the interval between attempts to publish the
+	// roots whose publication to the witness failed.
+	witnessRetryPeriod = 30 * time.Second
+)
+
+// SetPendingRoots sets the store of the roots which have yet to be published
+// to the witness. By default they are kept in memory, such that they are lost
+// if the sequencer restarts or another replica takes over.
+func (s *Sequencer) SetPendingRoots(pending witness.Pending) {
+	s.pending = pending
+}
+
+// queueWitness stores the root of revision until it has been published to the
+// witness by runWitness.
+func (s *Sequencer) queueWitness(ctx context.Context, revision int64, root []byte) {
+	if s.witness == nil {
+		return
+	}
+	if err := s.pending.Add(ctx, s.mapID, revision, root); err != nil {
+		glog.Errorf("ALERT: cannot store the root of map %v revision %v for the witness: %v",
+			s.mapID, revision, err)
+		witnessFailedCtr.Inc()
+		return
+	}
+	select {
+	case s.witnessc <- struct{}{}:
+	default:
+	}
+}
+
+// runWitness publishes the pending roots to the witness whenever an epoch has
+// been created, and retries failed publications periodically, until ctx is
+// done. Roots left over by a previous master are published on start.
+func (s *Sequencer) runWitness(ctx context.Context) {
+	ticker := time.NewTicker(witnessRetryPeriod)
+	defer ticker.Stop()
+	for {
+		s.publishWitness(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.witnessc:
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishWitness publishes the pending roots to the witness in revision order.
+// It stops at the first failure, which is retried later.
+func (s *Sequencer) publishWitness(ctx context.Context) {
+	roots, err := s.pending.List(ctx, s.mapID)
+	if err != nil {
+		glog.Errorf("pending.List(%v): %v", s.mapID, err)
+		return
+	}
+	revisions := make([]int64, 0, len(roots))
+	for r := range roots {
+		revisions = append(revisions, r)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i] < revisions[j] })
+	defer func() { unwitnessedGauge.Set(float64(len(revisions))) }()
+
+	for len(revisions) > 0 {
+		r := revisions[0]
+		cctx, cancel := context.WithTimeout(ctx, witnessPublishTimeout)
+		err := s.witness.Publish(cctx, s.mapID, r, roots[r])
+		cancel()
+		switch err {
+		case nil:
+		case witness.ErrAlreadyPublished:
+			glog.Errorf("ALERT: witness has a different root for map %v revision %v than %x",
+				s.mapID, r, roots[r])
+		default:
+			glog.Errorf("witness.Publish(%v, %v): %v", s.mapID, r, err)
+			witnessFailedCtr.Inc()
+			return
+		}
+		if err := s.pending.Remove(ctx, s.mapID, r); err != nil {
+			glog.Errorf("pending.Remove(%v, %v): %v", s.mapID, r, err)
+			return
+		}
+		revisions = revisions[1:]
+	}
+}
+
+// memPending keeps the pending roots in memory.
+type memPending struct {
+	mu    sync.Mutex
+	roots map[int64]map[int64][]byte
+}
+
+func newMemPending() *memPending {
+	return &memPending{roots: make(map[int64]map[int64][]byte)}
+}
+
+func (m *memPending) Add(ctx context.Context, mapID, revision int64, root []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.roots[mapID] == nil {
+		m.roots[mapID] = make(map[int64][]byte)
+	}
+	m.roots[mapID][revision] = root
+	return nil
+}
+
+func (m *memPending) Remove(ctx context.Context, mapID, revision int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.roots[mapID], revision)
+	return nil
+}
+
+func (m *memPending) List(ctx context.Context, mapID int64) (map[int64][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	roots := make(map[int64][]byte, len(m.roots[mapID]))
+	for r, root := range m.roots[mapID] {
+		roots[r] = root
+	}
+	return roots, nil
+}
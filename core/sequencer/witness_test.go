@@ -0,0 +1,122 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sequencer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/fake"
+	"github.com/google/keytransparency/core/witness"
+
+	"golang.org/x/net/context"
+)
+
+const witnessMapID = 1
+
+// failingWitness fails to publish roots while fail is set.
+type failingWitness struct {
+	*fake.Witness
+	fail bool
+}
+
+func (w *failingWitness) Publish(ctx context.Context, mapID, revision int64, root []byte) error {
+	if w.fail {
+		return errors.New("witness unavailable")
+	}
+	return w.Witness.Publish(ctx, mapID, revision, root)
+}
+
+// newWitnessSequencer returns a sequencer which only publishes roots to w.
+func newWitnessSequencer(w witness.Witness, pending witness.Pending) *Sequencer {
+	return &Sequencer{
+		mapID:    witnessMapID,
+		witness:  w,
+		pending:  pending,
+		witnessc: make(chan struct{}, 1),
+	}
+}
+
+func TestPublishWitness(t *testing.T) {
+	ctx := context.Background()
+	w := &failingWitness{Witness: fake.NewFakeWitness(), fail: true}
+	s := newWitnessSequencer(w, newMemPending())
+
+	// The root is kept while the witness is unavailable.
+	s.queueWitness(ctx, 1, []byte("root1"))
+	s.publishWitness(ctx)
+	if _, err := w.Lookup(ctx, witnessMapID, 1); err != witness.ErrNotFound {
+		t.Errorf("Lookup(1): %v, want %v", err, witness.ErrNotFound)
+	}
+	checkPending(ctx, t, s.pending, 1)
+
+	// The missing root is published along with the next one.
+	w.fail = false
+	s.queueWitness(ctx, 2, []byte("root2"))
+	s.publishWitness(ctx)
+	checkWitnessed(ctx, t, w, map[int64]string{1: "root1", 2: "root2"})
+	checkPending(ctx, t, s.pending, 0)
+}
+
+func TestWitnessFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &failingWitness{Witness: fake.NewFakeWitness(), fail: true}
+	pending := newMemPending()
+
+	// The master fails to publish its root before it loses mastership.
+	master := newWitnessSequencer(w, pending)
+	master.queueWitness(ctx, 1, []byte("root1"))
+	master.publishWitness(ctx)
+	checkPending(ctx, t, pending, 1)
+
+	// The next master publishes the root left over by the previous one.
+	w.fail = false
+	next := newWitnessSequencer(w, pending)
+	go next.runWitness(ctx)
+	for {
+		if _, err := w.Lookup(ctx, witnessMapID, 1); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	checkWitnessed(ctx, t, w, map[int64]string{1: "root1"})
+}
+
+// checkPending checks that pending holds want roots.
+func checkPending(ctx context.Context, t *testing.T, pending witness.Pending, want int) {
+	roots, err := pending.List(ctx, witnessMapID)
+	if err != nil {
+		t.Fatalf("List(): %v", err)
+	}
+	if got := len(roots); got != want {
+		t.Errorf("len(List()): %v, want %v", got, want)
+	}
+}
+
+// checkWitnessed checks that w has the given roots by revision.
+func checkWitnessed(ctx context.Context, t *testing.T, w witness.Witness, roots map[int64]string) {
+	for r, want := range roots {
+		got, err := w.Lookup(ctx, witnessMapID, r)
+		if err != nil {
+			t.Fatalf("Lookup(%v): %v", r, err)
+		}
+		if !bytes.Equal(got, []byte(want)) {
+			t.Errorf("Lookup(%v): %s, want %s", r, got, want)
+		}
+	}
+}
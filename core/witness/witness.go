@@ -0,0 +1,60 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package witness defines the interface to an external witness, e.g. BFTKV,
+// which stores the map roots published by the sequencer such that monitors
+// can compare them with the map roots served by the key server.
+package witness
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrNotFound occurs when the witness has no root for a revision.
+	ErrNotFound = errors.New("root not found")
+	// ErrAlreadyPublished occurs when a different root has already been
+	// published for a revision.
+	ErrAlreadyPublished = errors.New("a different root was already published")
+)
+
+// Witness publishes and looks up map roots.
+type Witness interface {
+	// Publish stores root as the root hash of the given map revision.
+	Publish(ctx context.Context, mapID, revision int64, root []byte) error
+	// Lookup returns the root hash of the given map revision. It returns
+	// ErrNotFound if no root has been published for the revision.
+	Lookup(ctx context.Context, mapID, revision int64) ([]byte, error)
+}
+
+// Key returns the key under which the root of revision of map mapID is
+// stored.
+func Key(mapID, revision int64) string {
+	return fmt.Sprintf("%d|%d", mapID, revision)
+}
+
+// Pending stores the roots which have yet to be published to the witness,
+// such that they survive restarts of the sequencer and failovers to another
+// replica.
+type Pending interface {
+	// Add stores root as the pending root of the given map revision.
+	Add(ctx context.Context, mapID, revision int64, root []byte) error
+	// Remove removes the pending root of the given map revision.
+	Remove(ctx context.Context, mapID, revision int64) error
+	// List returns the pending roots of map mapID by revision.
+	List(ctx context.Context, mapID int64) (map[int64][]byte, error)
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authorization
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/google/keytransparency/core/authorization"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// JSONAuditor writes access records to a writer, one JSON object per line.
+type JSONAuditor struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditor returns an auditor which writes access records to w.
+func NewJSONAuditor(w io.Writer) *JSONAuditor {
+	return &JSONAuditor{enc: json.NewEncoder(w)}
+}
+
+// Audit writes record to the writer.
+func (a *JSONAuditor) Audit(ctx context.Context, record *authorization.AccessRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enc.Encode(record)
+}
+
+// LogAuditor writes access records to the server log.
+type LogAuditor struct{}
+
+// NewLogAuditor returns an auditor which writes access records to the server
+// log.
+func NewLogAuditor() *LogAuditor {
+	return &LogAuditor{}
+}
+
+// Audit writes record to the server log.
+func (a *LogAuditor) Audit(ctx context.Context, record *authorization.AccessRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	glog.Infof("Access audit: %s", b)
+	return nil
+}
@@ -17,6 +17,7 @@ package authorization
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/google/keytransparency/core/authentication"
 	"github.com/google/keytransparency/core/authorization"
@@ -25,14 +26,37 @@ import (
 )
 
 type authz struct {
+	mu     sync.RWMutex // Guards policy and FilePolicy.modTime.
 	policy *authzpb.AuthorizationPolicy
 }
 
-// New creates a new instance of the authorization module.
+// New creates a new instance of the authorization module. Without a policy,
+// users are only authorized to access their own profiles.
 func New() authorization.Authorization {
 	return &authz{}
 }
 
+// NewFromPolicy creates a new instance of the authorization module which
+// enforces policy.
+func NewFromPolicy(policy *authzpb.AuthorizationPolicy) (authorization.Authorization, error) {
+	if err := ValidatePolicy(policy); err != nil {
+		return nil, err
+	}
+	return &authz{policy: policy}, nil
+}
+
+func (a *authz) getPolicy() *authzpb.AuthorizationPolicy {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.policy
+}
+
+func (a *authz) setPolicy(policy *authzpb.AuthorizationPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policy = policy
+}
+
 // IsAuthorized verifies that the identity issuing the call (from ctx) is
 // authorized to carry the given permission. A call is authorized if:
 //  1. userID matches the identity in sctx,
@@ -45,13 +69,14 @@ func (a *authz) IsAuthorized(sctx *authentication.SecurityContext, mapID int64,
 	}
 
 	// Case 2.
+	policy := a.getPolicy()
 	rLabel := resourceLabel(mapID, appID)
-	roles, ok := a.policy.GetResourceToRoleLabels()[rLabel]
+	roles, ok := policy.GetResourceToRoleLabels()[rLabel]
 	if !ok {
 		return fmt.Errorf("resource <mapID=%v, appID=%v> does not have a defined policy", mapID, appID)
 	}
 	for _, l := range roles.GetLabels() {
-		role := a.policy.GetRoles()[l]
+		role := policy.GetRoles()[l]
 		if isPrincipalInRole(role, sctx.Identity()) && isPermisionInRole(role, permission) {
 			return nil
 		}
@@ -59,6 +84,19 @@ func (a *authz) IsAuthorized(sctx *authentication.SecurityContext, mapID int64,
 	return fmt.Errorf("%v is not authorized to perform %v on resource defined by <mapID=%v, appID=%v>", sctx.Identity(), permission, mapID, appID)
 }
 
+// IsLogged returns whether any role of the resource defined by mapID and appID
+// carries the LOG permission.
+func (a *authz) IsLogged(mapID int64, appID string) bool {
+	policy := a.getPolicy()
+	roles := policy.GetResourceToRoleLabels()[resourceLabel(mapID, appID)]
+	for _, l := range roles.GetLabels() {
+		if isPermisionInRole(policy.GetRoles()[l], authzpb.Permission_LOG) {
+			return true
+		}
+	}
+	return false
+}
+
 func resourceLabel(mapID int64, appID string) string {
 	return fmt.Sprintf("%d|%s", mapID, appID)
 }
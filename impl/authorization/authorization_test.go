@@ -183,6 +183,29 @@ func TestIsAuthorized(t *testing.T) {
 	}
 }
 
+func TestIsLogged(t *testing.T) {
+	a := setup()
+	for _, tc := range []struct {
+		description string
+		mapID       int64
+		appID       string
+		want        bool
+	}{
+		{"LOG in one of multiple roles", 1, "1", true},
+		{"LOG in only role", 1, "2", true},
+		{"role without permissions", 1, "3", false},
+		{"undefined role", 1, "4", false},
+		{"no resource label", 1, "10", false},
+	} {
+		if got := a.IsLogged(tc.mapID, tc.appID); got != tc.want {
+			t.Errorf("%v: IsLogged(%v, %v)=%v, want %v", tc.description, tc.mapID, tc.appID, got, tc.want)
+		}
+	}
+	if New().IsLogged(1, "1") {
+		t.Errorf("IsLogged() without a policy: true, want false")
+	}
+}
+
 func TestResouceLabel(t *testing.T) {
 	for _, tc := range []struct {
 		mapID int64
@@ -0,0 +1,173 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authorization
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	authzpb "github.com/google/keytransparency/core/proto/authorization"
+)
+
+// ValidatePolicy checks that every resource of policy is a valid map_id|app_id
+// label, that every role label refers to a defined role, and that roles only
+// contain known permissions.
+func ValidatePolicy(policy *authzpb.AuthorizationPolicy) error {
+	for label, role := range policy.GetRoles() {
+		if role == nil {
+			return fmt.Errorf("role %q is empty", label)
+		}
+		for _, p := range role.GetPermissions() {
+			if _, ok := authzpb.Permission_name[int32(p)]; !ok {
+				return fmt.Errorf("role %q has unknown permission %v", label, p)
+			}
+		}
+	}
+	for resource, labels := range policy.GetResourceToRoleLabels() {
+		parts := strings.SplitN(resource, "|", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("resource %q is not of the form map_id|app_id", resource)
+		}
+		if _, err := strconv.ParseInt(parts[0], 10, 64); err != nil {
+			return fmt.Errorf("resource %q has invalid map_id: %v", resource, err)
+		}
+		for _, l := range labels.GetLabels() {
+			if _, ok := policy.GetRoles()[l]; !ok {
+				return fmt.Errorf("resource %q refers to undefined role %q", resource, l)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadPolicy reads and validates the policy in path. Files ending in .json are
+// parsed as JSON, all other files as text protos.
+func ReadPolicy(path string) (*authzpb.AuthorizationPolicy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(authzpb.AuthorizationPolicy)
+	if filepath.Ext(path) == ".json" {
+		err = jsonpb.Unmarshal(bytes.NewReader(b), policy)
+	} else {
+		err = proto.UnmarshalText(string(b), policy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", path, err)
+	}
+	if err := ValidatePolicy(policy); err != nil {
+		return nil, fmt.Errorf("invalid policy %v: %v", path, err)
+	}
+	return policy, nil
+}
+
+// FilePolicy is an authorization module whose policy is read from a file.
+// The policy can be reloaded without restarting the server.
+type FilePolicy struct {
+	*authz
+	path    string
+	modTime time.Time
+}
+
+// NewFromFile creates a new instance of the authorization module which
+// enforces the policy in path.
+func NewFromFile(path string) (*FilePolicy, error) {
+	f := &FilePolicy{
+		authz: &authz{},
+		path:  path,
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reads the policy file again. If the policy is invalid, the current
+// policy stays in effect.
+func (f *FilePolicy) Reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	// Remember the modification time even if the policy is invalid such
+	// that Watch only retries once the file changes again.
+	f.mu.Lock()
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+	policy, err := ReadPolicy(f.path)
+	if err != nil {
+		return err
+	}
+	f.setPolicy(policy)
+	return nil
+}
+
+// Watch reloads the policy on SIGHUP and whenever the modification time of the
+// policy file changes, checked every period, until ctx is done.
+func (f *FilePolicy) Watch(ctx context.Context, period time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			f.reload("SIGHUP")
+		case <-ticker.C:
+			info, err := os.Stat(f.path)
+			if err != nil {
+				glog.Errorf("os.Stat(%v): %v", f.path, err)
+				continue
+			}
+			if f.modified(info.ModTime()) {
+				f.reload("file change")
+			}
+		}
+	}
+}
+
+// modified returns whether modTime differs from the modification time of the
+// policy file at the last reload.
+func (f *FilePolicy) modified(modTime time.Time) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return !modTime.Equal(f.modTime)
+}
+
+func (f *FilePolicy) reload(reason string) {
+	if err := f.Reload(); err != nil {
+		glog.Errorf("Reloading authorization policy on %v failed, keeping the current policy: %v", reason, err)
+		return
+	}
+	glog.Infof("Reloaded authorization policy from %v on %v", f.path, reason)
+}
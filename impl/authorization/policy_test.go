@@ -0,0 +1,176 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authorization
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/authentication"
+
+	"golang.org/x/net/context"
+
+	authzpb "github.com/google/keytransparency/core/proto/authorization"
+)
+
+const (
+	helpdesk   = "helpdesk@example.com"
+	textPolicy = `
+roles: <
+  key: "helpdesk"
+  value: <
+    principals: "helpdesk@example.com"
+    permissions: WRITE
+  >
+>
+resource_to_role_labels: <
+  key: "1|app"
+  value: <
+    labels: "helpdesk"
+  >
+>
+`
+	jsonPolicy = `{
+  "roles": {"helpdesk": {"principals": ["helpdesk@example.com"], "permissions": ["WRITE"]}},
+  "resourceToRoleLabels": {"1|app": {"labels": ["helpdesk"]}}
+}`
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile(%v): %v", path, err)
+	}
+	return path
+}
+
+func TestReadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authz")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name, content string
+		wantErr       bool
+	}{
+		{"policy.txt", textPolicy, false},
+		{"policy.json", jsonPolicy, false},
+		{"garbage.txt", "roles: <", true},
+		{"undefined.txt", `resource_to_role_labels: < key: "1|app" value: < labels: "nobody" > >`, true},
+		{"resource.txt", `resource_to_role_labels: < key: "app" value: < > >`, true},
+		{"mapid.txt", `resource_to_role_labels: < key: "x|app" value: < > >`, true},
+	} {
+		path := writeFile(t, dir, tc.name, tc.content)
+		policy, err := ReadPolicy(path)
+		if got, want := err != nil, tc.wantErr; got != want {
+			t.Errorf("ReadPolicy(%v): %v, wantErr %v", tc.name, err, want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		a := &authz{policy: policy}
+		sctx := authentication.NewSecurityContext(helpdesk)
+		if err := a.IsAuthorized(sctx, 1, "app", "user", authzpb.Permission_WRITE); err != nil {
+			t.Errorf("%v: IsAuthorized(helpdesk, WRITE): %v", tc.name, err)
+		}
+		if err := a.IsAuthorized(sctx, 1, "other", "user", authzpb.Permission_WRITE); err == nil {
+			t.Errorf("%v: IsAuthorized(helpdesk, other app) unexpectedly succeeded", tc.name)
+		}
+	}
+}
+
+func TestFilePolicyReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authz")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeFile(t, dir, "policy.txt", "")
+
+	f, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile(): %v", err)
+	}
+	sctx := authentication.NewSecurityContext(helpdesk)
+	if err := f.IsAuthorized(sctx, 1, "app", "user", authzpb.Permission_WRITE); err == nil {
+		t.Errorf("IsAuthorized() with empty policy unexpectedly succeeded")
+	}
+
+	writeFile(t, dir, "policy.txt", textPolicy)
+	if err := f.Reload(); err != nil {
+		t.Fatalf("Reload(): %v", err)
+	}
+	if err := f.IsAuthorized(sctx, 1, "app", "user", authzpb.Permission_WRITE); err != nil {
+		t.Errorf("IsAuthorized() after reload: %v", err)
+	}
+
+	// Invalid policies are rejected and the current policy stays in effect.
+	writeFile(t, dir, "policy.txt", "roles: <")
+	if err := f.Reload(); err == nil {
+		t.Errorf("Reload() of invalid policy unexpectedly succeeded")
+	}
+	if err := f.IsAuthorized(sctx, 1, "app", "user", authzpb.Permission_WRITE); err != nil {
+		t.Errorf("IsAuthorized() after failed reload: %v", err)
+	}
+
+	if _, err := NewFromFile(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Errorf("NewFromFile(missing) unexpectedly succeeded")
+	}
+}
+
+// TestFilePolicyConcurrentReload reloads the policy while it is watched. Run
+// with -race to detect unsynchronized access.
+func TestFilePolicyConcurrentReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authz")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeFile(t, dir, "policy.txt", textPolicy)
+
+	f, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile(): %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Watch(ctx, time.Millisecond)
+		close(done)
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := f.Reload(); err != nil {
+					t.Errorf("Reload(): %v", err)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+	<-done
+}
@@ -39,6 +39,9 @@ const pageSize = 16
 type Client struct {
 	client     mupb.MutationServiceClient
 	pollPeriod time.Duration
+	// treeSize is the size of the last received log root. It is sent to the
+	// server to receive a consistency proof with the next log root.
+	treeSize int64
 }
 
 // New initializes a new mutations API monitoring client.
@@ -72,6 +75,7 @@ func (c *Client) StartPolling(startEpoch int64) (<-chan *ktpb.GetMutationsRespon
 				// only write to results channel and increment epoch
 				// if there was no error:
 				glog.Infof("Got response %v at %v", monitorResp.Epoch, now)
+				c.treeSize = monitorResp.GetLogRoot().GetTreeSize()
 				response <- monitorResp
 				epoch++
 			}
@@ -84,22 +88,30 @@ func (c *Client) pollMutations(ctx context.Context,
 	queryEpoch int64,
 	opts ...grpc.CallOption) (*ktpb.GetMutationsResponse, error) {
 	response, err := c.client.GetMutations(ctx, &ktpb.GetMutationsRequest{
-		PageSize: pageSize,
-		Epoch:    queryEpoch,
+		PageSize:      pageSize,
+		Epoch:         queryEpoch,
+		FirstTreeSize: c.treeSize,
 	}, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	// Page if necessary: query all mutations in the current epoch
-	for response.GetNextPageToken() != "" {
-		req := &ktpb.GetMutationsRequest{PageSize: pageSize}
+	for token := response.GetNextPageToken(); token != ""; {
+		req := &ktpb.GetMutationsRequest{
+			PageSize:      pageSize,
+			Epoch:         queryEpoch,
+			FirstTreeSize: c.treeSize,
+			PageToken:     token,
+		}
 		resp, err := c.client.GetMutations(ctx, req, opts...)
 		if err != nil {
 			return nil, err
 		}
 		response.Mutations = append(response.Mutations, resp.GetMutations()...)
+		token = resp.GetNextPageToken()
 	}
+	response.NextPageToken = ""
 
 	return response, nil
 }
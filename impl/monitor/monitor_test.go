@@ -22,14 +22,50 @@ import (
 	"testing"
 
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	"github.com/google/keytransparency/core/monitor/storage"
+	"github.com/google/trillian"
+
+	mopb "github.com/google/keytransparency/core/proto/monitor_v1_types"
+	localst "github.com/google/keytransparency/impl/monitor/storage"
 )
 
 func TestGetSignedMapRoot(t *testing.T) {
-	srv := New(storage.New())
-	_, err := srv.GetSignedMapRoot(context.TODO(), nil)
+	srv := New(map[string]storage.Storage{"kt": localst.New()})
+	_, err := srv.GetSignedMapRoot(context.TODO(), &mopb.GetMonitoringRequest{})
 	if got, want := err, ErrNothingProcessed; got != want {
 		t.Errorf("GetSignedMapRoot(_, _): %v, want %v", got, want)
 	}
 }
+
+func TestGetSignedMapRootByKtURL(t *testing.T) {
+	stA, stB := localst.New(), localst.New()
+	if err := stA.Set(1, 0, &trillian.SignedMapRoot{MapId: 1}, nil, nil); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if err := stB.Set(1, 0, &trillian.SignedMapRoot{MapId: 2}, nil, nil); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	srv := New(map[string]storage.Storage{"a": stA, "b": stB})
+	for _, tc := range []struct {
+		ktURL     string
+		wantMapID int64
+		wantCode  codes.Code
+	}{
+		{"a", 1, codes.OK},
+		{"b", 2, codes.OK},
+		{"c", 0, codes.NotFound},
+		{"", 0, codes.NotFound}, // Ambiguous.
+	} {
+		resp, err := srv.GetSignedMapRoot(context.TODO(), &mopb.GetMonitoringRequest{Kt_URL: tc.ktURL})
+		if got, want := grpc.Code(err), tc.wantCode; got != want {
+			t.Errorf("GetSignedMapRoot(%q): %v, want code %v", tc.ktURL, err, want)
+			continue
+		}
+		if got, want := resp.GetSmr().GetMapId(), tc.wantMapID; got != want {
+			t.Errorf("GetSignedMapRoot(%q).Smr.MapId: %v, want %v", tc.ktURL, got, want)
+		}
+	}
+}
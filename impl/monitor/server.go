@@ -41,16 +41,32 @@ var (
 // Server holds internal state for the monitor server. It serves monitoring
 // responses via a grpc and HTTP API.
 type Server struct {
-	storage storage.Storage
+	storages map[string]storage.Storage
 }
 
-// New creates a new instance of the monitor server.
-func New(storage storage.Storage) *Server {
+// New creates a new instance of the monitor server. storages maps the URL of
+// each monitored key server to the storage of its monitoring results.
+func New(storages map[string]storage.Storage) *Server {
 	return &Server{
-		storage: storage,
+		storages: storages,
 	}
 }
 
+// storage returns the storage of the key server ktURL. If ktURL is empty and
+// only one key server is monitored, its storage is returned.
+func (s *Server) storage(ktURL string) (storage.Storage, error) {
+	if ktURL == "" && len(s.storages) == 1 {
+		for _, st := range s.storages {
+			return st, nil
+		}
+	}
+	st, ok := s.storages[ktURL]
+	if !ok {
+		return nil, grpc.Errorf(codes.NotFound, "Key server %q is not monitored", ktURL)
+	}
+	return st, nil
+}
+
 // GetSignedMapRoot returns the latest valid signed map root the monitor
 // observed. Additionally, the response contains additional data necessary to
 // reproduce errors on failure.
@@ -60,11 +76,15 @@ func New(storage storage.Storage) *Server {
 // from the previous to the current epoch it won't sign the map root and
 // additional data will be provided to reproduce the failure.
 func (s *Server) GetSignedMapRoot(ctx context.Context, in *mopb.GetMonitoringRequest) (*mopb.GetMonitoringResponse, error) {
-	latestEpoch := s.storage.LatestEpoch()
+	st, err := s.storage(in.GetKt_URL())
+	if err != nil {
+		return nil, err
+	}
+	latestEpoch := st.LatestEpoch()
 	if latestEpoch == 0 {
 		return nil, ErrNothingProcessed
 	}
-	return s.getResponseByRevision(latestEpoch)
+	return getResponseByRevision(st, latestEpoch)
 }
 
 // GetSignedMapRootByRevision works similar to GetSignedMapRoot but returns
@@ -75,14 +95,22 @@ func (s *Server) GetSignedMapRoot(ctx context.Context, in *mopb.GetMonitoringReq
 // mutations from the previous to the current epoch it won't sign the map root
 // and additional data will be provided to reproduce the failure.
 func (s *Server) GetSignedMapRootByRevision(ctx context.Context, in *mopb.GetMonitoringRequest) (*mopb.GetMonitoringResponse, error) {
-	return s.getResponseByRevision(in.GetEpoch())
+	st, err := s.storage(in.GetKt_URL())
+	if err != nil {
+		return nil, err
+	}
+	return getResponseByRevision(st, in.GetEpoch())
 }
 
-func (s *Server) getResponseByRevision(epoch int64) (*mopb.GetMonitoringResponse, error) {
-	res, err := s.storage.Get(epoch)
-	if err == storage.ErrNotFound {
+func getResponseByRevision(st storage.Storage, epoch int64) (*mopb.GetMonitoringResponse, error) {
+	res, err := st.Get(epoch)
+	switch {
+	case err == storage.ErrNotFound:
 		return nil, grpc.Errorf(codes.NotFound,
 			"Could not find monitoring response for epoch %d", epoch)
+	case err != nil:
+		return nil, grpc.Errorf(codes.Internal,
+			"Could not read monitoring response for epoch %d", epoch)
 	}
 
 	resp := &mopb.GetMonitoringResponse{
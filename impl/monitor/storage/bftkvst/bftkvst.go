@@ -1,58 +1,193 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bftkvst implements a monitor storage which keeps the monitoring
+// results in BFTKV in addition to an in-memory cache. Results stored in BFTKV
+// survive restarts of the monitor.
 package bftkvst
 
 import (
-	"bytes"
 	"encoding/binary"
+	"errors"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 
 	"github.com/google/keytransparency/core/monitor/storage"
 	localst "github.com/google/keytransparency/impl/monitor/storage"
+
 	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	mopb "github.com/google/keytransparency/core/proto/monitor_v1_types"
 	"github.com/google/trillian"
-
 	"github.com/yahoo/bftkv/api"
-	"github.com/yahoo/bftkv/protocol"
 )
 
-type bftkvStorage struct {
-	storage *localst.Storage
-	client *protocol.Client
+// kv is the subset of the BFTKV client used by the storage.
+type kv interface {
+	Read(key []byte) ([]byte, error)
+	Write(key, value []byte) error
+}
+
+// Storage stores monitoring results in BFTKV and caches them in memory.
+type Storage struct {
+	storage   *localst.Storage
+	client    kv
+	namespace string
+	latest    int64
 }
 
-func New(path string) storage.Storage {
-	storage := localst.New()
+// New opens the BFTKV client with the keyrings at path and recovers the latest
+// epoch stored in BFTKV. Keys are prefixed with namespace such that the
+// results of several key servers can be stored in the same BFTKV. If the
+// client cannot be opened, results are only kept in memory.
+func New(path, namespace string) *Storage {
 	client, err := api.OpenClient(path)
 	if err != nil {
-		client = nil
+		glog.Errorf("api.OpenClient(%v): %v, storing results in memory only", path, err)
+		return newStorage(nil, namespace)
+	}
+	return newStorage(client, namespace)
+}
+
+func newStorage(client kv, namespace string) *Storage {
+	s := &Storage{
+		storage:   localst.New(),
+		client:    client,
+		namespace: namespace,
+	}
+	if client != nil {
+		s.latest = s.recoverLatestEpoch()
+		glog.Infof("Recovered latest epoch %v from BFTKV", s.latest)
 	}
-	return &bftkvStorage{storage, client}
+	return s
 }
 
-func (s *bftkvStorage) Set(epoch int64,
+// Set stores the monitoring result in memory and in BFTKV.
+func (s *Storage) Set(epoch int64,
 	seenNanos int64,
 	smr *trillian.SignedMapRoot,
 	response *ktpb.GetMutationsResponse,
 	errorList []error) error {
+	if s.client != nil {
+		if _, err := s.client.Read(s.key(epoch)); err == nil {
+			return storage.ErrAlreadyStored
+		}
+		value, err := marshalResult(&storage.MonitoringResult{
+			Smr:      smr,
+			Seen:     seenNanos,
+			Errors:   errorList,
+			Response: response,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.client.Write(s.key(epoch), value); err != nil {
+			return err
+		}
+	}
+	if err := s.storage.Set(epoch, seenNanos, smr, response, errorList); err != nil {
+		return err
+	}
+	if epoch > s.latest {
+		s.latest = epoch
+	}
+	return nil
+}
 
-	err := s.storage.Set(epoch, seenNanos, smr, response, errorList)
+// Get returns the monitoring result of epoch. Results which are not cached in
+// memory are read from BFTKV.
+func (s *Storage) Get(epoch int64) (*storage.MonitoringResult, error) {
+	result, err := s.storage.Get(epoch)
+	if err != storage.ErrNotFound || s.client == nil {
+		return result, err
+	}
+	value, err := s.client.Read(s.key(epoch))
 	if err != nil {
-		return err
+		return nil, storage.ErrNotFound
 	}
-	if s.client != nil {
-		var buf bytes.Buffer
-		binary.Write(&buf, binary.BigEndian, epoch)
-		key := buf.Bytes()
-		if _, err := s.client.Read(key); err == nil {
-			return storage.ErrAlreadyStored
+	return unmarshalResult(value)
+}
+
+// LatestEpoch returns the latest stored epoch, including epochs stored in
+// BFTKV before the monitor was restarted.
+func (s *Storage) LatestEpoch() int64 {
+	return s.latest
+}
+
+// recoverLatestEpoch finds the highest epoch stored in BFTKV. The monitor
+// stores epochs in order starting at 1, hence the stored epochs form a
+// contiguous range which can be searched with an exponential and a binary
+// search.
+func (s *Storage) recoverLatestEpoch() int64 {
+	stored := func(epoch int64) bool {
+		_, err := s.client.Read(s.key(epoch))
+		return err == nil
+	}
+	if !stored(1) {
+		return 0
+	}
+	// Find an upper bound hi which is not stored.
+	lo, hi := int64(1), int64(2)
+	for stored(hi) {
+		lo, hi = hi, hi*2
+	}
+	// Invariant: lo is stored, hi is not.
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if stored(mid) {
+			lo = mid
+		} else {
+			hi = mid
 		}
-		err = s.client.Write(key, []byte(response.String()))
 	}
-	return err
+	return lo
 }
 
-func (s *bftkvStorage) Get(epoch int64) (*storage.MonitoringResult, error) {
-	return s.storage.Get(epoch)
+// key returns the BFTKV key of epoch: the namespace followed by the
+// big-endian epoch.
+func (s *Storage) key(epoch int64) []byte {
+	k := make([]byte, len(s.namespace)+8)
+	copy(k, s.namespace)
+	binary.BigEndian.PutUint64(k[len(s.namespace):], uint64(epoch))
+	return k
 }
 
-func (s *bftkvStorage) LatestEpoch() int64 {
-	return s.storage.LatestEpoch()
+// marshalResult serializes r as a MonitoringRecord.
+func marshalResult(r *storage.MonitoringResult) ([]byte, error) {
+	pb := &mopb.MonitoringRecord{
+		Smr:                r.Smr,
+		SeenTimestampNanos: r.Seen,
+		Response:           r.Response,
+	}
+	for _, err := range r.Errors {
+		pb.Errors = append(pb.Errors, err.Error())
+	}
+	return proto.Marshal(pb)
+}
+
+func unmarshalResult(b []byte) (*storage.MonitoringResult, error) {
+	pb := new(mopb.MonitoringRecord)
+	if err := proto.Unmarshal(b, pb); err != nil {
+		return nil, err
+	}
+	r := &storage.MonitoringResult{
+		Smr:      pb.GetSmr(),
+		Seen:     pb.GetSeenTimestampNanos(),
+		Response: pb.GetResponse(),
+	}
+	for _, e := range pb.GetErrors() {
+		r.Errors = append(r.Errors, errors.New(e))
+	}
+	return r, nil
 }
@@ -0,0 +1,111 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bftkvst
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/keytransparency/core/monitor/storage"
+
+	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/trillian"
+)
+
+type fakeKV map[string][]byte
+
+func (f fakeKV) Read(key []byte) ([]byte, error) {
+	v, ok := f[string(key)]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return v, nil
+}
+
+func (f fakeKV) Write(key, value []byte) error {
+	f[string(key)] = value
+	return nil
+}
+
+func TestSetGetAfterRestart(t *testing.T) {
+	kv := make(fakeKV)
+	s := newStorage(kv, "kt")
+	smr := &trillian.SignedMapRoot{MapRevision: 1, RootHash: []byte("root")}
+	resp := &ktpb.GetMutationsResponse{Epoch: 1, Smr: smr}
+	if err := s.Set(1, 10, smr, resp, nil); err != nil {
+		t.Fatalf("Set(1): %v", err)
+	}
+	failedResp := &ktpb.GetMutationsResponse{Epoch: 2}
+	if err := s.Set(2, 20, nil, failedResp, []error{errors.New("failure")}); err != nil {
+		t.Fatalf("Set(2): %v", err)
+	}
+	if got, want := s.Set(2, 20, nil, nil, nil), storage.ErrAlreadyStored; got != want {
+		t.Errorf("Set(2) again: %v, want %v", got, want)
+	}
+
+	// Restart.
+	s = newStorage(kv, "kt")
+	if got, want := s.LatestEpoch(), int64(2); got != want {
+		t.Errorf("LatestEpoch(): %v, want %v", got, want)
+	}
+	r, err := s.Get(1)
+	if err != nil {
+		t.Fatalf("Get(1): %v", err)
+	}
+	if got, want := r.Seen, int64(10); got != want {
+		t.Errorf("Get(1).Seen: %v, want %v", got, want)
+	}
+	if got, want := r.Smr, smr; !proto.Equal(got, want) {
+		t.Errorf("Get(1).Smr: %v, want %v", got, want)
+	}
+	if got, want := r.Response, resp; !proto.Equal(got, want) {
+		t.Errorf("Get(1).Response: %v, want %v", got, want)
+	}
+	r, err = s.Get(2)
+	if err != nil {
+		t.Fatalf("Get(2): %v", err)
+	}
+	if got, want := len(r.Errors), 1; got != want {
+		t.Fatalf("len(Get(2).Errors): %v, want %v", got, want)
+	}
+	if got, want := r.Errors[0].Error(), "failure"; got != want {
+		t.Errorf("Get(2).Errors[0]: %v, want %v", got, want)
+	}
+	if got, want := r.Response, failedResp; !proto.Equal(got, want) {
+		t.Errorf("Get(2).Response: %v, want %v", got, want)
+	}
+	if _, err := s.Get(3); err != storage.ErrNotFound {
+		t.Errorf("Get(3): %v, want %v", err, storage.ErrNotFound)
+	}
+
+	// Results of other key servers are kept apart.
+	if got, want := newStorage(kv, "other").LatestEpoch(), int64(0); got != want {
+		t.Errorf("LatestEpoch() of other namespace: %v, want %v", got, want)
+	}
+}
+
+func TestRecoverLatestEpoch(t *testing.T) {
+	for _, n := range []int64{0, 1, 2, 3, 7, 8, 9, 100} {
+		kv := make(fakeKV)
+		s := newStorage(kv, "kt")
+		for i := int64(1); i <= n; i++ {
+			kv.Write(s.key(i), []byte{})
+		}
+		if got := newStorage(kv, "kt").LatestEpoch(); got != n {
+			t.Errorf("LatestEpoch() with %v stored epochs: %v", n, got)
+		}
+	}
+}
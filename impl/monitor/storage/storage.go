@@ -15,8 +15,6 @@
 package storage
 
 import (
-	"errors"
-
 	"github.com/google/keytransparency/core/monitor/storage"
 	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
 	"github.com/google/trillian"
@@ -25,10 +23,10 @@ import (
 var (
 	// ErrAlreadyStored is raised if the caller tries storing a response which
 	// has already been stored.
-	ErrAlreadyStored = errors.New("already stored epoch")
+	ErrAlreadyStored = storage.ErrAlreadyStored
 	// ErrNotFound is raised if the caller tries to retrieve data for an epoch
 	// which has not been processed and stored yet.
-	ErrNotFound = errors.New("data for epoch not found")
+	ErrNotFound = storage.ErrNotFound
 )
 
 // Storage is an in-memory store for the monitoring results.
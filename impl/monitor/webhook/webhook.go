@@ -0,0 +1,65 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook implements a monitor.Alerter which posts alerts as JSON to
+// an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/keytransparency/core/monitor"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Webhook posts alerts to a URL.
+type Webhook struct {
+	url     string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// New returns an alerter posting to url. Requests time out after timeout.
+func New(url string, timeout time.Duration) *Webhook {
+	return &Webhook{
+		url:     url,
+		timeout: timeout,
+		client:  http.DefaultClient,
+	}
+}
+
+// Alert posts a as JSON.
+func (w *Webhook) Alert(ctx context.Context, a *monitor.Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	resp, err := ctxhttp.Post(ctx, w.client, w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %v: %v", w.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/monitor"
+
+	"golang.org/x/net/context"
+)
+
+func TestAlert(t *testing.T) {
+	want := &monitor.Alert{
+		MapID:         1,
+		Epoch:         2,
+		Root:          []byte("root"),
+		WitnessedRoot: []byte("witnessed"),
+		Reason:        "reason",
+	}
+	var got monitor.Alert
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Decode(): %v", err)
+		}
+	}))
+	defer s.Close()
+
+	if err := New(s.URL, time.Second).Alert(context.Background(), want); err != nil {
+		t.Fatalf("Alert(): %v", err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("Alert(): posted %v, want %v", got, want)
+	}
+
+	s.Close()
+	if err := New(s.URL, time.Second).Alert(context.Background(), want); err == nil {
+		t.Errorf("Alert() to closed server succeeded")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package authentication authenticates users with verified X.509 client
+// certificates presented during the TLS handshake.
+package authentication
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/keytransparency/core/authentication"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// oidSubjectAltName is the OID of the subject alternative name extension.
+var oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+var (
+	// ErrNoMatchingRule occurs when no rule maps the client certificate
+	// to an identity.
+	ErrNoMatchingRule = errors.New("auth: client certificate does not match any rule")
+)
+
+// Field is the certificate field a rule is matched against.
+type Field string
+
+const (
+	// Email matches the email address SANs of the certificate.
+	Email Field = "email"
+	// URI matches the URI SANs of the certificate.
+	URI Field = "uri"
+	// DNS matches the DNS name SANs of the certificate.
+	DNS Field = "dns"
+	// CommonName matches the common name of the certificate subject.
+	CommonName Field = "cn"
+)
+
+// Rule maps certificates whose Field matches Pattern to an identity. Identity
+// is a template which may refer to submatches of Pattern, e.g. $1.
+type Rule struct {
+	Field    Field
+	Pattern  *regexp.Regexp
+	Identity string
+}
+
+// ParseRule parses a rule of the form field:pattern=identity, e.g.
+// uri:^spiffe://example.com/sa/(.+)$=$1@sa.example.com. The pattern must not
+// contain '='.
+func ParseRule(s string) (*Rule, error) {
+	p := strings.SplitN(s, ":", 2)
+	if len(p) != 2 {
+		return nil, fmt.Errorf("rule %q is not of the form field:pattern=identity", s)
+	}
+	field := Field(p[0])
+	switch field {
+	case Email, URI, DNS, CommonName:
+	default:
+		return nil, fmt.Errorf("rule %q has unknown field %q", s, p[0])
+	}
+	i := strings.LastIndex(p[1], "=")
+	if i < 0 {
+		return nil, fmt.Errorf("rule %q is not of the form field:pattern=identity", s)
+	}
+	pattern, err := regexp.Compile(p[1][:i])
+	if err != nil {
+		return nil, fmt.Errorf("rule %q has invalid pattern: %v", s, err)
+	}
+	return &Rule{
+		Field:    field,
+		Pattern:  pattern,
+		Identity: p[1][i+1:],
+	}, nil
+}
+
+// values returns the values of field in cert.
+func values(cert *x509.Certificate, field Field) []string {
+	switch field {
+	case Email:
+		return cert.EmailAddresses
+	case URI:
+		// x509.Certificate has no URI SANs before Go 1.10.
+		return uriSANs(cert)
+	case DNS:
+		return cert.DNSNames
+	case CommonName:
+		if cert.Subject.CommonName == "" {
+			return nil
+		}
+		return []string{cert.Subject.CommonName}
+	}
+	return nil
+}
+
+// uriSANs returns the URI subject alternative names of cert.
+func uriSANs(cert *x509.Certificate) []string {
+	var uris []string
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var seq asn1.RawValue
+		if rest, err := asn1.Unmarshal(ext.Value, &seq); err != nil || len(rest) != 0 {
+			return nil
+		}
+		for rest := seq.Bytes; len(rest) > 0; {
+			var v asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+				return nil
+			}
+			// uniformResourceIdentifier [6] IA5String
+			if v.Class == asn1.ClassContextSpecific && v.Tag == 6 {
+				uris = append(uris, string(v.Bytes))
+			}
+		}
+	}
+	return uris
+}
+
+// identity returns the identity rule maps cert to.
+func (r *Rule) identity(cert *x509.Certificate) (string, bool) {
+	for _, v := range values(cert, r.Field) {
+		m := r.Pattern.FindStringSubmatchIndex(v)
+		if m == nil {
+			continue
+		}
+		id := r.Pattern.ExpandString(nil, r.Identity, v, m)
+		if len(id) > 0 {
+			return string(id), true
+		}
+	}
+	return "", false
+}
+
+// MTLSAuth authenticates users by their verified client certificates.
+type MTLSAuth struct {
+	rules []*Rule
+}
+
+// New creates a new authenticator which maps client certificates to
+// identities with the first matching rule.
+func New(rules []*Rule) *MTLSAuth {
+	return &MTLSAuth{rules: rules}
+}
+
+// ValidateCreds authenticate the information present in ctx.
+func (a *MTLSAuth) ValidateCreds(ctx context.Context) (*authentication.SecurityContext, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, authentication.ErrMissingAuth
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, authentication.ErrMissingAuth
+	}
+	// Only certificates verified against the client CAs are accepted.
+	chains := tlsInfo.State.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil, authentication.ErrMissingAuth
+	}
+	cert := chains[0][0]
+	for _, r := range a.rules {
+		if id, ok := r.identity(cert); ok {
+			return authentication.NewSecurityContext(id), nil
+		}
+	}
+	glog.V(2).Infof("MTLSAuth: no rule matches certificate %v", cert.Subject)
+	return nil, ErrNoMatchingRule
+}
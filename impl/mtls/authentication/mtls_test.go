@@ -0,0 +1,160 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authentication
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/authentication"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// newCert creates a self-signed certificate with the given common name and
+// email, URI and DNS subject alternative names.
+func newCert(t *testing.T, cn, email, uri, dns string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): %v", err)
+	}
+	var names []asn1.RawValue
+	if email != "" {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, Bytes: []byte(email)})
+	}
+	if dns != "" {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte(dns)})
+	}
+	if uri != "" {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(uri)})
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if len(names) > 0 {
+		san, err := asn1.Marshal(names)
+		if err != nil {
+			t.Fatalf("asn1.Marshal(): %v", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: oidSubjectAltName, Value: san}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate(): %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate(): %v", err)
+	}
+	return cert
+}
+
+func peerContext(verified bool, cert *x509.Certificate) context.Context {
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if verified {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{},
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func mustParseRules(t *testing.T, rules ...string) []*Rule {
+	var ret []*Rule
+	for _, s := range rules {
+		r, err := ParseRule(s)
+		if err != nil {
+			t.Fatalf("ParseRule(%v): %v", s, err)
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func TestParseRule(t *testing.T) {
+	for _, tc := range []struct {
+		rule    string
+		success bool
+	}{
+		{"email:^(.+)@example.com$=$1@example.com", true},
+		{"uri:^spiffe://example.com/sa/(.+)$=$1@sa.example.com", true},
+		{"dns:.*=$0", true},
+		{"cn:^(.+)$=$1", true},
+		{"ip:.*=$0", false},   // Unknown field.
+		{"email", false},      // Missing pattern.
+		{"email:.*", false},   // Missing identity.
+		{"email:(=$1", false}, // Invalid pattern.
+		{"uri:a=b=$0", true},  // Identity after last '='.
+	} {
+		_, err := ParseRule(tc.rule)
+		if got := err == nil; got != tc.success {
+			t.Errorf("ParseRule(%v): %v, want success %v", tc.rule, err, tc.success)
+		}
+	}
+}
+
+func TestValidateCreds(t *testing.T) {
+	auth := New(mustParseRules(t,
+		"uri:^spiffe://example.com/sa/([a-z]+)$=$1@sa.example.com",
+		"email:^(.+@example\\.com)$=$1",
+		"cn:^admin$=admin@example.com",
+	))
+	spiffe := newCert(t, "svc", "", "spiffe://example.com/sa/frontend", "frontend.example.com")
+	user := newCert(t, "alice", "alice@example.com", "", "")
+	admin := newCert(t, "admin", "admin@other.com", "", "")
+	other := newCert(t, "bob", "bob@other.com", "https://other.com/bob", "other.com")
+
+	for _, tc := range []struct {
+		desc    string
+		ctx     context.Context
+		wantID  string
+		wantErr error
+	}{
+		{"no peer", context.Background(), "", authentication.ErrMissingAuth},
+		{"unverified", peerContext(false, user), "", authentication.ErrMissingAuth},
+		{"uri", peerContext(true, spiffe), "frontend@sa.example.com", nil},
+		{"email", peerContext(true, user), "alice@example.com", nil},
+		{"common name", peerContext(true, admin), "admin@example.com", nil},
+		{"no matching rule", peerContext(true, other), "", ErrNoMatchingRule},
+	} {
+		sctx, err := auth.ValidateCreds(tc.ctx)
+		if got, want := err, tc.wantErr; got != want {
+			t.Errorf("%v: ValidateCreds(): %v, want %v", tc.desc, got, want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got, want := sctx.Identity(), tc.wantID; got != want {
+			t.Errorf("%v: Identity(): %v, want %v", tc.desc, got, want)
+		}
+	}
+}
@@ -17,8 +17,6 @@ package mutation
 
 import (
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 
 	cmutation "github.com/google/keytransparency/core/mutation"
 
@@ -43,5 +41,5 @@ func (s *Server) GetMutations(ctx context.Context, in *tpb.GetMutationsRequest)
 
 // GetMutationsStream is a streaming API similar to GetMutations.
 func (s *Server) GetMutationsStream(in *tpb.GetMutationsRequest, stream spb.MutationService_GetMutationsStreamServer) error {
-	return grpc.Errorf(codes.Unimplemented, "GetMutationsStream is unimplemented")
+	return s.srv.GetMutationsStream(stream.Context(), in, stream.Send)
 }
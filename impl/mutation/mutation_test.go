@@ -17,14 +17,37 @@ package mutation
 import (
 	"testing"
 
+	"github.com/google/keytransparency/core/mutation"
+
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
 )
 
+type fakeStream struct {
+	grpc.ServerStream
+	sent []*tpb.GetMutationsResponse
+}
+
+func (s *fakeStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *fakeStream) Send(resp *tpb.GetMutationsResponse) error {
+	s.sent = append(s.sent, resp)
+	return nil
+}
+
 func TestGetMutationsStream(t *testing.T) {
-	srv := &Server{}
-	err := srv.GetMutationsStream(nil, nil)
-	if got, want := grpc.Code(err), codes.Unimplemented; got != want {
+	srv := New(mutation.New(0, 0, nil, nil, nil, nil))
+	stream := &fakeStream{}
+	err := srv.GetMutationsStream(&tpb.GetMutationsRequest{Epoch: 0}, stream)
+	if got, want := grpc.Code(err), codes.InvalidArgument; got != want {
 		t.Errorf("GetMutationsStream(_, _): %v, want %v", got, want)
 	}
+	if got := len(stream.sent); got != 0 {
+		t.Errorf("GetMutationsStream(_, _) sent %v responses, want 0", got)
+	}
 }
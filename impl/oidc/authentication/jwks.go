@@ -0,0 +1,157 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// fetchTimeout bounds fetching a JWKS from a URL.
+const fetchTimeout = 10 * time.Second
+
+// jwk is a JSON Web Key (RFC 7517) containing an RSA or EC public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwks is a JSON Web Key Set.
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
+
+// readJWKS reads the JSON Web Key Set at location, a path or http(s) URL, and
+// returns its signing keys keyed by key ID.
+func readJWKS(location string) (map[string]crypto.PublicKey, error) {
+	var b []byte
+	var err error
+	if isURL(location) {
+		b, err = fetch(location)
+	} else {
+		b, err = ioutil.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading JWKS %v: %v", location, err)
+	}
+	return parseJWKS(b)
+}
+
+func fetch(url string) ([]byte, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %v: %v", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// parseJWKS parses a JSON Web Key Set. Keys which are not meant for signing or
+// which are not supported are skipped. It fails if no usable key is left.
+func parseJWKS(b []byte) (map[string]crypto.PublicKey, error) {
+	var set jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("auth: parsing JWKS: %v", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			glog.V(2).Infof("auth: skipping key %q with use %q", k.Kid, k.Use)
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			glog.Warningf("auth: skipping key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("auth: no usable key in JWKS of %v keys", len(set.Keys))
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if e.BitLen() > 31 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on the P256 curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
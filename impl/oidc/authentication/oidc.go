@@ -0,0 +1,314 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package authentication authenticates users with OpenID Connect ID tokens or
+// other JWTs. Tokens are validated locally against the keys of the issuer.
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/keytransparency/core/authentication"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// refreshInterval is the minimum time between fetches of the JWKS URL.
+const refreshInterval = time.Minute
+
+var (
+	// ErrBadFormat occurs when the authentication header is malformed.
+	ErrBadFormat = errors.New("auth: bad authorization header format")
+	// ErrInvalidToken occurs when the token cannot be parsed or its
+	// signature is invalid.
+	ErrInvalidToken = errors.New("auth: invalid token")
+	// ErrUnknownKey occurs when the token is signed with an unknown key.
+	ErrUnknownKey = errors.New("auth: unknown signing key")
+	// ErrExpired occurs when the token is expired or not yet valid.
+	ErrExpired = errors.New("auth: token expired or not yet valid")
+	// ErrWrongIssuer occurs when the token has been issued by another
+	// issuer.
+	ErrWrongIssuer = errors.New("auth: wrong issuer")
+	// ErrWrongAudience occurs when the token is intended for another
+	// audience.
+	ErrWrongAudience = errors.New("auth: wrong audience")
+	// ErrMissingClaim occurs when a required claim is missing or has the
+	// wrong value.
+	ErrMissingClaim = errors.New("auth: missing claim")
+)
+
+// Config configures the validation of tokens.
+type Config struct {
+	// Issuer is the required iss claim.
+	Issuer string
+	// Audience is the required aud claim.
+	Audience string
+	// JWKS is the path or http(s) URL of the JSON Web Key Set containing
+	// the keys of the issuer.
+	JWKS string
+	// IdentityClaim is the claim used as identity of the user, e.g. email
+	// or sub.
+	IdentityClaim string
+	// RequiredClaims maps claims to their required values, e.g.
+	// email_verified to true.
+	RequiredClaims map[string]string
+	// ClockSkew is the tolerated clock skew when checking exp and nbf.
+	ClockSkew time.Duration
+}
+
+// OIDCAuth authenticates users with signed JWTs.
+type OIDCAuth struct {
+	config Config
+	now    func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+	// refreshing is closed once the running refresh of keys is done. It is
+	// nil if no refresh is running.
+	refreshing chan struct{}
+}
+
+// New creates a new authenticator which validates tokens as configured by
+// config.
+func New(config Config) (*OIDCAuth, error) {
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("auth: issuer and audience are required")
+	}
+	if config.IdentityClaim == "" {
+		return nil, errors.New("auth: identity claim is required")
+	}
+	keys, err := readJWKS(config.JWKS)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCAuth{
+		config:      config,
+		now:         time.Now,
+		keys:        keys,
+		lastRefresh: time.Now(),
+	}, nil
+}
+
+// ValidateCreds authenticate the information present in ctx.
+func (a *OIDCAuth) ValidateCreds(ctx context.Context) (*authentication.SecurityContext, error) {
+	token, err := getBearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	identity, ok := claims[a.config.IdentityClaim].(string)
+	if !ok || identity == "" {
+		glog.V(2).Infof("OIDCAuth: missing identity claim %v", a.config.IdentityClaim)
+		return nil, ErrMissingClaim
+	}
+	return authentication.NewSecurityContext(identity), nil
+}
+
+// header is the JOSE header of a JWT.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks the signature of token and returns its claims.
+func (a *OIDCAuth) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, err := a.key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if h.Alg != "RS256" {
+			return nil, ErrInvalidToken
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], sig); err != nil {
+			return nil, ErrInvalidToken
+		}
+	case *ecdsa.PublicKey:
+		if h.Alg != "ES256" || len(sig) != 64 {
+			return nil, ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, hash[:], r, s) {
+			return nil, ErrInvalidToken
+		}
+	default:
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// key returns the key with ID kid. Keys fetched from a URL are refreshed if
+// kid is unknown, to pick up rotated keys.
+func (a *OIDCAuth) key(kid string) (crypto.PublicKey, error) {
+	if k, ok := a.lookup(kid); ok {
+		return k, nil
+	}
+	if !isURL(a.config.JWKS) {
+		return nil, ErrUnknownKey
+	}
+	a.refresh()
+	if k, ok := a.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (a *OIDCAuth) lookup(kid string) (crypto.PublicKey, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	k, ok := a.keys[kid]
+	return k, ok
+}
+
+// refresh fetches the keys again, unless they have been fetched within
+// refreshInterval. Concurrent callers wait for a single fetch, which runs
+// without holding a.mu such that tokens signed with known keys are still
+// verified meanwhile.
+func (a *OIDCAuth) refresh() {
+	a.mu.Lock()
+	if done := a.refreshing; done != nil {
+		a.mu.Unlock()
+		<-done
+		return
+	}
+	if a.now().Sub(a.lastRefresh) < refreshInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastRefresh = a.now()
+	done := make(chan struct{})
+	a.refreshing = done
+	a.mu.Unlock()
+
+	keys, err := readJWKS(a.config.JWKS)
+
+	a.mu.Lock()
+	if err != nil {
+		glog.Errorf("Refreshing JWKS %v: %v", a.config.JWKS, err)
+	} else {
+		a.keys = keys
+	}
+	a.refreshing = nil
+	a.mu.Unlock()
+	close(done)
+}
+
+// validateClaims checks the issuer, audience, validity period and required
+// claims.
+func (a *OIDCAuth) validateClaims(claims map[string]interface{}) error {
+	if iss, _ := claims["iss"].(string); iss != a.config.Issuer {
+		return ErrWrongIssuer
+	}
+	if !hasAudience(claims["aud"], a.config.Audience) {
+		return ErrWrongAudience
+	}
+	now := a.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(a.config.ClockSkew)) {
+		return ErrExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.config.ClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return ErrExpired
+	}
+	for claim, want := range a.config.RequiredClaims {
+		v, ok := claims[claim]
+		if !ok || fmt.Sprint(v) != want {
+			glog.V(2).Infof("OIDCAuth: claim %v=%v, want %v", claim, v, want)
+			return ErrMissingClaim
+		}
+	}
+	return nil
+}
+
+// hasAudience returns whether aud, a string or a list of strings, contains
+// audience.
+func hasAudience(aud interface{}, audience string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, e := range a {
+			if s, ok := e.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// getBearerToken pulls the bearer token from the "authorization" header in
+// gRPC.
+func getBearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", authentication.ErrMissingAuth
+	}
+	authHeader, ok := md["authorization"]
+	if !ok || len(authHeader) != 1 {
+		return "", authentication.ErrMissingAuth
+	}
+	p := strings.Split(authHeader[0], " ")
+	if len(p) != 2 || !strings.EqualFold(p[0], "Bearer") {
+		return "", ErrBadFormat
+	}
+	return p[1], nil
+}
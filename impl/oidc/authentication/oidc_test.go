@@ -0,0 +1,325 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/authentication"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	issuer   = "https://idp.example.com"
+	audience = "keytransparency"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pad left pads b with zeros to size bytes.
+func pad(b []byte, size int) []byte {
+	return append(make([]byte, size-len(b)), b...)
+}
+
+type testKeys struct {
+	ec  *ecdsa.PrivateKey
+	rsa *rsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey(): %v", err)
+	}
+	return &testKeys{ec: ecKey, rsa: rsaKey}
+}
+
+// marshalJWKS returns a JWKS containing the EC key with ID ecKid and the RSA
+// key with ID "rsa".
+func (k *testKeys) marshalJWKS(t *testing.T, ecKid string) []byte {
+	set := jwks{Keys: []jwk{
+		{
+			Kty: "EC",
+			Kid: ecKid,
+			Use: "sig",
+			Crv: "P-256",
+			X:   b64(pad(k.ec.X.Bytes(), 32)),
+			Y:   b64(pad(k.ec.Y.Bytes(), 32)),
+		},
+		{
+			Kty: "RSA",
+			Kid: "rsa",
+			N:   b64(k.rsa.N.Bytes()),
+			E:   b64(big.NewInt(int64(k.rsa.E)).Bytes()),
+		},
+	}}
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("json.Marshal(): %v", err)
+	}
+	return b
+}
+
+// writeJWKS writes the public keys to a JWKS file in dir.
+func (k *testKeys) writeJWKS(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, k.marshalJWKS(t, "ec"), 0600); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	return path
+}
+
+// sign returns a JWT with claims signed with the key kid.
+func (k *testKeys) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	alg := map[string]string{"ec": "ES256", "rsa": "RS256"}[kid]
+	h, err := json.Marshal(header{Alg: alg, Kid: kid})
+	if err != nil {
+		t.Fatalf("json.Marshal(): %v", err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("json.Marshal(): %v", err)
+	}
+	signed := b64(h) + "." + b64(c)
+	hash := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch kid {
+	case "ec":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, hash[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign(): %v", err)
+		}
+		sig = append(pad(r.Bytes(), 32), pad(s.Bytes(), 32)...)
+	case "rsa":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, hash[:])
+		if err != nil {
+			t.Fatalf("rsa.SignPKCS1v15(): %v", err)
+		}
+	default:
+		// Unknown keys sign with the EC key.
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, hash[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign(): %v", err)
+		}
+		sig = append(pad(r.Bytes(), 32), pad(s.Bytes(), 32)...)
+	}
+	return signed + "." + b64(sig)
+}
+
+func tokenCtx(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestValidateCreds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oidc")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	keys := newTestKeys(t)
+	a, err := New(Config{
+		Issuer:         issuer,
+		Audience:       audience,
+		JWKS:           keys.writeJWKS(t, dir),
+		IdentityClaim:  "email",
+		RequiredClaims: map[string]string{"email_verified": "true"},
+	})
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+	now := time.Now()
+	claims := func(modify func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":            issuer,
+			"aud":            audience,
+			"exp":            now.Add(time.Hour).Unix(),
+			"nbf":            now.Add(-time.Minute).Unix(),
+			"email":          "alice@example.com",
+			"email_verified": true,
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+	valid := keys.sign(t, "ec", claims(nil))
+
+	for _, tc := range []struct {
+		desc    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{"ES256", tokenCtx(valid), nil},
+		{"RS256", tokenCtx(keys.sign(t, "rsa", claims(nil))), nil},
+		{"audience list", tokenCtx(keys.sign(t, "ec", claims(func(c map[string]interface{}) {
+			c["aud"] = []string{"other", audience}
+		}))), nil},
+		{"missing header", context.Background(), authentication.ErrMissingAuth},
+		{"bad format", metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs("authorization", valid)), ErrBadFormat},
+		{"garbage", tokenCtx("a.b.c"), ErrInvalidToken},
+		{"tampered", tokenCtx(valid[:len(valid)-4] + "AAAA"), ErrInvalidToken},
+		{"unknown key", tokenCtx(keys.sign(t, "other", claims(nil))), ErrUnknownKey},
+		{"wrong issuer", tokenCtx(keys.sign(t, "ec", claims(func(c map[string]interface{}) {
+			c["iss"] = "https://evil.example.com"
+		}))), ErrWrongIssuer},
+		{"wrong audience", tokenCtx(keys.sign(t, "ec", claims(func(c map[string]interface{}) {
+			c["aud"] = "other"
+		}))), ErrWrongAudience},
+		{"expired", tokenCtx(keys.sign(t, "ec", claims(func(c map[string]interface{}) {
+			c["exp"] = now.Add(-time.Hour).Unix()
+		}))), ErrExpired},
+		{"not yet valid", tokenCtx(keys.sign(t, "ec", claims(func(c map[string]interface{}) {
+			c["nbf"] = now.Add(time.Hour).Unix()
+		}))), ErrExpired},
+		{"unverified email", tokenCtx(keys.sign(t, "ec", claims(func(c map[string]interface{}) {
+			c["email_verified"] = false
+		}))), ErrMissingClaim},
+		{"missing identity", tokenCtx(keys.sign(t, "ec", claims(func(c map[string]interface{}) {
+			delete(c, "email")
+		}))), ErrMissingClaim},
+	} {
+		sctx, err := a.ValidateCreds(tc.ctx)
+		if got, want := err, tc.wantErr; got != want {
+			t.Errorf("%v: ValidateCreds(): %v, want %v", tc.desc, got, want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got, want := sctx.Identity(), "alice@example.com"; got != want {
+			t.Errorf("%v: Identity(): %v, want %v", tc.desc, got, want)
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	for _, tc := range []struct {
+		jwks    string
+		wantLen int
+		wantErr bool
+	}{
+		{`{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}]}`, 1, false},
+		// Unusable keys are skipped.
+		{`{"keys": [
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+			{"kty": "EC", "kid": "p384", "crv": "P-384"},
+			{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AQ"},
+			{"kty": "RSA", "kid": "a", "use": "sig", "n": "AQAB", "e": "AQAB"}]}`, 1, false},
+		// The set must contain a usable key.
+		{`{"keys": []}`, 0, true},
+		{`{"keys": [{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"}]}`, 0, true},
+		{`{"keys": [{"kty": "oct", "kid": "a"}]}`, 0, true},
+		{`{"keys": [{"kty": "EC", "kid": "a", "crv": "P-384"}]}`, 0, true},
+		{`{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`, 0, true},
+		{`not json`, 0, true},
+	} {
+		keys, err := parseJWKS([]byte(tc.jwks))
+		if got, want := err != nil, tc.wantErr; got != want {
+			t.Errorf("parseJWKS(%v): %v, wantErr %v", tc.jwks, err, want)
+			continue
+		}
+		if got, want := len(keys), tc.wantLen; got != want {
+			t.Errorf("parseJWKS(%v): %v keys, want %v", tc.jwks, got, want)
+		}
+	}
+}
+
+func TestRefreshKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	var mu sync.Mutex
+	body := keys.marshalJWKS(t, "ec")
+	fetches := 0
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		refresh := fetches > 1
+		mu.Unlock()
+		if refresh {
+			entered <- struct{}{}
+			<-release
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(body)
+	}))
+	defer srv.Close()
+	a, err := New(Config{
+		Issuer:        issuer,
+		Audience:      audience,
+		JWKS:          srv.URL,
+		IdentityClaim: "email",
+	})
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+	// The EC key is rotated to the ID "new".
+	mu.Lock()
+	body = keys.marshalJWKS(t, "new")
+	mu.Unlock()
+	a.now = func() time.Time { return time.Now().Add(refreshInterval) }
+
+	const callers = 5
+	errc := make(chan error)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := a.key("new")
+			errc <- err
+		}()
+	}
+	<-entered
+	// Known keys are served while the keys are fetched.
+	if _, err := a.key("ec"); err != nil {
+		t.Errorf("key(ec) during refresh: %v", err)
+	}
+	close(release)
+	for i := 0; i < callers; i++ {
+		if err := <-errc; err != nil {
+			t.Errorf("key(new): %v", err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := fetches, 2; got != want {
+		t.Errorf("%v fetches, want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sequencer implements the sequencer service which streams newly
+// created epochs to monitors and auditors.
+package sequencer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	cmutation "github.com/google/keytransparency/core/mutation"
+	csequencer "github.com/google/keytransparency/core/sequencer"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	spb "github.com/google/keytransparency/impl/proto/sequencer_v1_service"
+)
+
+const (
+	// The SignedMapRoot of an epoch is queued in the log when the epoch is
+	// created. Retry fetching the epoch until the log has integrated it.
+	retryDelay = 500 * time.Millisecond
+	maxRetries = 20
+)
+
+// Server holds internal state for the sequencer service.
+type Server struct {
+	seq       *csequencer.Sequencer
+	mutations *cmutation.Server
+}
+
+// New creates a new instance of the sequencer service.
+func New(seq *csequencer.Sequencer, mutations *cmutation.Server) *Server {
+	return &Server{
+		seq:       seq,
+		mutations: mutations,
+	}
+}
+
+// GetEpochs streams the mutations and proofs of every epoch created after
+// the call until the client disconnects.
+func (s *Server) GetEpochs(in *tpb.GetEpochsRequest, stream spb.SequencerService_GetEpochsServer) error {
+	ctx := stream.Context()
+	revisions, cancel := s.seq.Subscribe()
+	defer cancel()
+
+	var last, treeSize int64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case rev := <-revisions:
+			// Revisions may have been dropped if this stream is slow.
+			// Send every epoch since the last one sent.
+			if last == 0 {
+				last = rev - 1
+			}
+			for epoch := last + 1; epoch <= rev; epoch++ {
+				resp, err := s.getEpoch(ctx, epoch, treeSize)
+				if err != nil {
+					glog.Errorf("getEpoch(%v): %v", epoch, err)
+					return grpc.Errorf(codes.Internal, "Cannot fetch epoch %v", epoch)
+				}
+				if err := stream.Send(&tpb.GetEpochsResponse{Mutations: resp}); err != nil {
+					return err
+				}
+				last = epoch
+				treeSize = resp.GetLogRoot().GetTreeSize()
+			}
+		}
+	}
+}
+
+// getEpoch fetches epoch and retries until the log contains its map root.
+func (s *Server) getEpoch(ctx context.Context, epoch, treeSize int64) (*tpb.GetMutationsResponse, error) {
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		var resp *tpb.GetMutationsResponse
+		resp, err = s.mutations.GetEpoch(ctx, epoch, treeSize)
+		if err == nil {
+			if resp.GetLogRoot().GetTreeSize() > epoch {
+				return resp, nil
+			}
+			err = fmt.Errorf("log does not contain epoch %v yet", epoch)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+	return nil, err
+}
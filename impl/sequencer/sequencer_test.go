@@ -0,0 +1,152 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sequencer
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/fake"
+	"github.com/google/keytransparency/core/mutator/entry"
+	"github.com/google/keytransparency/impl/sql/mutations"
+	"github.com/google/keytransparency/impl/transaction"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	_ "github.com/mattn/go-sqlite3" // Use sqlite database for testing.
+
+	cmutation "github.com/google/keytransparency/core/mutation"
+	csequencer "github.com/google/keytransparency/core/sequencer"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+const (
+	mapID = 1
+	logID = 2
+	// numEpochs exceeds the number of revisions buffered for a
+	// subscriber, such that revisions are dropped while the stream is
+	// not read.
+	numEpochs = 20
+)
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *tpb.GetEpochsResponse
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func (s *fakeStream) Send(resp *tpb.GetEpochsResponse) error {
+	select {
+	case s.sent <- resp:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func newServer(t *testing.T) (*Server, *csequencer.Sequencer, *fake.TrillianMap) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	// Every connection to :memory: opens a separate database.
+	db.SetMaxOpenConns(1)
+	muts, err := mutations.New(db, mapID)
+	if err != nil {
+		t.Fatalf("mutations.New(): %v", err)
+	}
+	factory := transaction.NewFactory(db)
+	tmap := fake.NewFakeTrillianMapClient(mapID)
+	tlog := fake.NewFakeTrillianLogClient()
+	seq := csequencer.New(mapID, tmap, logID, tlog, entry.New(), muts, factory, nil, 10)
+	if err := seq.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize(): %v", err)
+	}
+	msrv := cmutation.New(logID, mapID, tlog, tmap, muts, factory)
+	return New(seq, msrv), seq, tmap
+}
+
+func TestGetEpochs(t *testing.T) {
+	srv, seq, tmap := newServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &fakeStream{ctx: ctx, sent: make(chan *tpb.GetEpochsResponse)}
+	errc := make(chan error)
+	go func() { errc <- srv.GetEpochs(&tpb.GetEpochsRequest{}, stream) }()
+
+	// Epochs created before GetEpochs subscribed are not sent. Create
+	// epochs until the first one is received.
+	var next int64
+	for next == 0 {
+		if err := seq.CreateEpoch(ctx, true); err != nil {
+			t.Fatalf("CreateEpoch(): %v", err)
+		}
+		select {
+		case resp := <-stream.sent:
+			next = resp.GetMutations().GetEpoch() + 1
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Epochs created while the stream is not read are sent nevertheless.
+	for i := 0; i < numEpochs; i++ {
+		if err := seq.CreateEpoch(ctx, true); err != nil {
+			t.Fatalf("CreateEpoch(): %v", err)
+		}
+	}
+	root, err := tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(): %v", err)
+	}
+	for last := root.GetMapRoot().GetMapRevision(); next <= last; next++ {
+		var resp *tpb.GetEpochsResponse
+		select {
+		case resp = <-stream.sent:
+		case err := <-errc:
+			t.Fatalf("GetEpochs(): %v before epoch %v was sent", err, next)
+		}
+		m := resp.GetMutations()
+		if got, want := m.GetEpoch(), next; got != want {
+			t.Fatalf("GetEpochs() sent epoch %v, want %v", got, want)
+		}
+		if got, want := m.GetSmr().GetMapRevision(), next; got != want {
+			t.Errorf("epoch %v: map revision %v, want %v", next, got, want)
+		}
+		if got := m.GetLogRoot().GetTreeSize(); got <= next {
+			t.Errorf("epoch %v: log tree size %v does not contain the map root", next, got)
+		}
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("GetEpochs() after the client disconnected: %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestGetEpochsCancel(t *testing.T) {
+	srv, _, _ := newServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream := &fakeStream{ctx: ctx, sent: make(chan *tpb.GetEpochsResponse)}
+	if err := srv.GetEpochs(&tpb.GetEpochsRequest{}, stream); err != context.Canceled {
+		t.Errorf("GetEpochs(): %v, want %v", err, context.Canceled)
+	}
+}
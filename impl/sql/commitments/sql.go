@@ -18,6 +18,8 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/google/keytransparency/core/transaction"
+
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 
@@ -80,10 +82,6 @@ func New(db *sql.DB, mapID int64) (*Commitments, error) {
 // WriteCommitment saves a commitment to the database.
 // Writes if the same commitment value succeeds.
 func (c *Commitments) Write(ctx context.Context, commitment, data, nonce []byte) (returnErr error) {
-	committed := &tpb.Committed{
-		Key:  nonce,
-		Data: data,
-	}
 	tx, err := c.db.Begin()
 	if err != nil {
 		return err
@@ -97,8 +95,17 @@ func (c *Commitments) Write(ctx context.Context, commitment, data, nonce []byte)
 		}
 		returnErr = tx.Commit()
 	}()
+	return c.WriteTxn(tx, commitment, data, nonce)
+}
 
-	readStmt, err := tx.Prepare(readExpr)
+// WriteTxn saves a commitment to the database as part of txn, which is
+// neither committed nor rolled back.
+func (c *Commitments) WriteTxn(txn transaction.Txn, commitment, data, nonce []byte) error {
+	committed := &tpb.Committed{
+		Key:  nonce,
+		Data: data,
+	}
+	readStmt, err := txn.Prepare(readExpr)
 	if err != nil {
 		return err
 	}
@@ -109,7 +116,7 @@ func (c *Commitments) Write(ctx context.Context, commitment, data, nonce []byte)
 	switchErr := readStmt.QueryRow(c.mapID, commitment).Scan(&value)
 	switch {
 	case switchErr == sql.ErrNoRows:
-		writeStmt, err := tx.Prepare(insertExpr)
+		writeStmt, err := txn.Prepare(insertExpr)
 		if err != nil {
 			return err
 		}
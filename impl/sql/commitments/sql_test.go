@@ -73,3 +73,47 @@ func TestWriteRead(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteTxn(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	defer db.Close()
+	c, err := New(db, 1)
+	if err != nil {
+		t.Fatalf("Failed to create committer: %v", err)
+	}
+
+	for _, tc := range []struct {
+		commitment []byte
+		commit     bool
+	}{
+		{[]byte("committmentA"), false},
+		{[]byte("committmentB"), true},
+	} {
+		txn, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Begin(): %v", err)
+		}
+		if err := c.WriteTxn(txn, tc.commitment, []byte("data"), []byte("key")); err != nil {
+			t.Errorf("WriteTxn(%s): %v", tc.commitment, err)
+		}
+		if tc.commit {
+			err = txn.Commit()
+		} else {
+			err = txn.Rollback()
+		}
+		if err != nil {
+			t.Fatalf("Commit/Rollback(): %v", err)
+		}
+		// Commitments are saved if and only if the transaction commits.
+		data, _, err := c.Read(nil, tc.commitment)
+		if err != nil {
+			t.Errorf("Read(_, %s): %v", tc.commitment, err)
+		}
+		if got, want := data != nil, tc.commit; got != want {
+			t.Errorf("Read(_, %s): %s, want found %v", tc.commitment, data, want)
+		}
+	}
+}
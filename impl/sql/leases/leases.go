@@ -0,0 +1,116 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package leases implements an election.LeaseStore backed by a lock table in
+// a SQL database shared by all replicas.
+package leases
+
+import (
+	"database/sql"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	countExpr  = `SELECT COUNT(*) AS count FROM Leases WHERE Resource = ?;`
+	insertExpr = `INSERT INTO Leases (Resource, Holder, Expiry) VALUES (?, ?, ?);`
+	// acquireExpr takes over the lease if it is held by the same holder or
+	// has expired. The condition and the update are a single statement, so
+	// concurrent replicas cannot both acquire the lease.
+	acquireExpr = `
+	UPDATE Leases SET Holder = ?, Expiry = ?
+	WHERE Resource = ? AND (Holder = ? OR Expiry < ?);`
+	releaseExpr = `DELETE FROM Leases WHERE Resource = ? AND Holder = ?;`
+	holderExpr  = `SELECT Holder, Expiry FROM Leases WHERE Resource = ?;`
+)
+
+var createStmt = []string{
+	`
+	CREATE TABLE IF NOT EXISTS Leases (
+		Resource VARCHAR(255) NOT NULL,
+		Holder   VARCHAR(255) NOT NULL,
+		Expiry   BIGINT       NOT NULL,
+		PRIMARY KEY(Resource)
+	);`,
+}
+
+// Leases stores leases in a SQL database. Expiry times are taken from the
+// clocks of the replicas, which are expected to be synchronized much more
+// precisely than the TTL of the leases.
+type Leases struct {
+	db *sql.DB
+}
+
+// New returns a lease store backed by db.
+func New(db *sql.DB) (*Leases, error) {
+	l := &Leases{db: db}
+	for _, stmt := range createStmt {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Acquire acquires or renews the lease on resource for holder unless another
+// holder holds an unexpired lease.
+func (l *Leases) Acquire(ctx context.Context, resource, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	expiry := now.Add(ttl).UnixNano()
+	var count int
+	if err := l.db.QueryRowContext(ctx, countExpr, resource).Scan(&count); err != nil {
+		return false, err
+	}
+	if count == 0 {
+		// If another replica inserts the row first, the primary key
+		// makes this insert fail and the next campaign round competes
+		// for the existing row.
+		if _, err := l.db.ExecContext(ctx, insertExpr, resource, holder, expiry); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	res, err := l.db.ExecContext(ctx, acquireExpr, holder, expiry, resource, holder, now.UnixNano())
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Release releases the lease on resource if it is held by holder.
+func (l *Leases) Release(ctx context.Context, resource, holder string) error {
+	_, err := l.db.ExecContext(ctx, releaseExpr, resource, holder)
+	return err
+}
+
+// Holder returns the holder of the unexpired lease on resource.
+func (l *Leases) Holder(ctx context.Context, resource string) (string, error) {
+	var holder string
+	var expiry int64
+	switch err := l.db.QueryRowContext(ctx, holderExpr, resource).Scan(&holder, &expiry); {
+	case err == sql.ErrNoRows:
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+	if time.Now().UnixNano() > expiry {
+		return "", nil
+	}
+	return holder, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leases
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/net/context"
+)
+
+func TestLeases(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	defer db.Close()
+	l, err := New(db)
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		holder     string
+		ttl        time.Duration
+		release    bool
+		want       bool
+		wantHolder string
+	}{
+		{"acquire", "a", time.Hour, false, true, "a"},
+		{"held", "b", time.Hour, false, false, "a"},
+		{"renew", "a", 50 * time.Millisecond, false, true, "a"},
+		{"expired", "b", time.Hour, false, true, "b"},
+		{"release", "b", time.Hour, true, false, ""},
+		{"released", "a", time.Hour, false, true, "a"},
+	} {
+		if tc.desc == "expired" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if tc.release {
+			if err := l.Release(ctx, "r", tc.holder); err != nil {
+				t.Fatalf("%v: Release(): %v", tc.desc, err)
+			}
+		} else {
+			got, err := l.Acquire(ctx, "r", tc.holder, tc.ttl)
+			if err != nil {
+				t.Fatalf("%v: Acquire(): %v", tc.desc, err)
+			}
+			if got != tc.want {
+				t.Errorf("%v: Acquire(%v): %v, want %v", tc.desc, tc.holder, got, tc.want)
+			}
+		}
+		holder, err := l.Holder(ctx, "r")
+		if err != nil {
+			t.Fatalf("%v: Holder(): %v", tc.desc, err)
+		}
+		if holder != tc.wantHolder {
+			t.Errorf("%v: Holder(): %q, want %q", tc.desc, holder, tc.wantHolder)
+		}
+	}
+}
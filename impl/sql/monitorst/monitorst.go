@@ -0,0 +1,256 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package monitorst implements a SQL backed storage for monitoring results.
+package monitorst
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/keytransparency/core/monitor/storage"
+
+	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/trillian"
+)
+
+const (
+	insertMapRowExpr = `INSERT INTO Maps (KtURL, MapID) VALUES (?, ?);`
+	countMapRowExpr  = `SELECT COUNT(*) AS count FROM Maps WHERE KtURL = ? AND MapID = ?;`
+	countExpr        = `
+	SELECT COUNT(*) AS count FROM MonitoringResults
+	WHERE KtURL = ? AND MapID = ? AND Epoch = ?;`
+	insertExpr = `
+	INSERT INTO MonitoringResults (KtURL, MapID, Epoch, Smr, Seen, Errors, Response)
+	VALUES (?, ?, ?, ?, ?, ?, ?);`
+	readExpr = `
+	SELECT Smr, Seen, Errors, Response FROM MonitoringResults
+	WHERE KtURL = ? AND MapID = ? AND Epoch = ?;`
+	readRangeExpr = `
+	SELECT Smr, Seen, Errors, Response FROM MonitoringResults
+	WHERE KtURL = ? AND MapID = ? AND Epoch >= ? AND Epoch <= ?
+	ORDER BY Epoch ASC;`
+	latestExpr = `
+	SELECT COALESCE(MAX(Epoch), 0) FROM MonitoringResults
+	WHERE KtURL = ? AND MapID = ?;`
+)
+
+var createStmt = []string{
+	`
+	CREATE TABLE IF NOT EXISTS Maps (
+		KtURL   VARCHAR(255) NOT NULL,
+		MapID   BIGINT NOT NULL,
+		PRIMARY KEY(KtURL, MapID)
+	);`,
+	`
+	CREATE TABLE IF NOT EXISTS MonitoringResults (
+		KtURL    VARCHAR(255) NOT NULL,
+		MapID    BIGINT NOT NULL,
+		Epoch    BIGINT NOT NULL,
+		Smr      BLOB,
+		Seen     BIGINT NOT NULL,
+		Errors   BLOB,
+		Response LONGBLOB,
+		PRIMARY KEY(KtURL, MapID, Epoch),
+		FOREIGN KEY(KtURL, MapID) REFERENCES Maps(KtURL, MapID) ON DELETE CASCADE
+	);`,
+}
+
+// Storage stores monitoring results in a SQL database.
+type Storage struct {
+	ktURL string
+	mapID int64
+	db    *sql.DB
+}
+
+// New returns a new SQL backed monitor storage for the results of map mapID
+// of the key server at ktURL. Key servers may use the same map ID, hence
+// results are kept apart by both.
+func New(db *sql.DB, ktURL string, mapID int64) (*Storage, error) {
+	s := &Storage{
+		ktURL: ktURL,
+		mapID: mapID,
+		db:    db,
+	}
+
+	// Create tables.
+	if err := s.create(); err != nil {
+		return nil, err
+	}
+	if err := s.insertMapRow(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Set stores the given data as a MonitoringResult which can be retrieved by
+// Get. It returns storage.ErrAlreadyStored if epoch has been stored before.
+func (s *Storage) Set(epoch int64,
+	seenNanos int64,
+	smr *trillian.SignedMapRoot,
+	response *ktpb.GetMutationsResponse,
+	errorList []error) (returnErr error) {
+	var smrB, respB []byte
+	var err error
+	if smr != nil {
+		if smrB, err = proto.Marshal(smr); err != nil {
+			return err
+		}
+	}
+	if response != nil {
+		if respB, err = proto.Marshal(response); err != nil {
+			return err
+		}
+	}
+	errStrs := make([]string, 0, len(errorList))
+	for _, e := range errorList {
+		errStrs = append(errStrs, e.Error())
+	}
+	errsB, err := json.Marshal(errStrs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if returnErr != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				returnErr = fmt.Errorf("Set failed: %v, and Rollback failed: %v", returnErr, rbErr)
+			}
+			return
+		}
+		returnErr = tx.Commit()
+	}()
+
+	var count int
+	if err := tx.QueryRow(countExpr, s.ktURL, s.mapID, epoch).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return storage.ErrAlreadyStored
+	}
+	_, err = tx.Exec(insertExpr, s.ktURL, s.mapID, epoch, smrB, seenNanos, errsB, respB)
+	return err
+}
+
+// Get returns the MonitoringResult for the given epoch. It returns
+// storage.ErrNotFound if the result does not exist.
+func (s *Storage) Get(epoch int64) (*storage.MonitoringResult, error) {
+	r, err := scanResult(s.db.QueryRow(readExpr, s.ktURL, s.mapID, epoch))
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	return r, err
+}
+
+// GetRange returns the MonitoringResults of all stored epochs in [start, end]
+// ordered by epoch.
+func (s *Storage) GetRange(start, end int64) ([]*storage.MonitoringResult, error) {
+	rows, err := s.db.Query(readRangeExpr, s.ktURL, s.mapID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]*storage.MonitoringResult, 0)
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// LatestEpoch returns the latest stored epoch or 0 if nothing has been stored.
+func (s *Storage) LatestEpoch() int64 {
+	var epoch int64
+	if err := s.db.QueryRow(latestExpr, s.ktURL, s.mapID).Scan(&epoch); err != nil {
+		glog.Errorf("LatestEpoch(): %v", err)
+		return 0
+	}
+	return epoch
+}
+
+// scanner is implemented by sql.Row and sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanResult(row scanner) (*storage.MonitoringResult, error) {
+	var smrB, errsB, respB []byte
+	r := new(storage.MonitoringResult)
+	if err := row.Scan(&smrB, &r.Seen, &errsB, &respB); err != nil {
+		return nil, err
+	}
+	if len(smrB) > 0 {
+		r.Smr = new(trillian.SignedMapRoot)
+		if err := proto.Unmarshal(smrB, r.Smr); err != nil {
+			return nil, err
+		}
+	}
+	if len(respB) > 0 {
+		r.Response = new(ktpb.GetMutationsResponse)
+		if err := proto.Unmarshal(respB, r.Response); err != nil {
+			return nil, err
+		}
+	}
+	var errStrs []string
+	if len(errsB) > 0 {
+		if err := json.Unmarshal(errsB, &errStrs); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range errStrs {
+		r.Errors = append(r.Errors, errors.New(e))
+	}
+	return r, nil
+}
+
+// Create creates new database tables.
+func (s *Storage) create() error {
+	for _, stmt := range createStmt {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("Failed to create monitoring results tables: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) insertMapRow() error {
+	// Check if a map row does not exist for the same key server and MapID.
+	var count int
+	if err := s.db.QueryRow(countMapRowExpr, s.ktURL, s.mapID).Scan(&count); err != nil {
+		return fmt.Errorf("insertMapRow(): %v", err)
+	}
+	if count >= 1 {
+		return nil
+	}
+
+	// Insert a map row if it does not exist already.
+	if _, err := s.db.Exec(insertMapRowExpr, s.ktURL, s.mapID); err != nil {
+		return fmt.Errorf("insertMapRow(): %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitorst
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/keytransparency/core/monitor/storage"
+	_ "github.com/mattn/go-sqlite3"
+
+	ktpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+	"github.com/google/trillian"
+)
+
+func newStorage(t *testing.T) (*Storage, func()) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	s, err := New(db, "kt", 1)
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+	return s, func() { db.Close() }
+}
+
+func TestSetGet(t *testing.T) {
+	s, done := newStorage(t)
+	defer done()
+
+	if got, want := s.LatestEpoch(), int64(0); got != want {
+		t.Errorf("LatestEpoch(): %v, want %v", got, want)
+	}
+	smr := &trillian.SignedMapRoot{MapRevision: 1, RootHash: []byte("root")}
+	resp := &ktpb.GetMutationsResponse{Epoch: 1, Smr: smr}
+	for _, tc := range []struct {
+		epoch int64
+		smr   *trillian.SignedMapRoot
+		resp  *ktpb.GetMutationsResponse
+		errs  []error
+		want  error
+	}{
+		{1, smr, resp, nil, nil},
+		{1, smr, resp, nil, storage.ErrAlreadyStored},
+		{2, nil, &ktpb.GetMutationsResponse{Epoch: 2}, []error{errors.New("a"), errors.New("b")}, nil},
+		{3, nil, nil, nil, nil},
+	} {
+		if got := s.Set(tc.epoch, tc.epoch*10, tc.smr, tc.resp, tc.errs); got != tc.want {
+			t.Errorf("Set(%v): %v, want %v", tc.epoch, got, tc.want)
+		}
+	}
+	if got, want := s.LatestEpoch(), int64(3); got != want {
+		t.Errorf("LatestEpoch(): %v, want %v", got, want)
+	}
+
+	r, err := s.Get(1)
+	if err != nil {
+		t.Fatalf("Get(1): %v", err)
+	}
+	if got, want := r.Seen, int64(10); got != want {
+		t.Errorf("Get(1).Seen: %v, want %v", got, want)
+	}
+	if !proto.Equal(r.Smr, smr) || !proto.Equal(r.Response, resp) {
+		t.Errorf("Get(1): {%v, %v}, want {%v, %v}", r.Smr, r.Response, smr, resp)
+	}
+	r, err = s.Get(2)
+	if err != nil {
+		t.Fatalf("Get(2): %v", err)
+	}
+	if got, want := len(r.Errors), 2; got != want {
+		t.Fatalf("len(Get(2).Errors): %v, want %v", got, want)
+	}
+	if got, want := r.Errors[1].Error(), "b"; got != want {
+		t.Errorf("Get(2).Errors[1]: %v, want %v", got, want)
+	}
+	if r.Smr != nil {
+		t.Errorf("Get(2).Smr: %v, want nil", r.Smr)
+	}
+	if _, err := s.Get(4); err != storage.ErrNotFound {
+		t.Errorf("Get(4): %v, want %v", err, storage.ErrNotFound)
+	}
+
+	results, err := s.GetRange(2, 10)
+	if err != nil {
+		t.Fatalf("GetRange(): %v", err)
+	}
+	if got, want := len(results), 2; got != want {
+		t.Fatalf("len(GetRange(2, 10)): %v, want %v", got, want)
+	}
+	if got, want := results[0].Seen, int64(20); got != want {
+		t.Errorf("GetRange(2, 10)[0].Seen: %v, want %v", got, want)
+	}
+}
+
+func TestKeyServersApart(t *testing.T) {
+	s, done := newStorage(t)
+	defer done()
+	// Another key server using the same map ID.
+	other, err := New(s.db, "other", 1)
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+
+	if err := s.Set(1, 10, nil, nil, nil); err != nil {
+		t.Fatalf("Set(1): %v", err)
+	}
+	if err := other.Set(1, 20, nil, nil, nil); err != nil {
+		t.Fatalf("other.Set(1): %v", err)
+	}
+	if err := other.Set(2, 30, nil, nil, nil); err != nil {
+		t.Fatalf("other.Set(2): %v", err)
+	}
+	if got, want := s.LatestEpoch(), int64(1); got != want {
+		t.Errorf("LatestEpoch(): %v, want %v", got, want)
+	}
+	r, err := s.Get(1)
+	if err != nil {
+		t.Fatalf("Get(1): %v", err)
+	}
+	if got, want := r.Seen, int64(10); got != want {
+		t.Errorf("Get(1).Seen: %v, want %v", got, want)
+	}
+	if _, err := s.Get(2); err != storage.ErrNotFound {
+		t.Errorf("Get(2): %v, want %v", err, storage.ErrNotFound)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package unwitnessed implements a witness.Pending backed by a table in a SQL
+// database shared by all replicas of the sequencer.
+package unwitnessed
+
+import (
+	"database/sql"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	countExpr  = `SELECT COUNT(*) AS count FROM UnwitnessedRoots WHERE MapID = ? AND Revision = ?;`
+	insertExpr = `INSERT INTO UnwitnessedRoots (MapID, Revision, Root) VALUES (?, ?, ?);`
+	deleteExpr = `DELETE FROM UnwitnessedRoots WHERE MapID = ? AND Revision = ?;`
+	listExpr   = `SELECT Revision, Root FROM UnwitnessedRoots WHERE MapID = ?;`
+)
+
+var createStmt = []string{
+	`
+	CREATE TABLE IF NOT EXISTS UnwitnessedRoots (
+		MapID    BIGINT NOT NULL,
+		Revision BIGINT NOT NULL,
+		Root     BLOB   NOT NULL,
+		PRIMARY KEY(MapID, Revision)
+	);`,
+}
+
+// Roots stores the map roots which have yet to be published to the witness.
+type Roots struct {
+	db *sql.DB
+}
+
+// New returns a store of unwitnessed roots backed by db.
+func New(db *sql.DB) (*Roots, error) {
+	r := &Roots{db: db}
+	for _, stmt := range createStmt {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add stores root as the unwitnessed root of the given map revision. Adding a
+// revision which is already stored is a no-op, since the root of a map
+// revision never changes.
+func (r *Roots) Add(ctx context.Context, mapID, revision int64, root []byte) error {
+	var count int
+	if err := r.db.QueryRowContext(ctx, countExpr, mapID, revision).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, insertExpr, mapID, revision, root)
+	return err
+}
+
+// Remove removes the unwitnessed root of the given map revision.
+func (r *Roots) Remove(ctx context.Context, mapID, revision int64) error {
+	_, err := r.db.ExecContext(ctx, deleteExpr, mapID, revision)
+	return err
+}
+
+// List returns the unwitnessed roots of map mapID by revision.
+func (r *Roots) List(ctx context.Context, mapID int64) (map[int64][]byte, error) {
+	rows, err := r.db.QueryContext(ctx, listExpr, mapID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roots := make(map[int64][]byte)
+	for rows.Next() {
+		var revision int64
+		var root []byte
+		if err := rows.Scan(&revision, &root); err != nil {
+			return nil, err
+		}
+		roots[revision] = root
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unwitnessed
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/net/context"
+)
+
+func TestRoots(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	defer db.Close()
+	r, err := New(db)
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		mapID    int64
+		revision int64
+		root     string
+		remove   bool
+		want     map[int64][]byte
+	}{
+		{"add", 1, 1, "a", false, map[int64][]byte{1: []byte("a")}},
+		{"add again", 1, 1, "a", false, map[int64][]byte{1: []byte("a")}},
+		{"add next", 1, 2, "b", false, map[int64][]byte{1: []byte("a"), 2: []byte("b")}},
+		{"other map", 2, 1, "c", false, map[int64][]byte{1: []byte("a"), 2: []byte("b")}},
+		{"remove", 1, 1, "", true, map[int64][]byte{2: []byte("b")}},
+		{"remove again", 1, 1, "", true, map[int64][]byte{2: []byte("b")}},
+		{"remove last", 1, 2, "", true, map[int64][]byte{}},
+	} {
+		if tc.remove {
+			if err := r.Remove(ctx, tc.mapID, tc.revision); err != nil {
+				t.Fatalf("%v: Remove(): %v", tc.desc, err)
+			}
+		} else {
+			if err := r.Add(ctx, tc.mapID, tc.revision, []byte(tc.root)); err != nil {
+				t.Fatalf("%v: Add(): %v", tc.desc, err)
+			}
+		}
+		got, err := r.List(ctx, 1)
+		if err != nil {
+			t.Fatalf("%v: List(): %v", tc.desc, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: List(): %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
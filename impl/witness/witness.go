@@ -0,0 +1,148 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package witness implements a witness.Witness backed by the HTTP front end of
+// a BFTKV cluster.
+package witness
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/keytransparency/core/witness"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	writePath = "/writeonce/"
+	readPath  = "/read/"
+)
+
+// BFTKV publishes roots to a BFTKV HTTP front end.
+type BFTKV struct {
+	endpoint   string
+	timeout    time.Duration
+	retries    int
+	retryDelay time.Duration
+	client     *http.Client
+}
+
+// New returns a witness that talks to the BFTKV HTTP front end at endpoint,
+// e.g. http://localhost:6001. Each request times out after timeout and
+// failed requests are retried up to retries times.
+func New(endpoint string, timeout time.Duration, retries int) *BFTKV {
+	return &BFTKV{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		timeout:    timeout,
+		retries:    retries,
+		retryDelay: time.Second,
+		client:     http.DefaultClient,
+	}
+}
+
+// Publish writes root to BFTKV. Roots are written once; publishing a
+// different root for the same revision fails.
+func (b *BFTKV) Publish(ctx context.Context, mapID, revision int64, root []byte) error {
+	key := witness.Key(mapID, revision)
+	return b.retry(ctx, func(ctx context.Context) (bool, error) {
+		// The BFTKV HTTP front end reads the value from the request body.
+		req, err := http.NewRequest("GET", b.endpoint+writePath+key, bytes.NewReader(root))
+		if err != nil {
+			return false, err
+		}
+		resp, err := ctxhttp.Do(ctx, b.client, req)
+		if err != nil {
+			return true, err
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return false, nil
+		case resp.StatusCode >= http.StatusInternalServerError:
+			return true, fmt.Errorf("writing %v: %v", key, resp.Status)
+		}
+		// The key has already been written. Fine if the roots match.
+		stored, err := b.Lookup(ctx, mapID, revision)
+		if err != nil {
+			return false, fmt.Errorf("writing %v: %v", key, resp.Status)
+		}
+		if !bytes.Equal(stored, root) {
+			return false, witness.ErrAlreadyPublished
+		}
+		return false, nil
+	})
+}
+
+// Lookup reads the root of revision from BFTKV.
+func (b *BFTKV) Lookup(ctx context.Context, mapID, revision int64) ([]byte, error) {
+	key := witness.Key(mapID, revision)
+	var root []byte
+	err := b.retry(ctx, func(ctx context.Context) (bool, error) {
+		resp, err := ctxhttp.Get(ctx, b.client, b.endpoint+readPath+key)
+		if err != nil {
+			return true, err
+		}
+		defer resp.Body.Close()
+		// Non-OK responses contain an error message in the body.
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return false, witness.ErrNotFound
+		case resp.StatusCode >= http.StatusInternalServerError:
+			return true, fmt.Errorf("reading %v: %v", key, resp.Status)
+		case resp.StatusCode != http.StatusOK:
+			return false, fmt.Errorf("reading %v: %v", key, resp.Status)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return true, err
+		}
+		if len(body) == 0 {
+			return false, witness.ErrNotFound
+		}
+		root = body
+		return false, nil
+	})
+	return root, err
+}
+
+// retry calls f until it succeeds, returns a permanent error, or the retries
+// are exhausted. f returns whether its error is transient.
+func (b *BFTKV) retry(ctx context.Context, f func(context.Context) (bool, error)) error {
+	var err error
+	for i := 0; i <= b.retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(b.retryDelay):
+			}
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, b.timeout)
+		var transient bool
+		transient, err = f(reqCtx)
+		cancel()
+		if err == nil || !transient {
+			return err
+		}
+		glog.Warningf("BFTKV request failed (attempt %v/%v): %v", i+1, b.retries+1, err)
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package witness
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/keytransparency/core/witness"
+
+	"golang.org/x/net/context"
+)
+
+// fakeBFTKV mimics the BFTKV HTTP front end. The first failures requests
+// fail with an internal server error.
+type fakeBFTKV struct {
+	mu       sync.Mutex
+	kv       map[string][]byte
+	failures int
+}
+
+func (f *fakeBFTKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, writePath):
+		key := strings.TrimPrefix(r.URL.Path, writePath)
+		if _, ok := f.kv[key]; ok {
+			http.Error(w, "already written", http.StatusForbidden)
+			return
+		}
+		f.kv[key], _ = ioutil.ReadAll(r.Body)
+	case strings.HasPrefix(r.URL.Path, readPath):
+		v, ok := f.kv[strings.TrimPrefix(r.URL.Path, readPath)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(v)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPublishLookup(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeBFTKV{kv: make(map[string][]byte), failures: 2}
+	s := httptest.NewServer(f)
+	defer s.Close()
+	b := New(s.URL, time.Second, 2)
+	b.retryDelay = time.Millisecond
+
+	if _, err := b.Lookup(ctx, 1, 1); err != witness.ErrNotFound {
+		t.Errorf("Lookup() before Publish(): %v, want %v", err, witness.ErrNotFound)
+	}
+	for _, tc := range []struct {
+		root []byte
+		want error
+	}{
+		{[]byte("root"), nil},
+		{[]byte("root"), nil}, // Publishing the same root is idempotent.
+		{[]byte("other"), witness.ErrAlreadyPublished},
+	} {
+		if got := b.Publish(ctx, 1, 1, tc.root); got != tc.want {
+			t.Errorf("Publish(%s): %v, want %v", tc.root, got, tc.want)
+		}
+	}
+	root, err := b.Lookup(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("Lookup(): %v", err)
+	}
+	if got, want := root, []byte("root"); !bytes.Equal(got, want) {
+		t.Errorf("Lookup(): %s, want %s", got, want)
+	}
+	if got, want := f.kv[witness.Key(1, 1)], []byte("root"); !bytes.Equal(got, want) {
+		t.Errorf("stored root: %s, want %s", got, want)
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	f := &fakeBFTKV{kv: make(map[string][]byte), failures: 3}
+	s := httptest.NewServer(f)
+	defer s.Close()
+	b := New(s.URL, time.Second, 2)
+	b.retryDelay = time.Millisecond
+
+	if err := b.Publish(context.Background(), 1, 1, []byte("root")); err == nil {
+		t.Errorf("Publish() with unavailable witness succeeded")
+	}
+}
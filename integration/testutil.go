@@ -15,8 +15,11 @@
 package integration
 
 import (
+	"crypto"
 	"database/sql"
+	"flag"
 	"log"
+	"math/rand"
 	"net"
 	"testing"
 
@@ -47,8 +50,12 @@ import (
 	stestonly "github.com/google/trillian/storage/testonly"
 )
 
+var realMap = flag.Bool("real_map", false, "Run against a Trillian map server backed by the MySQL test database of Trillian instead of an in-memory map")
+
 const (
 	logID = 0
+	// maxMutations is large enough for every test to create a single epoch.
+	maxMutations = 1000
 )
 
 // NewDB creates a new in-memory database for testing.
@@ -109,32 +116,54 @@ f5JqSoyp0uiL8LeNYyj5vgklK8pLcyDbRqch9Az8jXVAmcBAkvaSrLW8wQ==
 	return vrf, verfier, nil
 }
 
-// NewEnv sets up common resources for tests.
-func NewEnv(t *testing.T) *Env {
-	ctx := context.Background()
-	sqldb := NewDB(t)
-
-	// Map server
+// newMapEnv starts a Trillian map server and creates a CONIKS map on it.
+func newMapEnv(ctx context.Context, t *testing.T) (*integration.MapEnv, *trillian.Tree) {
 	mapEnv, err := integration.NewMapEnv(ctx, "keytransparency")
 	if err != nil {
 		t.Fatalf("Failed to create trillian map server: %v", err)
 	}
-
-	// Configure map.
 	treeParams := stestonly.MapTree
 	treeParams.HashStrategy = trillian.HashStrategy_CONIKS_SHA512_256
 	tree, err := mapEnv.AdminClient.CreateTree(ctx, &trillian.CreateTreeRequest{
 		Tree: treeParams,
 	})
 	if err != nil {
+		mapEnv.Close()
 		t.Fatalf("CreateTree(): %v", err)
 	}
-	mapID := tree.TreeId
+	return mapEnv, tree
+}
 
-	mapPubKey, err := der.UnmarshalPublicKey(tree.GetPublicKey().GetDer())
-	if err != nil {
-		t.Fatalf("Failed to load signing keypair: %v", err)
+// NewEnv sets up common resources for tests. The map is an in-memory fake
+// unless --real_map is set.
+func NewEnv(t *testing.T) *Env {
+	ctx := context.Background()
+	sqldb := NewDB(t)
+
+	// Trillian. Every environment gets its own map ID, like maps created
+	// by Trillian, such that environments sharing the database do not see
+	// each other's mutations and commitments.
+	var mapEnv *integration.MapEnv
+	var mapTree *trillian.Tree
+	var tmap trillian.TrillianMapClient
+	var mapPubKey crypto.PublicKey
+	if *realMap {
+		mapEnv, mapTree = newMapEnv(ctx, t)
+		tmap = mapEnv.MapClient
+		pubKey, err := der.UnmarshalPublicKey(mapTree.GetPublicKey().GetDer())
+		if err != nil {
+			t.Fatalf("Failed to load signing keypair: %v", err)
+		}
+		mapPubKey = pubKey
+	} else {
+		fakeMap := fake.NewFakeTrillianMapClient(rand.Int63())
+		mapTree = fakeMap.Tree()
+		tmap = fakeMap
+		mapPubKey = fakeMap.PublicKey()
 	}
+	mapID := mapTree.GetTreeId()
+	tlog := fake.NewFakeTrillianLogClient()
+	tadmin := fake.NewFakeTrillianAdminClient(tlog.Tree(logID), mapTree)
 
 	// Common data structures.
 	mutations, err := mutations.New(sqldb, mapID)
@@ -153,17 +182,14 @@ func NewEnv(t *testing.T) *Env {
 	}
 	authz := authorization.New()
 
-	tlog := fake.NewFakeTrillianLogClient()
-	tadmin := trillian.NewTrillianAdminClient(nil)
-
 	factory := transaction.NewFactory(sqldb)
-	server := keyserver.New(logID, tlog, mapID, mapEnv.MapClient, tadmin, commitments,
+	server := keyserver.New(logID, tlog, mapID, tmap, tadmin, commitments,
 		vrfPriv, mutator, auth, authz, factory, mutations)
 	s := grpc.NewServer()
 	pb.RegisterKeyTransparencyServiceServer(s, server)
 
 	// Signer
-	signer := sequencer.New(mapID, mapEnv.MapClient, logID, tlog, mutator, mutations, factory)
+	signer := sequencer.New(mapID, tmap, logID, tlog, mutator, mutations, factory, fake.NewFakeWitness(), maxMutations)
 
 	addr, lis := Listen(t)
 	go s.Serve(lis)
@@ -173,7 +199,7 @@ func NewEnv(t *testing.T) *Env {
 	if err != nil {
 		t.Fatalf("Dial(%v) = %v", addr, err)
 	}
-	client := grpcc.New(cc, vrfPub, mapPubKey, coniks.Default, fake.NewFakeTrillianLogVerifier())
+	client := grpcc.New(cc, vrfPub, mapPubKey, coniks.Default, tlog.Verifier())
 	client.RetryCount = 0
 
 	// Mimic first sequence event
@@ -202,7 +228,9 @@ func NewEnv(t *testing.T) *Env {
 func (env *Env) Close(t *testing.T) {
 	env.Conn.Close()
 	env.GRPCServer.Stop()
-	env.mapEnv.Close()
+	if env.mapEnv != nil {
+		env.mapEnv.Close()
+	}
 	env.db.Close()
 }
 
@@ -0,0 +1,125 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/keytransparency/cmd/keytransparency-client/grpcc"
+	"github.com/google/keytransparency/core/crypto/signatures"
+
+	"golang.org/x/net/context"
+
+	tpb "github.com/google/keytransparency/core/proto/keytransparency_v1_types"
+)
+
+func TestPoll(t *testing.T) {
+	bctx := context.Background()
+	env := NewEnv(t)
+	defer env.Close(t)
+	env.Client.RetryCount = 0
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	store := grpcc.NewFileWatchStore(filepath.Join(dir, "watch"))
+
+	userID := "alice"
+	ctx := GetNewOutgoingContextWithFakeAuth(userID)
+	id := grpcc.Identity{UserID: userID, AppID: appID}
+	signers := []signatures.Signer{createSigner(t, testPrivKey1)}
+	authorizedKeys := []*tpb.PublicKey{getAuthorizedKey(testPubKey1)}
+
+	type update struct {
+		profile  []byte
+		authored bool
+	}
+	for _, tc := range []struct {
+		desc    string
+		updates []update // Each update is sequenced in its own epoch.
+		want    []update // Changes reported by Poll.
+	}{
+		{"first watch", []update{{cp(1), false}}, nil},
+		{"no new epoch", nil, nil},
+		{"authored", []update{{cp(2), true}}, []update{{cp(2), true}}},
+		{"unexpected", []update{{cp(3), false}}, []update{{cp(3), false}}},
+		{"A->B->A", []update{{cp(4), false}, {cp(3), false}}, []update{{cp(4), false}, {cp(3), false}}},
+		{"authored and unexpected", []update{{cp(5), true}, {cp(6), false}}, []update{{cp(5), true}, {cp(6), false}}},
+	} {
+		for _, u := range tc.updates {
+			req, err := env.Client.CreateUpdate(ctx, userID, appID, u.profile, signers, authorizedKeys)
+			if err != nil {
+				t.Fatalf("%v: CreateUpdate(): %v", tc.desc, err)
+			}
+			// Updates by this client are recorded before they are sent.
+			if u.authored {
+				if err := grpcc.RecordAuthored(store, req); err != nil {
+					t.Fatalf("%v: RecordAuthored(): %v", tc.desc, err)
+				}
+			}
+			if got, want := env.Client.Submit(ctx, req), grpcc.ErrRetry; got != want {
+				t.Fatalf("%v: Submit(): %v, want %v", tc.desc, got, want)
+			}
+			if err := env.Signer.CreateEpoch(bctx, true); err != nil {
+				t.Fatalf("%v: CreateEpoch(_): %v", tc.desc, err)
+			}
+		}
+
+		before, err := store.Get(id)
+		if err != nil {
+			t.Fatalf("%v: Get(): %v", tc.desc, err)
+		}
+		changes, err := env.Client.Poll(bctx, store, []grpcc.Identity{id})
+		if err != nil {
+			t.Fatalf("%v: Poll(): %v", tc.desc, err)
+		}
+		if got, want := len(changes), len(tc.want); got != want {
+			t.Fatalf("%v: len(Poll()): %v, want %v", tc.desc, got, want)
+		}
+		for i, c := range changes {
+			if got, want := c.NewProfile, tc.want[i].profile; !bytes.Equal(got, want) {
+				t.Errorf("%v: changes[%v].NewProfile: %s, want %s", tc.desc, i, got, want)
+			}
+			if got, want := c.Authored, tc.want[i].authored; got != want {
+				t.Errorf("%v: changes[%v].Authored: %v, want %v", tc.desc, i, got, want)
+			}
+			if i == 0 {
+				if got, want := c.OldProfile, before.Profile; !bytes.Equal(got, want) {
+					t.Errorf("%v: changes[0].OldProfile: %s, want %s", tc.desc, got, want)
+				}
+			}
+		}
+
+		// The watched entry is advanced to the latest update and no
+		// authored updates are left.
+		e, err := store.Get(id)
+		if err != nil {
+			t.Fatalf("%v: Get(): %v", tc.desc, err)
+		}
+		if n := len(tc.updates); n > 0 {
+			if got, want := e.Profile, tc.updates[n-1].profile; !bytes.Equal(got, want) {
+				t.Errorf("%v: Profile: %s, want %s", tc.desc, got, want)
+			}
+		}
+		if got := len(e.Authored); got != 0 {
+			t.Errorf("%v: len(Authored): %v, want 0", tc.desc, got)
+		}
+	}
+}